@@ -0,0 +1,173 @@
+// Package audit ведет журнал аудита изменений: кто, когда и в рамках какого запроса
+// изменил сущность и какие поля изменились. Запись журнала делается в той же транзакции,
+// что и само изменение.
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/lib/pq"
+	"main.go/cmd/internal/storage"
+	model "main.go/tracker_model"
+)
+
+// Типы сущностей.
+const (
+	EntityUser      = "user"
+	EntitySchedule  = "schedule" // график работы, entity_id — пользователь
+	EntitySession   = "session"
+	EntityTask      = "task"
+	EntityProject   = "project"
+	EntityRate      = "rate"
+	EntityInvoice   = "invoice"
+	EntityWebhook   = "webhook"
+	EntityAbsence   = "absence"
+	EntityTimesheet = "timesheet"
+)
+
+// Действия.
+const (
+	ActionCreated    = "created"
+	ActionUpdated    = "updated"
+	ActionDeleted    = "deleted"
+	ActionStarted    = "started"
+	ActionPaused     = "paused"
+	ActionEnded      = "ended"
+	ActionAutoClosed = "auto_closed"
+	ActionSubmitted  = "submitted"
+	ActionApproved   = "approved"
+	ActionRejected   = "rejected"
+	ActionAnonymized = "anonymized"
+)
+
+// ignoredFields поля, не попадающие в diff: вложенные коллекции, которые журналируются отдельно.
+var ignoredFields = map[string]bool{"userTask": true, "lines": true}
+
+// PersonalFields поля diff с личными данными, значения которых скрываются при обезличивании пользователя.
+var PersonalFields = []string{"surname", "name", "patronymic", "address", "passport", "description", "comment"}
+
+// Change одно изменение для журнала аудита.
+type Change struct {
+	Action   string      // действие, например updated
+	Entity   string      // тип сущности
+	EntityID int         // идентификатор сущности
+	UserID   int         // пользователь, к чьим данным относится изменение, 0 — ни к чьим
+	Before   interface{} // состояние до изменения, nil при создании
+	After    interface{} // состояние после изменения, nil при удалении
+}
+
+// Record записывает изменение в журнал. Инициатор и идентификатор запроса берутся из ctx
+// (см. Middleware); без них изменение записывается как системное.
+func Record(ctx context.Context, q storage.Querier, c Change) error {
+	diff, err := Diff(c.Before, c.After)
+	if err != nil {
+		return fmt.Errorf("ошибка вычисления изменений для журнала аудита: %v", err)
+	}
+	var actorID interface{}
+	if id := ActorID(ctx); id != 0 {
+		actorID = id
+	}
+	var userID interface{}
+	if c.UserID != 0 {
+		userID = c.UserID
+	}
+	_, err = q.Exec(`
+		INSERT INTO audit_log (actor_id, action, entity, entity_id, user_id, diff, request_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		actorID, c.Entity+"."+c.Action, c.Entity, c.EntityID, userID, string(diff), RequestID(ctx))
+	if err != nil {
+		return fmt.Errorf("ошибка записи в журнал аудита: %v", err)
+	}
+	return nil
+}
+
+// Diff возвращает JSON с полями, значения которых отличаются в before и after
+// (сравниваются JSON-представления), в виде {"поле": {"before": ..., "after": ...}}.
+func Diff(before, after interface{}) ([]byte, error) {
+	b, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	type change struct {
+		Before interface{} `json:"before"`
+		After  interface{} `json:"after"`
+	}
+	diff := map[string]change{}
+	for key, value := range a {
+		if old, ok := b[key]; !ok || !reflect.DeepEqual(old, value) {
+			diff[key] = change{Before: b[key], After: value}
+		}
+	}
+	for key, old := range b {
+		if _, ok := a[key]; !ok {
+			diff[key] = change{Before: old}
+		}
+	}
+	return json.Marshal(diff)
+}
+
+// fields возвращает JSON-представление значения в виде набора полей.
+func fields(v interface{}) (map[string]interface{}, error) {
+	result := map[string]interface{}{}
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil()) {
+		return result, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	for key := range ignoredFields {
+		delete(result, key)
+	}
+	return result, nil
+}
+
+// Redact скрывает значения личных полей (PersonalFields) во всех записях журнала о данных пользователя.
+// Должна вызываться в транзакции: журнал допускает изменение только с включенной на время
+// транзакции настройкой audit.redact.
+func Redact(tx storage.Querier, userID int) (int64, error) {
+	if _, err := tx.Exec(`SET LOCAL audit.redact = 'on'`); err != nil {
+		return 0, fmt.Errorf("ошибка включения обезличивания журнала аудита: %v", err)
+	}
+	res, err := tx.Exec(`
+		UPDATE audit_log
+		SET diff = (diff - $2::text[]) || COALESCE((
+			SELECT jsonb_object_agg(key, '{"before": "[REDACTED]", "after": "[REDACTED]"}'::jsonb)
+			FROM jsonb_object_keys(diff) AS key
+			WHERE key = ANY($2)), '{}'::jsonb)
+		WHERE user_id = $1 AND diff ?| $2`, userID, pq.Array(PersonalFields))
+	if err != nil {
+		return 0, fmt.Errorf("ошибка обезличивания журнала аудита: %v", err)
+	}
+	return res.RowsAffected()
+}
+
+// Columns перечень колонок audit_log в порядке, ожидаемом Scan.
+const Columns = "id, actor_id, action, entity, entity_id, user_id, diff, request_id, created_at"
+
+// Scan сканирует строку с колонками Columns в запись журнала.
+func Scan(row storage.RowScanner) (model.AuditEntry, error) {
+	var entry model.AuditEntry
+	var actorID, userID sql.NullInt64
+	var diff string
+	err := row.Scan(&entry.ID, &actorID, &entry.Action, &entry.Entity, &entry.EntityID, &userID, &diff, &entry.RequestID, &entry.CreatedAt)
+	if err != nil {
+		return entry, err
+	}
+	entry.ActorID = int(actorID.Int64)
+	entry.UserID = int(userID.Int64)
+	entry.Diff = json.RawMessage(diff)
+	return entry, nil
+}
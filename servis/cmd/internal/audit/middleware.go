@@ -0,0 +1,62 @@
+package audit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+	"strconv"
+)
+
+// RequestIDHeader заголовок с идентификатором запроса. Переданный клиентом идентификатор
+// сохраняется, иначе генерируется новый; в обоих случаях он возвращается в ответе.
+const RequestIDHeader = "X-Request-ID"
+
+// actorHeader заголовок с идентификатором пользователя, выполняющего действие (см. util.ActorHeader).
+const actorHeader = "X-User-ID"
+
+// validRequestID допустимый идентификатор запроса, переданный клиентом.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,64}$`)
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	actorIDKey
+)
+
+// Middleware сохраняет в контексте запроса его идентификатор и инициатора изменений для Record.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+
+		ctx := context.WithValue(r.Context(), requestIDKey, requestID)
+		if actorID, err := strconv.Atoi(r.Header.Get(actorHeader)); err == nil && actorID > 0 {
+			ctx = context.WithValue(ctx, actorIDKey, actorID)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequestID возвращает идентификатор запроса из контекста или пустую строку.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// ActorID возвращает инициатора изменений из контекста или 0, если он не указан.
+func ActorID(ctx context.Context) int {
+	id, _ := ctx.Value(actorIDKey).(int)
+	return id
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,240 @@
+package autostop
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"main.go/cmd/internal/audit"
+	"main.go/cmd/internal/config"
+	"main.go/cmd/internal/events"
+	"main.go/cmd/internal/outbox"
+	"main.go/cmd/internal/storage"
+	"main.go/cmd/internal/storage/cache"
+	"main.go/cmd/internal/taskbudget"
+	model "main.go/tracker_model"
+)
+
+// Run периодически завершает забытые незавершенные сессии, пока не будет отменен ctx.
+// Сессия завершается, если она открыта дольше MaxDuration или прошел конец рабочего дня WorkdayEnd.
+func Run(ctx context.Context, db *sql.DB, log *slog.Logger, cfg config.AutoStopConfig) {
+	if !cfg.Enabled {
+		log.Info("Автоматическое завершение сессий отключено")
+		return
+	}
+
+	workdayEnd, err := parseWorkdayEnd(cfg.WorkdayEnd)
+	if err != nil {
+		log.Error("Неверный формат workday_end, автоматическое завершение сессий отключено", slog.String("error", err.Error()))
+		return
+	}
+	if cfg.Interval <= 0 {
+		log.Warn("Не задан interval, автоматическое завершение сессий отключено")
+		return
+	}
+	if cfg.MaxDuration <= 0 && workdayEnd < 0 {
+		log.Warn("Не заданы max_duration и workday_end, автоматическое завершение сессий отключено")
+		return
+	}
+
+	log.Info("Запущено автоматическое завершение сессий", slog.Duration("interval", cfg.Interval), slog.Duration("max_duration", cfg.MaxDuration), slog.String("workday_end", cfg.WorkdayEnd))
+
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		closed, err := closeForgottenSessions(ctx, db, log, cfg.MaxDuration, workdayEnd, time.Now())
+		if err != nil {
+			log.Error("Ошибка при автоматическом завершении сессий", slog.String("error", err.Error()))
+		} else if closed > 0 {
+			log.Info("Забытые сессии завершены автоматически", slog.Int("count", closed))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// parseWorkdayEnd разбирает время окончания рабочего дня в формате HH:MM
+// и возвращает смещение от полуночи. Пустая строка дает -1.
+func parseWorkdayEnd(s string) (time.Duration, error) {
+	if s == "" {
+		return -1, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return -1, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// closeTime вычисляет момент, в который должна быть завершена сессия, начатая в startTime:
+// ближайший конец рабочего дня после начала или начало плюс maxDuration, что наступит раньше.
+func closeTime(startTime time.Time, maxDuration, workdayEnd time.Duration) time.Time {
+	var result time.Time
+	if maxDuration > 0 {
+		result = startTime.Add(maxDuration)
+	}
+	if workdayEnd >= 0 {
+		// Время собирается по часам и минутам, а не прибавляется к полуночи,
+		// чтобы не сдвигаться на час в дни перехода на летнее время
+		hour, minute := int(workdayEnd/time.Hour), int(workdayEnd%time.Hour/time.Minute)
+		dayEnd := time.Date(startTime.Year(), startTime.Month(), startTime.Day(), hour, minute, 0, 0, startTime.Location())
+		if !dayEnd.After(startTime) {
+			dayEnd = dayEnd.AddDate(0, 0, 1)
+		}
+		if result.IsZero() || dayEnd.Before(result) {
+			result = dayEnd
+		}
+	}
+	return result
+}
+
+// closeForgottenSessions завершает все незавершенные сессии, момент завершения которых уже наступил,
+// помечает их как auto_closed и обновляет кэш. Возвращает количество завершенных сессий.
+func closeForgottenSessions(ctx context.Context, db *sql.DB, log *slog.Logger, maxDuration, workdayEnd time.Duration, now time.Time) (int, error) {
+	type openSession struct {
+		id        int
+		userID    int
+		startTime time.Time
+		timezone  string
+	}
+
+	// Сессии, начатые в согласованной неделе, не выбираются: завершить их автоматически
+	// нельзя, и без этого условия предупреждение о них повторялось бы на каждом проходе
+	rows, err := db.Query(`
+		SELECT ut.id, ut.user_id, ut.start_time, u.timezone
+		FROM users_tasks ut
+		JOIN users u ON u.id = ut.user_id
+		WHERE ut.end_time IS NULL
+			AND NOT EXISTS (
+				SELECT 1 FROM timesheets t
+				WHERE t.user_id = ut.user_id AND t.status = $1
+					AND t.week_start = date_trunc('week', ut.start_time AT TIME ZONE u.timezone)::date
+			)
+	`, model.StatusApproved)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка при получении незавершенных сессий: %v", err)
+	}
+	var sessions []openSession
+	for rows.Next() {
+		var s openSession
+		if err := rows.Scan(&s.id, &s.userID, &s.startTime, &s.timezone); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("ошибка сканирования незавершенной сессии: %v", err)
+		}
+		sessions = append(sessions, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("ошибка итерации по незавершенным сессиям: %v", err)
+	}
+
+	closed := 0
+	for _, s := range sessions {
+		// Конец рабочего дня отсчитывается в часовом поясе пользователя
+		loc, err := storage.LoadTimezone(s.timezone)
+		if err != nil {
+			loc = time.UTC
+		}
+		endTime := closeTime(s.startTime.In(loc), maxDuration, workdayEnd)
+		if endTime.After(now) {
+			continue
+		}
+		totalSeconds := int64(endTime.Sub(s.startTime) / time.Second)
+
+		task, budget, err := closeSession(ctx, db, s.id, endTime, totalSeconds)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if errors.Is(err, storage.ErrPeriodLocked) {
+			// Сюда попадают только сессии, момент завершения которых приходится на согласованную
+			// позднюю неделю; сессии из согласованной недели начала отсеяны запросом выше
+			log.Debug("Период согласован, сессия не завершена автоматически", slog.Int("session_id", s.id), slog.Int("user_id", s.userID))
+			continue
+		}
+		if err != nil {
+			return closed, err
+		}
+
+		closed++
+		log.Info("Сессия завершена автоматически", slog.Int("session_id", s.id), slog.Int("user_id", s.userID), slog.Time("end_time", endTime))
+		taskbudget.LogAlert(log, budget)
+
+		cache.UserCacheMutex.Lock()
+		if user, exists := cache.UserCache[s.userID]; exists {
+			for i := range user.UserTask {
+				if user.UserTask[i].IDSession == s.id {
+					user.UserTask[i] = task
+					break
+				}
+			}
+			cache.UserCache[s.userID] = user
+		}
+		cache.UserCacheMutex.Unlock()
+	}
+	return closed, nil
+}
+
+// closeSession в одной транзакции завершает сессию с отметкой auto_closed, записывает
+// событие о завершении в outbox и проверяет бюджет задачи, как при ручном завершении.
+// Если сессия уже завершена, возвращается sql.ErrNoRows, если она попадает в согласованный
+// период — storage.ErrPeriodLocked. В журнал аудита завершение записывается как системное действие.
+// Возвращает бюджет задачи с достигнутым порогом либо nil.
+func closeSession(ctx context.Context, db *sql.DB, sessionID int, endTime time.Time, totalSeconds int64) (model.UserTask, *model.TaskBudget, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return model.UserTask{}, nil, fmt.Errorf("ошибка при начале транзакции: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Условие end_time IS NULL защищает от гонки с одновременным /end_task
+	before, err := storage.ScanUserTask(tx.QueryRow(`
+		SELECT `+storage.UserTaskColumns+`
+		FROM users_tasks
+		WHERE id = $1 AND end_time IS NULL
+		FOR UPDATE`, sessionID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return before, nil, err
+	}
+	if err != nil {
+		return before, nil, fmt.Errorf("ошибка при получении сессии %d: %v", sessionID, err)
+	}
+	if err := storage.CheckPeriodUnlocked(tx, before.UserID, before.StartTime, endTime); err != nil {
+		return before, nil, err
+	}
+
+	task, err := storage.ScanUserTask(tx.QueryRow(`
+		UPDATE users_tasks
+		SET end_time = $1, total_seconds = $2, auto_closed = TRUE
+		WHERE id = $3
+		RETURNING `+storage.UserTaskColumns,
+		endTime, totalSeconds, sessionID))
+	if err != nil {
+		return task, nil, fmt.Errorf("ошибка при завершении сессии %d: %v", sessionID, err)
+	}
+
+	if err := outbox.EnqueueSession(tx, events.SessionEnded, task); err != nil {
+		return task, nil, err
+	}
+	err = audit.Record(ctx, tx, audit.Change{Action: audit.ActionAutoClosed, Entity: audit.EntitySession, EntityID: task.IDSession, UserID: task.UserID, Before: before, After: task})
+	if err != nil {
+		return task, nil, err
+	}
+
+	budget, err := taskbudget.Check(tx, task.UserID, task.IDTask)
+	if err != nil {
+		return task, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return task, nil, fmt.Errorf("ошибка при фиксации транзакции: %v", err)
+	}
+	return task, budget, nil
+}
@@ -0,0 +1,154 @@
+package calendar
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"main.go/cmd/internal/storage"
+)
+
+// Schedule норма рабочего времени в секундах по дням недели, индекс — time.Weekday.
+// Нулевая норма означает выходной день.
+type Schedule [7]int64
+
+// DefaultSchedule возвращает график с нормой daily с понедельника по пятницу.
+func DefaultSchedule(daily time.Duration) Schedule {
+	var s Schedule
+	for d := time.Monday; d <= time.Friday; d++ {
+		s[d] = int64(daily / time.Second)
+	}
+	return s
+}
+
+// WeekdayName возвращает название дня недели в нижнем регистре, например monday.
+func WeekdayName(d time.Weekday) string {
+	return strings.ToLower(d.String())
+}
+
+// ParseWeekday возвращает день недели по названию, полученному из WeekdayName.
+func ParseWeekday(name string) (time.Weekday, bool) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if WeekdayName(d) == name {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// UserSchedule возвращает график пользователя. Если график не задан, возвращается fallback
+// и false вторым значением.
+func UserSchedule(q storage.Querier, userID int, fallback Schedule) (Schedule, bool, error) {
+	rows, err := q.Query(`SELECT weekday, seconds FROM work_schedules WHERE user_id = $1`, userID)
+	if err != nil {
+		return fallback, false, fmt.Errorf("ошибка при получении графика работы: %v", err)
+	}
+	defer rows.Close()
+
+	var s Schedule
+	found := false
+	for rows.Next() {
+		var weekday int
+		var seconds int64
+		if err := rows.Scan(&weekday, &seconds); err != nil {
+			return fallback, false, fmt.Errorf("ошибка сканирования графика работы: %v", err)
+		}
+		s[weekday] = seconds
+		found = true
+	}
+	if err := rows.Err(); err != nil {
+		return fallback, false, fmt.Errorf("ошибка итерации по графику работы: %v", err)
+	}
+	if !found {
+		return fallback, false, nil
+	}
+	return s, true, nil
+}
+
+// SetUserSchedule заменяет график пользователя в транзакции tx.
+func SetUserSchedule(tx *sql.Tx, userID int, s Schedule) error {
+	if _, err := tx.Exec(`DELETE FROM work_schedules WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("ошибка при удалении графика работы: %v", err)
+	}
+	for d, seconds := range s {
+		_, err := tx.Exec(`INSERT INTO work_schedules (user_id, weekday, seconds) VALUES ($1, $2, $3)`, userID, d, seconds)
+		if err != nil {
+			return fmt.Errorf("ошибка при сохранении графика работы: %v", err)
+		}
+	}
+	return nil
+}
+
+// Holidays возвращает названия праздничных дней в диапазоне [from, to] по датам в формате YYYY-MM-DD.
+func Holidays(q storage.Querier, from, to time.Time) (map[string]string, error) {
+	rows, err := q.Query(`
+		SELECT to_char(date, 'YYYY-MM-DD'), name FROM holidays WHERE date BETWEEN $1 AND $2
+	`, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении праздничных дней: %v", err)
+	}
+	defer rows.Close()
+
+	holidays := map[string]string{}
+	for rows.Next() {
+		var date, name string
+		if err := rows.Scan(&date, &name); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования праздничного дня: %v", err)
+		}
+		holidays[date] = name
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по праздничным дням: %v", err)
+	}
+	return holidays, nil
+}
+
+// LoadHolidaysFile загружает праздничные дни из файла path в базу данных и возвращает их количество.
+// Каждая строка файла содержит дату в формате YYYY-MM-DD и необязательное название через пробел,
+// пустые строки и строки, начинающиеся с #, пропускаются. Уже загруженные даты обновляются.
+func LoadHolidaysFile(db *sql.DB, path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка открытия файла праздничных дней: %v", err)
+	}
+	defer f.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("ошибка при начале транзакции: %v", err)
+	}
+	defer tx.Rollback()
+
+	count := 0
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		dateStr, name, _ := strings.Cut(text, " ")
+		date, err := time.Parse("2006-01-02", dateStr)
+		if err != nil {
+			return 0, fmt.Errorf("%s:%d: неверная дата %q", path, line, dateStr)
+		}
+		_, err = tx.Exec(`
+			INSERT INTO holidays (date, name) VALUES ($1, $2)
+			ON CONFLICT (date) DO UPDATE SET name = EXCLUDED.name
+		`, date, strings.TrimSpace(name))
+		if err != nil {
+			return 0, fmt.Errorf("ошибка при сохранении праздничного дня %s: %v", dateStr, err)
+		}
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("ошибка чтения файла праздничных дней: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("ошибка при фиксации транзакции: %v", err)
+	}
+	return count, nil
+}
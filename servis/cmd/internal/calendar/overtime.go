@@ -0,0 +1,93 @@
+package calendar
+
+import (
+	"time"
+
+	model "main.go/tracker_model"
+)
+
+// Overtime распределяет отработанное время worked (секунды по датам YYYY-MM-DD) за период
+// [from, to] на норму и переработку. За день переработкой считается время сверх нормы по графику s;
+// в праздничные дни и дни отсутствий из absences норма нулевая, при отсутствии на половину дня —
+// половинная. За неделю (с понедельника) переработка — большее из суммы дневных переработок
+// и времени сверх weeklyThreshold; недели на границах периода учитываются только днями внутри
+// периода. Нулевой weeklyThreshold отключает недельный порог. Время, отработанное в выходные
+// по графику и праздничные дни, дополнительно суммируется в WeekendAndHolidaySeconds; дни
+// отсутствий в эту сумму не входят.
+func Overtime(worked map[string]int64, from, to time.Time, s Schedule, holidays map[string]string, absences []model.Absence, weeklyThreshold time.Duration) model.OvertimeReport {
+	report := model.OvertimeReport{
+		StartDate:              from.Format("2006-01-02"),
+		EndDate:                to.Format("2006-01-02"),
+		WeeklyThresholdSeconds: int64(weeklyThreshold / time.Second),
+		Days:                   []model.OvertimeDay{},
+		Weeks:                  []model.OvertimeWeek{},
+	}
+
+	var week *model.OvertimeWeek
+	var dailyOvertime int64
+	closeWeek := func() {
+		if week == nil {
+			return
+		}
+		week.OvertimeSeconds = dailyOvertime
+		if report.WeeklyThresholdSeconds > 0 && week.WorkedSeconds-report.WeeklyThresholdSeconds > week.OvertimeSeconds {
+			week.OvertimeSeconds = week.WorkedSeconds - report.WeeklyThresholdSeconds
+		}
+		week.RegularSeconds = week.WorkedSeconds - week.OvertimeSeconds
+
+		report.Total.WorkedSeconds += week.WorkedSeconds
+		report.Total.ScheduledSeconds += week.ScheduledSeconds
+		report.Total.RegularSeconds += week.RegularSeconds
+		report.Total.OvertimeSeconds += week.OvertimeSeconds
+		report.Total.WeekendAndHolidaySeconds += week.WeekendAndHolidaySeconds
+		report.Total.AbsenceDays += week.AbsenceDays
+		report.Weeks = append(report.Weeks, *week)
+		week, dailyOvertime = nil, 0
+	}
+
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		if week == nil || d.Weekday() == time.Monday {
+			closeWeek()
+			monday := d.AddDate(0, 0, -(int(d.Weekday())+6)%7)
+			week = &model.OvertimeWeek{WeekStart: monday.Format("2006-01-02")}
+		}
+
+		date := d.Format("2006-01-02")
+		day := model.OvertimeDay{
+			Date:             date,
+			WorkedSeconds:    worked[date],
+			ScheduledSeconds: s[d.Weekday()],
+			Weekend:          s[d.Weekday()] == 0,
+		}
+		if name, ok := holidays[date]; ok {
+			day.Holiday, day.HolidayName = true, name
+			day.ScheduledSeconds = 0
+		}
+		for _, absence := range absences {
+			if d.Before(absence.StartDate) || d.After(absence.EndDate) {
+				continue
+			}
+			day.Absence = absence.Type
+			if absence.HalfDay {
+				day.ScheduledSeconds /= 2
+				week.AbsenceDays += 0.5
+			} else {
+				day.ScheduledSeconds = 0
+				week.AbsenceDays++
+			}
+			break
+		}
+		day.RegularSeconds = min(day.WorkedSeconds, day.ScheduledSeconds)
+		day.OvertimeSeconds = day.WorkedSeconds - day.RegularSeconds
+		report.Days = append(report.Days, day)
+
+		week.WorkedSeconds += day.WorkedSeconds
+		week.ScheduledSeconds += day.ScheduledSeconds
+		if day.Weekend || day.Holiday {
+			week.WeekendAndHolidaySeconds += day.WorkedSeconds
+		}
+		dailyOvertime += day.OvertimeSeconds
+	}
+	closeWeek()
+	return report
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"time"
@@ -12,6 +13,13 @@ type Config struct {
 	Env        string           `yaml:"env" env:"ENV" env-default:"local"`
 	Database   DatabaseConfig   `yaml:"database"` // Database содержит настройки базы данных.
 	HTTPServer HTTPServerConfig `yaml:"http_server"`
+	AutoStop   AutoStopConfig   `yaml:"auto_stop"` // AutoStop настройки автоматического завершения забытых сессий.
+	Tasks      TasksConfig      `yaml:"tasks"`     // Tasks политики учета времени по задачам.
+	Webhooks   WebhooksConfig   `yaml:"webhooks"`  // Webhooks настройки доставки событий внешним системам.
+	Outbox     OutboxConfig     `yaml:"outbox"`    // Outbox настройки публикации событий из outbox.
+	Rounding   RoundingConfig   `yaml:"rounding"`  // Rounding настройки округления длительности сессий в отчетах.
+	Calendar   CalendarConfig   `yaml:"calendar"`  // Calendar производственный календарь и пороги переработки.
+	Passport   PassportConfig   `yaml:"passport"`  // Passport ключ шифрования паспортных данных.
 }
 
 type DatabaseConfig struct {
@@ -21,6 +29,11 @@ type DatabaseConfig struct {
 	Password string `yaml:"password"` // Password пароль пользователя базы данных.
 	DBName   string `yaml:"dbname"`   // DBName имя базы данных.
 	SSLMode  string `yaml:"sslmode"`  // SSLMode режим SSL подключения.
+
+	ConnectAttempts int           `yaml:"connect_attempts" env-default:"10"` // ConnectAttempts количество попыток подключения при старте.
+	ConnectTimeout  time.Duration `yaml:"connect_timeout" env-default:"5s"`  // ConnectTimeout таймаут одной попытки подключения.
+	ConnectBackoff  time.Duration `yaml:"connect_backoff" env-default:"1s"`  // ConnectBackoff задержка перед второй попыткой, далее удваивается.
+	MaxBackoff      time.Duration `yaml:"max_backoff" env-default:"30s"`     // MaxBackoff наибольшая задержка между попытками подключения.
 }
 
 type HTTPServerConfig struct {
@@ -29,6 +42,57 @@ type HTTPServerConfig struct {
 	IdleTimeout time.Duration `yaml:"idle_timeout" env-default:"60s"`
 }
 
+type AutoStopConfig struct {
+	Enabled     bool          `yaml:"enabled" env-default:"true"`     // Enabled включает фоновую проверку.
+	Interval    time.Duration `yaml:"interval" env-default:"5m"`      // Interval период проверки незавершенных сессий.
+	MaxDuration time.Duration `yaml:"max_duration" env-default:"12h"` // MaxDuration максимальная длительность сессии, 0 — без ограничения.
+	WorkdayEnd  string        `yaml:"workday_end"`                    // WorkdayEnd конец рабочего дня в формате HH:MM в часовом поясе пользователя, пусто — не используется.
+}
+
+type TasksConfig struct {
+	SingleActiveSession bool `yaml:"single_active_session" env-default:"true"` // SingleActiveSession завершает текущую сессию при старте новой.
+}
+
+type WebhooksConfig struct {
+	Interval    time.Duration `yaml:"interval" env-default:"1s"`     // Interval период опроса очереди доставки.
+	BatchSize   int           `yaml:"batch_size" env-default:"100"`  // BatchSize количество доставок, выполняемых за один проход.
+	Timeout     time.Duration `yaml:"timeout" env-default:"5s"`      // Timeout таймаут одной попытки доставки.
+	MaxAttempts int           `yaml:"max_attempts" env-default:"5"`  // MaxAttempts максимальное количество попыток доставки.
+	BaseBackoff time.Duration `yaml:"base_backoff" env-default:"1s"` // BaseBackoff задержка перед второй попыткой, далее удваивается.
+}
+
+type OutboxConfig struct {
+	Interval    time.Duration `yaml:"interval" env-default:"1s"`            // Interval период опроса outbox.
+	BatchSize   int           `yaml:"batch_size" env-default:"100"`         // BatchSize количество событий, публикуемых за один проход.
+	MaxAttempts int           `yaml:"max_attempts" env-default:"10"`        // MaxAttempts количество попыток публикации события.
+	LockTimeout time.Duration `yaml:"lock_timeout" env-default:"1m"`        // LockTimeout время, на которое пачка событий закрепляется за экземпляром на время передачи.
+	Sinks       []string      `yaml:"sinks" env-default:"bus,log,webhooks"` // Sinks получатели событий: bus, log, webhooks.
+}
+
+type RoundingConfig struct {
+	Mode string        `yaml:"mode" env-default:"exact"` // Mode режим округления: exact, nearest, up.
+	Step time.Duration `yaml:"step" env-default:"15m"`   // Step шаг округления для режимов nearest и up.
+}
+
+type CalendarConfig struct {
+	HolidaysFile    string        `yaml:"holidays_file"`                      // HolidaysFile файл праздничных дней, загружаемый при старте, пусто — не загружается.
+	DailyHours      time.Duration `yaml:"daily_hours" env-default:"8h"`       // DailyHours норма рабочего дня с понедельника по пятницу для пользователей без графика.
+	WeeklyThreshold time.Duration `yaml:"weekly_threshold" env-default:"40h"` // WeeklyThreshold недельный порог, сверх которого время считается переработкой, 0 — не используется.
+}
+
+type PassportConfig struct {
+	Key     string `yaml:"key" env:"PASSPORT_KEY"`           // Key мастер-ключ шифрования паспортных данных: 32 байта в base64.
+	KeyFile string `yaml:"key_file" env:"PASSPORT_KEY_FILE"` // KeyFile файл с мастер-ключом в base64, используется, если Key не задан.
+}
+
+// String скрывает ключ при выводе настроек.
+func (c PassportConfig) String() string {
+	if c.Key != "" {
+		return fmt.Sprintf("{Key:%s KeyFile:%s}", "[REDACTED]", c.KeyFile)
+	}
+	return fmt.Sprintf("{Key: KeyFile:%s}", c.KeyFile)
+}
+
 func MustLoad() *Config {
 	//необходимо установить переменную окружения к файлу ./servis/cmd/config/local.yaml
 	configPath := os.Getenv("CONFIG_PATH_TRACKER")
@@ -0,0 +1,111 @@
+package events
+
+import (
+	"sync"
+	"time"
+
+	model "main.go/tracker_model"
+)
+
+// Типы событий по сессиям.
+const (
+	SessionStarted = "session.started" // начат отсчет времени по задаче
+	SessionPaused  = "session.paused"  // сессия остановлена из-за переключения на другую задачу
+	SessionEnded   = "session.ended"   // сессия завершена пользователем или автоматически
+	SessionAdded   = "session.added"   // сессия добавлена вручную
+	SessionEdited  = "session.edited"  // время сессии исправлено
+)
+
+// Типы событий по пользователям.
+const (
+	UserAdded      = "user.added"      // пользователь добавлен
+	UserDeleted    = "user.deleted"    // пользователь удален вместе с задачами
+	UserAnonymized = "user.anonymized" // личные данные пользователя обезличены по его запросу
+)
+
+// Типы событий по бюджетам задач.
+const (
+	TaskBudgetWarning  = "task.budget_warning"  // затраченное время достигло 80% оценки задачи
+	TaskBudgetExceeded = "task.budget_exceeded" // затраченное время достигло 100% оценки задачи
+)
+
+// Types перечень всех типов событий, на которые можно подписаться.
+var Types = []string{SessionStarted, SessionPaused, SessionEnded, SessionAdded, SessionEdited, UserAdded, UserDeleted, UserAnonymized, TaskBudgetWarning, TaskBudgetExceeded}
+
+// subscriberBuffer размер буфера канала подписчика. События для подписчика,
+// не успевающего их читать, отбрасываются, чтобы не блокировать обработчики запросов.
+const subscriberBuffer = 64
+
+// Event событие об изменении сессии, пользователя или бюджета задачи.
+type Event struct {
+	ID         int64             `json:"id"`
+	Type       string            `json:"type"`
+	UserID     int               `json:"user_id"`
+	Session    *model.UserTask   `json:"session,omitempty"`
+	User       *model.Users      `json:"user,omitempty"`
+	Budget     *model.TaskBudget `json:"budget,omitempty"`
+	OccurredAt time.Time         `json:"occurred_at"`
+}
+
+var (
+	subscribers      map[int]chan Event
+	subscribersMutex sync.Mutex
+	nextSubscriberID int
+	nextEventID      int64
+)
+
+// Publish рассылает событие всем подписчикам. Событию без идентификатора (не из outbox)
+// присваивается очередной локальный идентификатор, без времени — текущее время.
+func Publish(event Event) {
+	subscribersMutex.Lock()
+	defer subscribersMutex.Unlock()
+
+	if event.ID == 0 {
+		nextEventID++
+		event.ID = nextEventID
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+
+	for _, ch := range subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// PublishSession публикует событие типа eventType по сессии task.
+func PublishSession(eventType string, task model.UserTask) {
+	Publish(Event{Type: eventType, UserID: task.UserID, Session: &task})
+}
+
+// PublishUser публикует событие типа eventType по пользователю user.
+func PublishUser(eventType string, user model.Users) {
+	Publish(Event{Type: eventType, UserID: user.UserID, User: &user})
+}
+
+// Subscribe регистрирует нового подписчика и возвращает канал событий
+// и функцию отмены подписки, которую необходимо вызвать по завершении чтения.
+func Subscribe() (<-chan Event, func()) {
+	subscribersMutex.Lock()
+	defer subscribersMutex.Unlock()
+
+	if subscribers == nil {
+		subscribers = make(map[int]chan Event)
+	}
+	nextSubscriberID++
+	id := nextSubscriberID
+	ch := make(chan Event, subscriberBuffer)
+	subscribers[id] = ch
+
+	return ch, func() {
+		subscribersMutex.Lock()
+		defer subscribersMutex.Unlock()
+		if _, ok := subscribers[id]; ok {
+			delete(subscribers, id)
+			close(ch)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+// Package filter разбирает выражения фильтрации вида
+//
+//	surname~"Ivan" and (address~"Москва" or passport_serie=1234)
+//
+// в параметризованное SQL-условие. В текст запроса попадают только выражения полей
+// из белого списка, операторы и плейсхолдеры; все значения передаются аргументами.
+//
+// Грамматика (ключевые слова без учета регистра):
+//
+//	expr  = and { "or" and }
+//	and   = unary { "and" unary }
+//	unary = "not" unary | "(" expr ")" | field op value | field ["not"] "in" "(" value { "," value } ")"
+//	op    = "=" | "!=" | "<" | "<=" | ">" | ">=" | "~" | "!~"
+//	value = "строка" | целое число
+//
+// Операторы ~ и !~ (содержит / не содержит подстроку без учета регистра) допустимы только для строковых полей.
+package filter
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"main.go/cmd/internal/storage"
+)
+
+// Ограничения на размер выражения.
+const (
+	MaxLength     = 1000 // символов в выражении
+	MaxDepth      = 10   // уровней вложенности скобок и not
+	MaxConditions = 50   // сравнений в выражении
+)
+
+// Типы полей.
+const (
+	String = iota
+	Int
+)
+
+// Field поле, по которому разрешена фильтрация. Column — SQL-выражение,
+// подставляемое в запрос как есть, поэтому задается только в коде.
+// EqualityOnly разрешает только =, !=, in и not in; Value, если задана, преобразует
+// значение перед передачей в запрос (например, в слепой индекс зашифрованного поля)
+// и тоже ограничивает поле сравнением на равенство.
+type Field struct {
+	Column       string
+	Type         int
+	EqualityOnly bool
+	Value        func(value interface{}) interface{}
+}
+
+// Fields белый список полей по именам, используемым в выражении.
+type Fields map[string]Field
+
+// SyntaxError ошибка разбора выражения с позицией символа (начиная с 1).
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("позиция %d: %s", e.Pos, e.Msg)
+}
+
+// Parse разбирает выражение и возвращает SQL-условие. Значения добавляются к args,
+// нумерация плейсхолдеров продолжает уже имеющиеся аргументы.
+func Parse(input string, fields Fields, args []interface{}) (string, []interface{}, error) {
+	if len([]rune(input)) > MaxLength {
+		return "", args, &SyntaxError{Pos: MaxLength + 1, Msg: fmt.Sprintf("выражение длиннее %d символов", MaxLength)}
+	}
+	tokens, err := tokenize(input)
+	if err != nil {
+		return "", args, err
+	}
+
+	p := &parser{tokens: tokens, fields: fields, args: args}
+	if p.peek().kind == tokEOF {
+		return "", args, &SyntaxError{Pos: 1, Msg: "пустое выражение"}
+	}
+	where, err := p.parseOr(0)
+	if err != nil {
+		return "", args, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return "", args, p.unexpected(t, "and, or или конец выражения")
+	}
+	return where, p.args, nil
+}
+
+type parser struct {
+	tokens     []token
+	i          int
+	fields     Fields
+	args       []interface{}
+	conditions int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+// keyword проверяет, является ли лексема ключевым словом kw.
+func keyword(t token, kw string) bool {
+	return t.kind == tokIdent && strings.EqualFold(t.text, kw)
+}
+
+func (p *parser) unexpected(t token, expected string) error {
+	return &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("ожидалось: %s; получено: %s", expected, t.describe())}
+}
+
+func (p *parser) parseOr(depth int) (string, error) {
+	left, err := p.parseAnd(depth)
+	if err != nil {
+		return "", err
+	}
+	parts := []string{left}
+	for keyword(p.peek(), "or") {
+		p.next()
+		right, err := p.parseAnd(depth)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, right)
+	}
+	if len(parts) == 1 {
+		return left, nil
+	}
+	return "(" + strings.Join(parts, " OR ") + ")", nil
+}
+
+func (p *parser) parseAnd(depth int) (string, error) {
+	left, err := p.parseUnary(depth)
+	if err != nil {
+		return "", err
+	}
+	parts := []string{left}
+	for keyword(p.peek(), "and") {
+		p.next()
+		right, err := p.parseUnary(depth)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, right)
+	}
+	if len(parts) == 1 {
+		return left, nil
+	}
+	return "(" + strings.Join(parts, " AND ") + ")", nil
+}
+
+func (p *parser) parseUnary(depth int) (string, error) {
+	t := p.peek()
+	if depth >= MaxDepth && (t.kind == tokLParen || keyword(t, "not")) {
+		return "", &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("вложенность больше %d уровней", MaxDepth)}
+	}
+
+	switch {
+	case keyword(t, "not"):
+		p.next()
+		inner, err := p.parseUnary(depth + 1)
+		if err != nil {
+			return "", err
+		}
+		return "NOT (" + inner + ")", nil
+	case t.kind == tokLParen:
+		p.next()
+		inner, err := p.parseOr(depth + 1)
+		if err != nil {
+			return "", err
+		}
+		if t := p.next(); t.kind != tokRParen {
+			return "", p.unexpected(t, `")"`)
+		}
+		return inner, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (string, error) {
+	t := p.next()
+	if t.kind != tokIdent || keyword(t, "and") || keyword(t, "or") || keyword(t, "in") {
+		return "", p.unexpected(t, "имя поля, not или \"(\"")
+	}
+	field, ok := p.fields[strings.ToLower(t.text)]
+	if !ok {
+		return "", &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("неизвестное поле %q, допустимы: %s", t.text, p.fieldNames())}
+	}
+
+	p.conditions++
+	if p.conditions > MaxConditions {
+		return "", &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("больше %d сравнений в выражении", MaxConditions)}
+	}
+
+	op := p.next()
+	negate := false
+	if keyword(op, "not") {
+		negate = true
+		op = p.next()
+		if !keyword(op, "in") {
+			return "", p.unexpected(op, "in")
+		}
+	}
+	if keyword(op, "in") {
+		return p.parseIn(field, negate)
+	}
+	if op.kind != tokOp {
+		return "", p.unexpected(op, "оператор сравнения (=, !=, <, <=, >, >=, ~, !~) или in")
+	}
+	if (field.EqualityOnly || field.Value != nil) && op.text != "=" && op.text != "!=" {
+		return "", &SyntaxError{Pos: op.pos, Msg: fmt.Sprintf("для поля %s допустимы только =, != и in", t.text)}
+	}
+	if (op.text == "~" || op.text == "!~") && field.Type != String {
+		return "", &SyntaxError{Pos: op.pos, Msg: fmt.Sprintf("оператор %s допустим только для строковых полей", op.text)}
+	}
+
+	value, err := p.parseValue(field)
+	if err != nil {
+		return "", err
+	}
+
+	switch op.text {
+	case "~", "!~":
+		sqlOp := "ILIKE"
+		if op.text == "!~" {
+			sqlOp = "NOT ILIKE"
+		}
+		p.args = append(p.args, "%"+storage.EscapeLike(value.(string))+"%")
+		return fmt.Sprintf("%s %s $%d", field.Column, sqlOp, len(p.args)), nil
+	case "!=":
+		p.args = append(p.args, value)
+		return fmt.Sprintf("%s <> $%d", field.Column, len(p.args)), nil
+	}
+	p.args = append(p.args, value)
+	return fmt.Sprintf("%s %s $%d", field.Column, op.text, len(p.args)), nil
+}
+
+// parseIn разбирает список значений после in.
+func (p *parser) parseIn(field Field, negate bool) (string, error) {
+	if t := p.next(); t.kind != tokLParen {
+		return "", p.unexpected(t, `"(" после in`)
+	}
+	var placeholders []string
+	for {
+		value, err := p.parseValue(field)
+		if err != nil {
+			return "", err
+		}
+		p.args = append(p.args, value)
+		placeholders = append(placeholders, fmt.Sprintf("$%d", len(p.args)))
+
+		t := p.next()
+		if t.kind == tokRParen {
+			break
+		}
+		if t.kind != tokComma {
+			return "", p.unexpected(t, `"," или ")"`)
+		}
+	}
+	sqlOp := "IN"
+	if negate {
+		sqlOp = "NOT IN"
+	}
+	return fmt.Sprintf("%s %s (%s)", field.Column, sqlOp, strings.Join(placeholders, ", ")), nil
+}
+
+// parseValue разбирает значение и проверяет, что оно соответствует типу поля.
+func (p *parser) parseValue(field Field) (interface{}, error) {
+	t := p.next()
+	switch field.Type {
+	case Int:
+		if t.kind != tokNumber {
+			return nil, p.unexpected(t, "целое число")
+		}
+		n, err := strconv.ParseInt(t.text, 10, 32)
+		if err != nil {
+			return nil, &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("число %s вне допустимого диапазона", t.text)}
+		}
+		return field.convert(n), nil
+	default:
+		if t.kind != tokString {
+			return nil, p.unexpected(t, "строка в двойных кавычках")
+		}
+		return field.convert(t.value), nil
+	}
+}
+
+func (f Field) convert(value interface{}) interface{} {
+	if f.Value == nil {
+		return value
+	}
+	return f.Value(value)
+}
+
+// fieldNames возвращает список допустимых полей для сообщений об ошибках.
+func (p *parser) fieldNames() string {
+	names := make([]string, 0, len(p.fields))
+	for name := range p.fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
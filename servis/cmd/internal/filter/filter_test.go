@@ -0,0 +1,186 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testFields = Fields{
+	"surname": {Column: "surname", Type: String},
+	"age":     {Column: "age", Type: Int},
+	"role":    {Column: "role", Type: String, EqualityOnly: true},
+	"passport": {Column: "passport_hash", Type: Int, Value: func(v interface{}) interface{} {
+		return fmt.Sprintf("hash:%v", v)
+	}},
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		args     []interface{}
+		want     string
+		wantArgs []interface{}
+	}{
+		{
+			name:     "placeholders continue existing args",
+			input:    `surname="Ivanov"`,
+			args:     []interface{}{10, "x"},
+			want:     "surname = $3",
+			wantArgs: []interface{}{10, "x", "Ivanov"},
+		},
+		{
+			name:     "and binds tighter than or",
+			input:    `surname~"a" or surname~"b" and age>30`,
+			want:     "(surname ILIKE $1 OR (surname ILIKE $2 AND age > $3))",
+			wantArgs: []interface{}{"%a%", "%b%", int64(30)},
+		},
+		{
+			name:     "parentheses override precedence",
+			input:    `(surname~"a" or surname~"b") and age>=30`,
+			want:     "((surname ILIKE $1 OR surname ILIKE $2) AND age >= $3)",
+			wantArgs: []interface{}{"%a%", "%b%", int64(30)},
+		},
+		{
+			name:     "not applies to the nearest condition",
+			input:    `not surname="a" and age!=5`,
+			want:     "(NOT (surname = $1) AND age <> $2)",
+			wantArgs: []interface{}{"a", int64(5)},
+		},
+		{
+			name:     "not of a group",
+			input:    `NOT (age<1 OR age<=2)`,
+			want:     "NOT ((age < $1 OR age <= $2))",
+			wantArgs: []interface{}{int64(1), int64(2)},
+		},
+		{
+			name:     "keywords and field names ignore case",
+			input:    `SURNAME="x" And Age=1`,
+			want:     "(surname = $1 AND age = $2)",
+			wantArgs: []interface{}{"x", int64(1)},
+		},
+		{
+			name:     "in",
+			input:    `age in (1, 2, -3)`,
+			want:     "age IN ($1, $2, $3)",
+			wantArgs: []interface{}{int64(1), int64(2), int64(-3)},
+		},
+		{
+			name:     "not in on equality-only field",
+			input:    `role not in ("a", "b")`,
+			want:     "role NOT IN ($1, $2)",
+			wantArgs: []interface{}{"a", "b"},
+		},
+		{
+			name:     "value conversion",
+			input:    `passport=1234 or passport in (5)`,
+			want:     "(passport_hash = $1 OR passport_hash IN ($2))",
+			wantArgs: []interface{}{"hash:1234", "hash:5"},
+		},
+		{
+			name:     "like wildcards are escaped",
+			input:    `surname~"50%_a\\b" and surname!~"\""`,
+			want:     "(surname ILIKE $1 AND surname NOT ILIKE $2)",
+			wantArgs: []interface{}{`%50\%\_a\\b%`, `%"%`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, args, err := Parse(tt.input, testFields, tt.args)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("Parse(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("Parse(%q) args = %#v, want %#v", tt.input, args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantPos int
+		wantMsg string
+	}{
+		{"empty", "", 1, "пустое выражение"},
+		{"unknown field", `age=1 and name="x"`, 11, "неизвестное поле"},
+		{"like on int field", `age~"1"`, 4, "только для строковых полей"},
+		{"less on equality-only field", `role<"a"`, 5, "допустимы только =, != и in"},
+		{"less on converted field", `passport<1`, 9, "допустимы только =, != и in"},
+		{"string for int field", `age="1"`, 5, "целое число"},
+		{"number for string field", `surname=1`, 9, "строка в двойных кавычках"},
+		{"non-ASCII digits", `age=١٢`, 5, "недопустимый символ"},
+		{"int out of range", `age=99999999999`, 5, "вне допустимого диапазона"},
+		{"dangling and", `age=1 and`, 10, "имя поля"},
+		{"unbalanced parenthesis", `age=1)`, 6, "конец выражения"},
+		{"unclosed string", `surname="abc`, 9, "незакрытая строка"},
+		{"bad escape", `surname="a\n"`, 11, "экранирования"},
+		{"not without in", `age not 1`, 9, "in"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := []interface{}{1}
+			_, gotArgs, err := Parse(tt.input, testFields, args)
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("Parse(%q) error = %v, want *SyntaxError", tt.input, err)
+			}
+			if syntaxErr.Pos != tt.wantPos || !strings.Contains(syntaxErr.Msg, tt.wantMsg) {
+				t.Errorf("Parse(%q) error = %v, want position %d and message containing %q", tt.input, err, tt.wantPos, tt.wantMsg)
+			}
+			if !reflect.DeepEqual(gotArgs, args) {
+				t.Errorf("Parse(%q) args = %#v, want unchanged %#v", tt.input, gotArgs, args)
+			}
+		})
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	conditions := func(n int) string {
+		return strings.TrimSuffix(strings.Repeat("age=1 or ", n), " or ")
+	}
+	tests := []struct {
+		name    string
+		input   string
+		wantPos int // 0 — выражение допустимо
+		wantMsg string
+	}{
+		{"max depth of parentheses", strings.Repeat("(", MaxDepth) + "age=1" + strings.Repeat(")", MaxDepth), 0, ""},
+		{"too deep parentheses", strings.Repeat("(", MaxDepth+1) + "age=1" + strings.Repeat(")", MaxDepth+1), MaxDepth + 1, "вложенность"},
+		{"max depth of not", strings.Repeat("not ", MaxDepth) + "age=1", 0, ""},
+		{"too deep not", strings.Repeat("not ", MaxDepth+1) + "age=1", 4*MaxDepth + 1, "вложенность"},
+		{"max conditions", conditions(MaxConditions), 0, ""},
+		{"too many conditions", conditions(MaxConditions + 1), len("age=1 or ")*MaxConditions + 1, "сравнений"},
+		{"max length", `surname="` + strings.Repeat("a", MaxLength-len(`surname=""`)) + `"`, 0, ""},
+		{"too long", `surname="` + strings.Repeat("a", MaxLength-len(`surname=""`)+1) + `"`, MaxLength + 1, "длиннее"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := Parse(tt.input, testFields, nil)
+			if tt.wantPos == 0 {
+				if err != nil {
+					t.Fatalf("Parse: %v", err)
+				}
+				return
+			}
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("Parse error = %v, want *SyntaxError", err)
+			}
+			if syntaxErr.Pos != tt.wantPos || !strings.Contains(syntaxErr.Msg, tt.wantMsg) {
+				t.Errorf("Parse error = %v, want position %d and message containing %q", err, tt.wantPos, tt.wantMsg)
+			}
+		})
+	}
+}
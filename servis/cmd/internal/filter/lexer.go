@@ -0,0 +1,125 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Виды лексем выражения фильтра.
+const (
+	tokEOF    = iota
+	tokIdent  // имя поля или ключевое слово and, or, not, in
+	tokString // строка в двойных кавычках
+	tokNumber // целое число
+	tokOp     // оператор сравнения
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+// token лексема с позицией первого символа (в символах, начиная с 1).
+type token struct {
+	kind  int
+	text  string
+	pos   int
+	value string // значение строки без кавычек и экранирования
+}
+
+// describe возвращает описание лексемы для сообщений об ошибках.
+func (t token) describe() string {
+	switch t.kind {
+	case tokEOF:
+		return "конец выражения"
+	case tokString:
+		return fmt.Sprintf("строка %s", t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// operators операторы сравнения; двухсимвольные проверяются первыми.
+var operators = []string{"!=", "<=", ">=", "!~", "=", "<", ">", "~"}
+
+// isDigit сообщает, является ли r цифрой ASCII. Цифры других письменностей в числах
+// не допускаются: strconv.ParseInt их не разбирает.
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+// tokenize разбивает выражение на лексемы.
+func tokenize(input string) ([]token, error) {
+	runes := []rune(input)
+	var tokens []token
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		pos := i + 1
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case r == '(':
+			tokens = append(tokens, token{kind: tokLParen, text: "(", pos: pos})
+			i++
+			continue
+		case r == ')':
+			tokens = append(tokens, token{kind: tokRParen, text: ")", pos: pos})
+			i++
+			continue
+		case r == ',':
+			tokens = append(tokens, token{kind: tokComma, text: ",", pos: pos})
+			i++
+			continue
+		case r == '"':
+			var value strings.Builder
+			j := i + 1
+			for ; j < len(runes) && runes[j] != '"'; j++ {
+				if runes[j] == '\\' {
+					j++
+					if j == len(runes) || (runes[j] != '"' && runes[j] != '\\') {
+						return nil, &SyntaxError{Pos: j, Msg: `в строке допускаются только экранирования \" и \\`}
+					}
+				}
+				value.WriteRune(runes[j])
+			}
+			if j == len(runes) {
+				return nil, &SyntaxError{Pos: pos, Msg: "незакрытая строка"}
+			}
+			tokens = append(tokens, token{kind: tokString, text: string(runes[i : j+1]), pos: pos, value: value.String()})
+			i = j + 1
+			continue
+		case isDigit(r) || (r == '-' && i+1 < len(runes) && isDigit(runes[i+1])):
+			j := i + 1
+			for j < len(runes) && isDigit(runes[j]) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: string(runes[i:j]), pos: pos})
+			i = j
+			continue
+		case r == '_' || unicode.IsLetter(r):
+			j := i + 1
+			for j < len(runes) && (runes[j] == '_' || unicode.IsLetter(runes[j]) || isDigit(runes[j])) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: string(runes[i:j]), pos: pos})
+			i = j
+			continue
+		}
+
+		matched := false
+		for _, op := range operators {
+			if strings.HasPrefix(string(runes[i:]), op) {
+				tokens = append(tokens, token{kind: tokOp, text: op, pos: pos})
+				i += len([]rune(op))
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, &SyntaxError{Pos: pos, Msg: fmt.Sprintf("недопустимый символ %q", r)}
+		}
+	}
+
+	return append(tokens, token{kind: tokEOF, pos: len(runes) + 1}), nil
+}
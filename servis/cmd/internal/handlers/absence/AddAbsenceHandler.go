@@ -0,0 +1,168 @@
+package absence
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"main.go/cmd/internal/audit"
+	"main.go/cmd/internal/storage"
+	model "main.go/tracker_model"
+)
+
+var (
+	// errAbsenceOverlap возвращается, если период пересекается с другим несогласованным или согласованным отсутствием.
+	errAbsenceOverlap = errors.New("период пересекается с другим отсутствием пользователя")
+	// errNotSubmitted возвращается при рассмотрении отсутствия, которое не ожидает решения.
+	errNotSubmitted = errors.New("отсутствие не ожидает согласования")
+)
+
+// absenceTypes допустимые типы отсутствий.
+var absenceTypes = []string{model.AbsenceVacation, model.AbsenceSick, model.AbsenceBusinessTrip, model.AbsenceOther}
+
+// AbsenceInput представляет данные заявки на отсутствие.
+type AbsenceInput struct {
+	UserID    int    `json:"user_id"`    // Идентификатор пользователя
+	Type      string `json:"type"`       // Тип: vacation, sick, business_trip, other
+	StartDate string `json:"start_date"` // Первый день отсутствия в формате YYYY-MM-DD
+	EndDate   string `json:"end_date"`   // Последний день отсутствия в формате YYYY-MM-DD, по умолчанию равен start_date
+	HalfDay   bool   `json:"half_day"`   // Отсутствие на половину дня, только для одного дня
+	Comment   string `json:"comment"`    // Комментарий сотрудника
+}
+
+// AddAbsenceHandler обрабатывает заявки на отсутствие. Заявка создается в статусе submitted
+// и учитывается в сводке, отчетах и при запуске задач после согласования руководителем.
+// @Summary Заявка на отсутствие
+// @Description Создает заявку на отпуск, больничный, командировку или другое отсутствие на период дат.
+// @Tags Absence
+// @Accept json
+// @Produce json
+// @Param absence body AbsenceInput true "Отсутствие"
+// @Success 201 {object} model.Absence "Созданная заявка"
+// @Failure 400 {string} string "Неверный формат ввода"
+// @Failure 404 {string} string "Пользователь не найден"
+// @Failure 409 {string} string "Период пересекается с другим отсутствием"
+// @Failure 500 {string} string "Ошибка при создании заявки"
+// @Router /api/v1/absences [post]
+func AddAbsenceHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var input AbsenceInput
+
+		err := json.NewDecoder(r.Body).Decode(&input)
+		if err != nil {
+			log.Error("Неверный формат ввода", slog.String("error", err.Error()))
+			http.Error(w, "Неверный формат ввода", http.StatusBadRequest)
+			return
+		}
+
+		if !slices.Contains(absenceTypes, input.Type) {
+			log.Warn("Неверный тип отсутствия", slog.String("type", input.Type))
+			http.Error(w, "Invalid type, expected one of: vacation, sick, business_trip, other", http.StatusBadRequest)
+			return
+		}
+
+		startDate, err := time.Parse("2006-01-02", input.StartDate)
+		if err != nil {
+			log.Error("Неверный формат start_date", slog.String("error", err.Error()))
+			http.Error(w, "Invalid start_date format", http.StatusBadRequest)
+			return
+		}
+		endDate := startDate
+		if input.EndDate != "" {
+			endDate, err = time.Parse("2006-01-02", input.EndDate)
+			if err != nil {
+				log.Error("Неверный формат end_date", slog.String("error", err.Error()))
+				http.Error(w, "Invalid end_date format", http.StatusBadRequest)
+				return
+			}
+		}
+		if endDate.Before(startDate) {
+			log.Warn("end_date раньше start_date", slog.Any("absence", input))
+			http.Error(w, "end_date must not be before start_date", http.StatusBadRequest)
+			return
+		}
+		if input.HalfDay && !endDate.Equal(startDate) {
+			log.Warn("Половина дня указана для нескольких дней", slog.Any("absence", input))
+			http.Error(w, "half_day is allowed only for a single day", http.StatusBadRequest)
+			return
+		}
+
+		absence, err := addAbsence(r.Context(), db, input, startDate, endDate)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			log.Warn("Пользователь не найден", slog.Int("user_id", input.UserID))
+			http.Error(w, "Пользователь не найден", http.StatusNotFound)
+			return
+		case errors.Is(err, errAbsenceOverlap):
+			log.Warn("Отсутствие не может быть создано", slog.Int("user_id", input.UserID), slog.String("error", err.Error()))
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			log.Error("Ошибка при создании заявки на отсутствие", slog.Int("user_id", input.UserID), slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Ошибка при создании заявки на отсутствие: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		log.Info("Заявка на отсутствие создана", slog.Int("absence_id", absence.ID), slog.Int("user_id", absence.UserID), slog.String("type", absence.Type), slog.Float64("days", absence.Days))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(absence)
+	}
+}
+
+// addAbsence в одной транзакции проверяет, что период не пересекается с другими
+// не отклоненными отсутствиями пользователя, и сохраняет заявку.
+// Если пользователь не найден, возвращается ошибка, оборачивающая sql.ErrNoRows.
+func addAbsence(ctx context.Context, db *sql.DB, input AbsenceInput, startDate, endDate time.Time) (model.Absence, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return model.Absence{}, fmt.Errorf("ошибка при начале транзакции: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Блокировка пользователя, чтобы одновременные заявки проверялись на пересечение последовательно
+	var id int
+	if err := tx.QueryRow(`SELECT id FROM users WHERE id = $1 FOR UPDATE`, input.UserID).Scan(&id); err != nil {
+		return model.Absence{}, fmt.Errorf("ошибка при блокировке пользователя: %w", err)
+	}
+
+	var overlap bool
+	err = tx.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM absences
+			WHERE user_id = $1 AND status <> $2 AND start_date <= $4 AND end_date >= $3
+		)
+	`, input.UserID, model.StatusRejected, startDate, endDate).Scan(&overlap)
+	if err != nil {
+		return model.Absence{}, fmt.Errorf("ошибка при проверке пересечения отсутствий: %v", err)
+	}
+	if overlap {
+		return model.Absence{}, errAbsenceOverlap
+	}
+
+	absence, err := storage.ScanAbsence(tx.QueryRow(`
+		INSERT INTO absences (user_id, type, start_date, end_date, half_day, status, comment)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING `+storage.AbsenceColumns,
+		input.UserID, input.Type, startDate, endDate, input.HalfDay, model.StatusSubmitted, input.Comment))
+	if err != nil {
+		return absence, fmt.Errorf("ошибка при сохранении отсутствия: %v", err)
+	}
+
+	err = audit.Record(ctx, tx, audit.Change{Action: audit.ActionCreated, Entity: audit.EntityAbsence, EntityID: absence.ID, UserID: absence.UserID, After: absence})
+	if err != nil {
+		return absence, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return absence, fmt.Errorf("ошибка при фиксации транзакции: %v", err)
+	}
+	return absence, nil
+}
@@ -0,0 +1,84 @@
+package absence
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"main.go/cmd/internal/storage"
+	model "main.go/tracker_model"
+)
+
+// GetAbsencesHandler обрабатывает запросы на получение списка отсутствий с фильтрацией
+// по пользователю и статусу, например заявок, ожидающих согласования.
+// @Summary Список отсутствий
+// @Description Возвращает отсутствия, отсортированные по дате начала от новых к старым.
+// @Tags Absence
+// @Produce json
+// @Param user_id query int false "Идентификатор пользователя"
+// @Param status query string false "Статус (submitted, approved, rejected)"
+// @Success 200 {array} model.Absence "Список отсутствий"
+// @Failure 400 {string} string "Неверные параметры запроса"
+// @Failure 500 {string} string "Ошибка при выполнении запроса к базе данных"
+// @Router /api/v1/absences [get]
+func GetAbsencesHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userIDStr := r.URL.Query().Get("user_id")
+		status := r.URL.Query().Get("status")
+
+		query := "SELECT " + storage.AbsenceColumns + " FROM absences WHERE 1=1"
+		args := []interface{}{}
+		argID := 1
+
+		if userIDStr != "" {
+			userID, err := strconv.Atoi(userIDStr)
+			if err != nil {
+				log.Error("Неверный формат user_id", slog.String("error", err.Error()))
+				http.Error(w, "Invalid user_id", http.StatusBadRequest)
+				return
+			}
+			query += fmt.Sprintf(" AND user_id = $%d", argID)
+			args = append(args, userID)
+			argID++
+		}
+
+		if status != "" {
+			query += fmt.Sprintf(" AND status = $%d", argID)
+			args = append(args, status)
+			argID++
+		}
+
+		query += " ORDER BY start_date DESC, id DESC"
+
+		rows, err := db.Query(query, args...)
+		if err != nil {
+			log.Error("Ошибка выполнения запроса к базе данных", slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Database query failed: %v", err), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		absences := []model.Absence{}
+		for rows.Next() {
+			absence, err := storage.ScanAbsence(rows)
+			if err != nil {
+				log.Error("Ошибка сканирования строки результата", slog.String("error", err.Error()))
+				http.Error(w, fmt.Sprintf("Failed to scan row: %v", err), http.StatusInternalServerError)
+				return
+			}
+			absences = append(absences, absence)
+		}
+
+		if err = rows.Err(); err != nil {
+			log.Error("Ошибка итерации по строкам результата", slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Row iteration error: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(absences)
+	}
+}
@@ -0,0 +1,191 @@
+package absence
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"main.go/cmd/internal/audit"
+	"main.go/cmd/internal/handlers/util"
+	"main.go/cmd/internal/storage"
+	model "main.go/tracker_model"
+)
+
+// errSelfReview возвращается, если руководитель пытается рассмотреть собственную заявку.
+var errSelfReview = errors.New("руководитель не может рассматривать собственную заявку")
+
+// ReviewRequest представляет решение руководителя по заявке на отсутствие.
+type ReviewRequest struct {
+	Comment string `json:"comment"` // Комментарий руководителя, обязателен при отклонении
+}
+
+// ApproveAbsenceHandler обрабатывает запросы руководителя на согласование отсутствия.
+// @Summary Согласование отсутствия
+// @Description Согласует заявку на отсутствие; руководитель передается в заголовке X-User-ID.
+// @Tags Absence
+// @Accept json
+// @Produce json
+// @Param X-User-ID header int true "Идентификатор руководителя"
+// @Param id path int true "Идентификатор отсутствия"
+// @Param request body ReviewRequest false "Комментарий"
+// @Success 200 {object} model.Absence "Согласованное отсутствие"
+// @Failure 400 {string} string "Неверный формат ввода"
+// @Failure 403 {string} string "Недостаточно прав"
+// @Failure 404 {string} string "Отсутствие не найдено"
+// @Failure 409 {string} string "Заявка не ожидает согласования"
+// @Failure 500 {string} string "Ошибка при согласовании отсутствия"
+// @Router /api/v1/absences/{id}/approve [post]
+func ApproveAbsenceHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
+	return reviewAbsenceHandler(db, log, model.StatusApproved)
+}
+
+// RejectAbsenceHandler обрабатывает запросы руководителя на отклонение отсутствия.
+// @Summary Отклонение отсутствия
+// @Description Отклоняет заявку на отсутствие с обязательным комментарием; руководитель передается в заголовке X-User-ID.
+// @Tags Absence
+// @Accept json
+// @Produce json
+// @Param X-User-ID header int true "Идентификатор руководителя"
+// @Param id path int true "Идентификатор отсутствия"
+// @Param request body ReviewRequest true "Комментарий"
+// @Success 200 {object} model.Absence "Отклоненное отсутствие"
+// @Failure 400 {string} string "Неверный формат ввода"
+// @Failure 403 {string} string "Недостаточно прав"
+// @Failure 404 {string} string "Отсутствие не найдено"
+// @Failure 409 {string} string "Заявка не ожидает согласования"
+// @Failure 500 {string} string "Ошибка при отклонении отсутствия"
+// @Router /api/v1/absences/{id}/reject [post]
+func RejectAbsenceHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
+	return reviewAbsenceHandler(db, log, model.StatusRejected)
+}
+
+// reviewAbsenceHandler общая реализация согласования и отклонения отсутствия.
+func reviewAbsenceHandler(db *sql.DB, log *slog.Logger, decision string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		absenceID, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			log.Error("Неверный идентификатор отсутствия", slog.String("id", r.PathValue("id")), slog.String("error", err.Error()))
+			http.Error(w, "Неверный идентификатор отсутствия", http.StatusBadRequest)
+			return
+		}
+
+		managerID, err := util.ActorID(r)
+		if err != nil {
+			log.Warn("Не указан руководитель", slog.String("error", err.Error()))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var req ReviewRequest
+		if r.ContentLength != 0 {
+			err = json.NewDecoder(r.Body).Decode(&req)
+			if err != nil {
+				log.Error("Неверный формат ввода", slog.String("error", err.Error()))
+				http.Error(w, "Неверный формат ввода", http.StatusBadRequest)
+				return
+			}
+		}
+
+		if decision == model.StatusRejected && strings.TrimSpace(req.Comment) == "" {
+			log.Warn("Не указан комментарий к отклонению", slog.Int("absence_id", absenceID))
+			http.Error(w, "Комментарий обязателен при отклонении заявки", http.StatusBadRequest)
+			return
+		}
+
+		var role string
+		err = db.QueryRow(`SELECT role FROM users WHERE id = $1`, managerID).Scan(&role)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Error("Ошибка при получении роли пользователя", slog.Int("manager_id", managerID), slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Ошибка при получении роли пользователя: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if role != model.RoleManager {
+			log.Warn("Пользователь не является руководителем", slog.Int("manager_id", managerID))
+			http.Error(w, "Недостаточно прав", http.StatusForbidden)
+			return
+		}
+
+		absence, err := reviewAbsence(r.Context(), db, absenceID, managerID, decision, req.Comment)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			log.Warn("Отсутствие не найдено", slog.Int("absence_id", absenceID))
+			http.Error(w, "Отсутствие не найдено", http.StatusNotFound)
+			return
+		case errors.Is(err, errSelfReview):
+			log.Warn("Руководитель не может согласовать собственную заявку", slog.Int("absence_id", absenceID))
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		case errors.Is(err, errNotSubmitted):
+			log.Warn("Заявка не ожидает согласования", slog.Int("absence_id", absenceID))
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			log.Error("Ошибка при рассмотрении отсутствия", slog.Int("absence_id", absenceID), slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Ошибка при рассмотрении отсутствия: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		log.Info("Решение по отсутствию принято", slog.Int("absence_id", absence.ID), slog.String("status", absence.Status), slog.Int("manager_id", managerID))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(absence)
+	}
+}
+
+// reviewAbsence в одной транзакции фиксирует решение руководителя по заявке на отсутствие.
+// Решение (approved или rejected) записывается в журнал аудита как действие.
+func reviewAbsence(ctx context.Context, db *sql.DB, absenceID, managerID int, decision, comment string) (model.Absence, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return model.Absence{}, fmt.Errorf("ошибка при начале транзакции: %v", err)
+	}
+	defer tx.Rollback()
+
+	absence, err := storage.ScanAbsence(tx.QueryRow(`
+		SELECT `+storage.AbsenceColumns+` FROM absences WHERE id = $1 FOR UPDATE
+	`, absenceID))
+	if err != nil {
+		return absence, fmt.Errorf("ошибка при получении отсутствия: %w", err)
+	}
+	if absence.UserID == managerID {
+		return absence, errSelfReview
+	}
+	if absence.Status != model.StatusSubmitted {
+		return absence, errNotSubmitted
+	}
+
+	before := absence
+	// Комментарий руководителя дописывается к комментарию сотрудника
+	if comment != "" && absence.Comment != "" {
+		comment = absence.Comment + "\n" + comment
+	} else if comment == "" {
+		comment = absence.Comment
+	}
+
+	absence, err = storage.ScanAbsence(tx.QueryRow(`
+		UPDATE absences
+		SET status = $1, comment = $2, manager_id = $3, decided_at = $4
+		WHERE id = $5
+		RETURNING `+storage.AbsenceColumns,
+		decision, comment, managerID, time.Now(), absenceID))
+	if err != nil {
+		return absence, fmt.Errorf("ошибка при обновлении отсутствия: %v", err)
+	}
+
+	err = audit.Record(ctx, tx, audit.Change{Action: decision, Entity: audit.EntityAbsence, EntityID: absence.ID, UserID: absence.UserID, Before: before, After: absence})
+	if err != nil {
+		return absence, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return absence, fmt.Errorf("ошибка при фиксации транзакции: %v", err)
+	}
+	return absence, nil
+}
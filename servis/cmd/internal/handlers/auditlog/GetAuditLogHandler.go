@@ -0,0 +1,198 @@
+package auditlog
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"main.go/cmd/internal/audit"
+	"main.go/cmd/internal/handlers/util"
+	model "main.go/tracker_model"
+)
+
+const (
+	// defaultLimit количество записей журнала на странице по умолчанию.
+	defaultLimit = 50
+	// maxLimit наибольшее количество записей журнала на странице.
+	maxLimit = 500
+)
+
+// GetAuditLogHandler обрабатывает запросы на просмотр журнала аудита. Записи возвращаются
+// от новых к старым страницами; следующая страница запрашивается с before_id из ответа.
+// Журнал доступен только руководителям.
+// @Summary Журнал аудита
+// @Description Возвращает изменения сущностей с инициатором, идентификатором запроса и измененными полями.
+// @Tags Audit
+// @Produce json
+// @Param X-User-ID header int true "Идентификатор руководителя"
+// @Param entity query string false "Тип сущности: user, schedule, session, task, project, rate, invoice, webhook, absence, timesheet"
+// @Param entity_id query int false "Идентификатор сущности"
+// @Param actor_id query int false "Инициатор изменений"
+// @Param user_id query int false "Пользователь, к чьим данным относятся изменения"
+// @Param action query string false "Действие, например session.ended"
+// @Param request_id query string false "Идентификатор запроса (X-Request-ID)"
+// @Param from query string false "Начало периода: RFC 3339 или YYYY-MM-DD"
+// @Param to query string false "Окончание периода: RFC 3339 или YYYY-MM-DD включительно"
+// @Param before_id query int false "Вернуть записи с id меньше указанного"
+// @Param limit query int false "Количество записей, по умолчанию 50, не более 500"
+// @Success 200 {object} model.AuditPage "Записи журнала"
+// @Failure 400 {string} string "Неверные параметры запроса"
+// @Failure 403 {string} string "Недостаточно прав"
+// @Failure 500 {string} string "Ошибка при выполнении запроса к базе данных"
+// @Router /api/v1/audit [get]
+func GetAuditLogHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		actorID, err := util.ActorID(r)
+		if err != nil {
+			log.Warn("Не указан пользователь", slog.String("error", err.Error()))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var role string
+		err = db.QueryRow(`SELECT role FROM users WHERE id = $1`, actorID).Scan(&role)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Error("Ошибка при получении роли пользователя", slog.Int("actor_id", actorID), slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Ошибка при получении роли пользователя: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if role != model.RoleManager {
+			log.Warn("Пользователь не является руководителем", slog.Int("actor_id", actorID))
+			http.Error(w, "Недостаточно прав", http.StatusForbidden)
+			return
+		}
+
+		query := r.URL.Query()
+		limit := defaultLimit
+		if limitStr := query.Get("limit"); limitStr != "" {
+			limit, err = strconv.Atoi(limitStr)
+			if err != nil || limit < 1 || limit > maxLimit {
+				log.Warn("Неверное значение limit", slog.String("limit", limitStr))
+				http.Error(w, fmt.Sprintf("Invalid limit, expected 1-%d", maxLimit), http.StatusBadRequest)
+				return
+			}
+		}
+
+		where, args, err := auditFilters(query)
+		if err != nil {
+			log.Warn("Неверные параметры запроса", slog.String("error", err.Error()))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Лишняя запись показывает, что есть следующая страница
+		args = append(args, limit+1)
+		rows, err := db.Query(`
+			SELECT `+audit.Columns+`
+			FROM audit_log
+			WHERE `+where+`
+			ORDER BY id DESC
+			LIMIT $`+strconv.Itoa(len(args)), args...)
+		if err != nil {
+			log.Error("Ошибка выполнения запроса к базе данных", slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Database query failed: %v", err), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		page := model.AuditPage{Items: []model.AuditEntry{}}
+		for rows.Next() {
+			entry, err := audit.Scan(rows)
+			if err != nil {
+				log.Error("Ошибка сканирования строки результата", slog.String("error", err.Error()))
+				http.Error(w, fmt.Sprintf("Failed to scan row: %v", err), http.StatusInternalServerError)
+				return
+			}
+			page.Items = append(page.Items, entry)
+		}
+
+		if err = rows.Err(); err != nil {
+			log.Error("Ошибка итерации по строкам результата", slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Row iteration error: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		if len(page.Items) > limit {
+			page.Items = page.Items[:limit]
+			page.NextBeforeID = page.Items[limit-1].ID
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(page)
+	}
+}
+
+// auditFilters строит условие WHERE по параметрам запроса журнала аудита.
+func auditFilters(query url.Values) (string, []interface{}, error) {
+	conditions := []string{"TRUE"}
+	var args []interface{}
+	add := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	for _, name := range []string{"entity", "action", "request_id"} {
+		if value := query.Get(name); value != "" {
+			add(name+" = $%d", value)
+		}
+	}
+	for _, name := range []string{"entity_id", "actor_id", "user_id", "before_id"} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", nil, fmt.Errorf("Invalid %s", name)
+		}
+		if name == "before_id" {
+			add("id < $%d", id)
+		} else {
+			add(name+" = $%d", id)
+		}
+	}
+
+	if value := query.Get("from"); value != "" {
+		from, _, err := parseBound(value, false)
+		if err != nil {
+			return "", nil, fmt.Errorf("Invalid from: %v", err)
+		}
+		add("created_at >= $%d", from)
+	}
+	if value := query.Get("to"); value != "" {
+		to, inclusive, err := parseBound(value, true)
+		if err != nil {
+			return "", nil, fmt.Errorf("Invalid to: %v", err)
+		}
+		if inclusive {
+			add("created_at <= $%d", to)
+		} else {
+			add("created_at < $%d", to)
+		}
+	}
+	return strings.Join(conditions, " AND "), args, nil
+}
+
+// parseBound разбирает границу периода в формате RFC 3339 или YYYY-MM-DD (UTC).
+// Время окончания в RFC 3339 входит в период, и inclusive равен true. Дата окончания
+// включается целиком, поэтому для end возвращается начало следующего дня, не входящее в период.
+func parseBound(s string, end bool) (t time.Time, inclusive bool, err error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, true, nil
+	}
+	t, err = time.Parse("2006-01-02", s)
+	if err != nil {
+		return t, false, errors.New("expected RFC 3339 or YYYY-MM-DD")
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, false, nil
+}
@@ -0,0 +1,129 @@
+package billing
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/lib/pq"
+	"main.go/cmd/internal/audit"
+	model "main.go/tracker_model"
+)
+
+// errUnknownTasks возвращается, если среди задач проекта есть несуществующие.
+var errUnknownTasks = errors.New("указаны несуществующие задачи")
+
+// ProjectInput представляет данные запроса на создание проекта.
+type ProjectInput struct {
+	Name    string `json:"name"`     // Название проекта
+	TaskIDs []int  `json:"task_ids"` // Задачи, относящиеся к проекту
+}
+
+// AddProjectHandler обрабатывает запросы на создание проекта и привязку к нему задач.
+// Задача относится не более чем к одному проекту, повторная привязка переносит ее в новый проект.
+// @Summary Создание проекта
+// @Description Создает проект и привязывает к нему задачи для учета ставок уровня проекта.
+// @Tags Billing
+// @Accept json
+// @Produce json
+// @Param project body ProjectInput true "Проект"
+// @Success 201 {object} model.Project "Созданный проект"
+// @Failure 400 {string} string "Неверный формат ввода"
+// @Failure 500 {string} string "Ошибка при создании проекта"
+// @Router /api/v1/projects [post]
+func AddProjectHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var input ProjectInput
+
+		err := json.NewDecoder(r.Body).Decode(&input)
+		if err != nil {
+			log.Error("Неверный формат ввода", slog.String("error", err.Error()))
+			http.Error(w, "Неверный формат ввода", http.StatusBadRequest)
+			return
+		}
+
+		input.Name = strings.TrimSpace(input.Name)
+		if input.Name == "" {
+			log.Warn("Не указано название проекта")
+			http.Error(w, "Необходимо указать name", http.StatusBadRequest)
+			return
+		}
+
+		project, err := addProject(r.Context(), db, input)
+		if errors.Is(err, errUnknownTasks) {
+			log.Warn("Проект не может быть создан", slog.Any("task_ids", input.TaskIDs), slog.String("error", err.Error()))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Error("Ошибка при создании проекта", slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Ошибка при создании проекта: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		log.Info("Проект создан", slog.Int("project_id", project.ID), slog.String("name", project.Name), slog.Any("task_ids", project.TaskIDs))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(project)
+	}
+}
+
+// addProject в одной транзакции создает проект и привязывает к нему задачи.
+func addProject(ctx context.Context, db *sql.DB, input ProjectInput) (model.Project, error) {
+	project := model.Project{Name: input.Name, TaskIDs: input.TaskIDs}
+	if project.TaskIDs == nil {
+		project.TaskIDs = []int{}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return project, fmt.Errorf("ошибка при начале транзакции: %v", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`INSERT INTO projects (name) VALUES ($1) RETURNING id`, project.Name).Scan(&project.ID)
+	if err != nil {
+		return project, fmt.Errorf("ошибка при сохранении проекта: %v", err)
+	}
+
+	if len(project.TaskIDs) > 0 {
+		result, err := tx.Exec(`
+			UPDATE tasks SET project_id = $1 WHERE id_task = ANY($2)
+		`, project.ID, pq.Array(project.TaskIDs))
+		if err != nil {
+			return project, fmt.Errorf("ошибка при привязке задач к проекту: %v", err)
+		}
+		updated, err := result.RowsAffected()
+		if err != nil {
+			return project, fmt.Errorf("ошибка при привязке задач к проекту: %v", err)
+		}
+		if int(updated) != countDistinct(project.TaskIDs) {
+			return project, errUnknownTasks
+		}
+	}
+
+	err = audit.Record(ctx, tx, audit.Change{Action: audit.ActionCreated, Entity: audit.EntityProject, EntityID: project.ID, After: project})
+	if err != nil {
+		return project, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return project, fmt.Errorf("ошибка при фиксации транзакции: %v", err)
+	}
+	return project, nil
+}
+
+// countDistinct возвращает количество различных значений в ids.
+func countDistinct(ids []int) int {
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		seen[id] = true
+	}
+	return len(seen)
+}
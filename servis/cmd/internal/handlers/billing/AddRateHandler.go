@@ -0,0 +1,128 @@
+package billing
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"main.go/cmd/internal/audit"
+	model "main.go/tracker_model"
+)
+
+var (
+	// amountPattern формат денежной суммы: неотрицательное число с точностью до копеек.
+	amountPattern = regexp.MustCompile(`^\d{1,10}(\.\d{1,2})?$`)
+	// currencyPattern код валюты ISO 4217, например RUB.
+	currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+)
+
+// RateInput представляет данные запроса на создание ставки.
+type RateInput struct {
+	UserID        int    `json:"user_id"`        // Пользователь, 0 — любой
+	IDTask        int    `json:"id_task"`        // Задача, 0 — любая
+	ProjectID     int    `json:"project_id"`     // Проект, 0 — любой
+	HourlyRate    string `json:"hourly_rate"`    // Ставка за час, например "1500.00"
+	Currency      string `json:"currency"`       // Код валюты ISO 4217
+	EffectiveFrom string `json:"effective_from"` // Дата начала действия в формате YYYY-MM-DD
+}
+
+// AddRateHandler обрабатывает запросы на создание почасовой ставки.
+// Ставки не изменяются: новая ставка с более поздней датой начала действия заменяет прежнюю,
+// поэтому ранее выставленные счета можно воспроизвести.
+// @Summary Создание ставки
+// @Description Создает почасовую ставку уровня пользователя, задачи или проекта с датой начала действия.
+// @Tags Billing
+// @Accept json
+// @Produce json
+// @Param rate body RateInput true "Ставка"
+// @Success 201 {object} model.Rate "Созданная ставка"
+// @Failure 400 {string} string "Неверный формат ввода"
+// @Failure 500 {string} string "Ошибка при создании ставки"
+// @Router /api/v1/rates [post]
+func AddRateHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var input RateInput
+
+		err := json.NewDecoder(r.Body).Decode(&input)
+		if err != nil {
+			log.Error("Неверный формат ввода", slog.String("error", err.Error()))
+			http.Error(w, "Неверный формат ввода", http.StatusBadRequest)
+			return
+		}
+
+		effectiveFrom, err := validateRateInput(&input)
+		if err != nil {
+			log.Warn("Неверные параметры ставки", slog.Any("input", input), slog.String("error", err.Error()))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		rate, err := addRate(r.Context(), db, input, effectiveFrom)
+		if err != nil {
+			log.Error("Ошибка при создании ставки", slog.Any("input", input), slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Ошибка при создании ставки: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		log.Info("Ставка создана", slog.Int("rate_id", rate.ID), slog.String("hourly_rate", rate.HourlyRate), slog.String("currency", rate.Currency))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(rate)
+	}
+}
+
+// addRate в одной транзакции сохраняет ставку и запись о ней в журнале аудита.
+func addRate(ctx context.Context, db *sql.DB, input RateInput, effectiveFrom time.Time) (model.Rate, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return model.Rate{}, fmt.Errorf("ошибка при начале транзакции: %v", err)
+	}
+	defer tx.Rollback()
+
+	rate, err := scanRate(tx.QueryRow(`
+		INSERT INTO rates (user_id, id_task, project_id, hourly_rate, currency, effective_from)
+		VALUES (NULLIF($1, 0), NULLIF($2, 0), NULLIF($3, 0), $4, $5, $6)
+		RETURNING `+rateColumns,
+		input.UserID, input.IDTask, input.ProjectID, input.HourlyRate, input.Currency, effectiveFrom))
+	if err != nil {
+		return rate, err
+	}
+
+	err = audit.Record(ctx, tx, audit.Change{Action: audit.ActionCreated, Entity: audit.EntityRate, EntityID: rate.ID, UserID: rate.UserID, After: rate})
+	if err != nil {
+		return rate, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return rate, fmt.Errorf("ошибка при фиксации транзакции: %v", err)
+	}
+	return rate, nil
+}
+
+// validateRateInput проверяет параметры ставки, приводит код валюты к верхнему регистру
+// и возвращает дату начала действия.
+func validateRateInput(input *RateInput) (time.Time, error) {
+	if input.UserID < 0 || input.IDTask < 0 || input.ProjectID < 0 {
+		return time.Time{}, errors.New("идентификаторы не могут быть отрицательными")
+	}
+	if !amountPattern.MatchString(input.HourlyRate) {
+		return time.Time{}, errors.New("hourly_rate должна быть неотрицательным числом с точностью до копеек, например \"1500.00\"")
+	}
+	input.Currency = strings.ToUpper(strings.TrimSpace(input.Currency))
+	if !currencyPattern.MatchString(input.Currency) {
+		return time.Time{}, errors.New("currency должна быть кодом валюты ISO 4217, например RUB")
+	}
+	effectiveFrom, err := time.Parse("2006-01-02", input.EffectiveFrom)
+	if err != nil {
+		return time.Time{}, errors.New("effective_from должна быть датой в формате YYYY-MM-DD")
+	}
+	return effectiveFrom, nil
+}
@@ -0,0 +1,267 @@
+package billing
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/lib/pq"
+	"main.go/cmd/internal/audit"
+	"main.go/cmd/internal/rounding"
+	"main.go/cmd/internal/storage/cache"
+	model "main.go/tracker_model"
+)
+
+// InvoiceRequest представляет данные запроса на выставление счетов за период.
+type InvoiceRequest struct {
+	StartDate string `json:"start_date"` // Дата начала периода в формате YYYY-MM-DD
+	EndDate   string `json:"end_date"`   // Дата окончания периода в формате YYYY-MM-DD включительно
+	UserID    int    `json:"user_id"`    // Пользователь, 0 — все
+	ProjectID int    `json:"project_id"` // Проект, 0 — все
+}
+
+// InvoiceResult результат выставления счетов: по одному счету на каждую валюту
+// и сессии, для которых не нашлось ставки и которые остались невыставленными.
+type InvoiceResult struct {
+	Invoices          []model.Invoice `json:"invoices"`
+	UnratedSessionIDs []int           `json:"unrated_session_ids"`
+}
+
+// billableSession завершенная оплачиваемая сессия с действующей для нее ставкой.
+type billableSession struct {
+	line     model.InvoiceLine
+	seconds  int64
+	currency string
+	rated    bool
+}
+
+// CreateInvoiceHandler обрабатывает запросы на выставление счетов за период.
+// В счет попадают завершенные оплачиваемые сессии, еще не вошедшие в другие счета, начатые
+// в период по часовому поясу пользователя. Длительность сессий округляется по политике policy,
+// сумма считается по наиболее конкретной ставке (задача, затем проект, затем пользователь),
+// действующей на дату начала сессии. Выставленные сессии блокируются от изменений.
+// @Summary Выставление счетов
+// @Description Формирует счета со строками по сессиям за период, по одному счету на валюту.
+// @Tags Billing
+// @Accept json
+// @Produce json
+// @Param request body InvoiceRequest true "Период и фильтры"
+// @Success 201 {object} InvoiceResult "Выставленные счета"
+// @Failure 400 {string} string "Неверный формат ввода"
+// @Failure 500 {string} string "Ошибка при выставлении счетов"
+// @Router /api/v1/invoices [post]
+func CreateInvoiceHandler(db *sql.DB, log *slog.Logger, policy rounding.Policy) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req InvoiceRequest
+
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			log.Error("Неверный формат ввода", slog.String("error", err.Error()))
+			http.Error(w, "Неверный формат ввода", http.StatusBadRequest)
+			return
+		}
+
+		startDate, err := time.Parse("2006-01-02", req.StartDate)
+		if err != nil {
+			log.Error("Неверный формат start_date", slog.String("error", err.Error()))
+			http.Error(w, "Invalid start_date format", http.StatusBadRequest)
+			return
+		}
+		endDate, err := time.Parse("2006-01-02", req.EndDate)
+		if err != nil {
+			log.Error("Неверный формат end_date", slog.String("error", err.Error()))
+			http.Error(w, "Invalid end_date format", http.StatusBadRequest)
+			return
+		}
+		if endDate.Before(startDate) {
+			log.Warn("end_date раньше start_date", slog.Any("request", req))
+			http.Error(w, "end_date must not be before start_date", http.StatusBadRequest)
+			return
+		}
+
+		result, err := createInvoices(r.Context(), db, policy, req, startDate, endDate)
+		if err != nil {
+			log.Error("Ошибка при выставлении счетов", slog.Any("request", req), slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Ошибка при выставлении счетов: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		// Отражение выставленных сессий в кэше
+		cache.UserCacheMutex.Lock()
+		for _, invoice := range result.Invoices {
+			for _, line := range invoice.Lines {
+				user, exists := cache.UserCache[line.UserID]
+				if !exists {
+					continue
+				}
+				for i := range user.UserTask {
+					if user.UserTask[i].IDSession == line.SessionID {
+						user.UserTask[i].InvoiceID = invoice.ID
+						break
+					}
+				}
+				cache.UserCache[line.UserID] = user
+			}
+		}
+		cache.UserCacheMutex.Unlock()
+
+		for _, invoice := range result.Invoices {
+			log.Info("Счет выставлен", slog.Int("invoice_id", invoice.ID), slog.String("currency", invoice.Currency), slog.String("total", invoice.Total), slog.Int("lines", len(invoice.Lines)))
+		}
+		if len(result.UnratedSessionIDs) > 0 {
+			log.Warn("Для части сессий не найдена ставка, они не выставлены", slog.Any("session_ids", result.UnratedSessionIDs))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if len(result.Invoices) > 0 {
+			w.WriteHeader(http.StatusCreated)
+		}
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+// createInvoices в одной транзакции выбирает и блокирует сессии периода, создает счета
+// со строками и отмечает сессии как выставленные.
+func createInvoices(ctx context.Context, db *sql.DB, policy rounding.Policy, req InvoiceRequest, startDate, endDate time.Time) (InvoiceResult, error) {
+	result := InvoiceResult{Invoices: []model.Invoice{}, UnratedSessionIDs: []int{}}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return result, fmt.Errorf("ошибка при начале транзакции: %v", err)
+	}
+	defer tx.Rollback()
+
+	sessions, err := selectBillableSessions(tx, req, startDate, endDate)
+	if err != nil {
+		return result, err
+	}
+
+	// Сессии группируются по валюте ставки в порядке первого появления
+	var currencies []string
+	byCurrency := map[string][]billableSession{}
+	for _, s := range sessions {
+		if !s.rated {
+			result.UnratedSessionIDs = append(result.UnratedSessionIDs, s.line.SessionID)
+			continue
+		}
+		if _, ok := byCurrency[s.currency]; !ok {
+			currencies = append(currencies, s.currency)
+		}
+		byCurrency[s.currency] = append(byCurrency[s.currency], s)
+	}
+
+	for _, currency := range currencies {
+		invoice, err := insertInvoice(tx, policy, startDate, endDate, currency, byCurrency[currency])
+		if err != nil {
+			return result, err
+		}
+		err = audit.Record(ctx, tx, audit.Change{Action: audit.ActionCreated, Entity: audit.EntityInvoice, EntityID: invoice.ID, After: invoice})
+		if err != nil {
+			return result, err
+		}
+		result.Invoices = append(result.Invoices, invoice)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return result, fmt.Errorf("ошибка при фиксации транзакции: %v", err)
+	}
+	return result, nil
+}
+
+// selectBillableSessions выбирает с блокировкой сессии, которые можно выставить за период,
+// вместе с действующей ставкой. Сессии без ставки возвращаются с rated = false.
+func selectBillableSessions(tx *sql.Tx, req InvoiceRequest, startDate, endDate time.Time) ([]billableSession, error) {
+	rows, err := tx.Query(`
+		SELECT ut.id, ut.user_id, ut.id_task, ut.task_name, ut.start_time, ut.end_time, ut.total_seconds,
+			COALESCE(r.hourly_rate::text, ''), COALESCE(r.currency, ''), r.hourly_rate IS NOT NULL
+		FROM users_tasks ut
+		JOIN users u ON u.id = ut.user_id
+		JOIN tasks t ON t.id_task = ut.id_task
+		LEFT JOIN LATERAL (
+			SELECT hourly_rate, currency FROM rates
+			WHERE (user_id IS NULL OR user_id = ut.user_id)
+				AND (id_task IS NULL OR id_task = ut.id_task)
+				AND (project_id IS NULL OR project_id = t.project_id)
+				AND effective_from <= (ut.start_time AT TIME ZONE u.timezone)::date
+			ORDER BY (id_task IS NOT NULL) DESC, (project_id IS NOT NULL) DESC, (user_id IS NOT NULL) DESC,
+				effective_from DESC, id DESC
+			LIMIT 1
+		) r ON TRUE
+		WHERE ut.end_time IS NOT NULL AND ut.billable AND ut.invoice_id IS NULL
+			AND (ut.start_time AT TIME ZONE u.timezone)::date BETWEEN $1 AND $2
+			AND ($3 = 0 OR ut.user_id = $3)
+			AND ($4 = 0 OR t.project_id = $4)
+		ORDER BY ut.start_time, ut.id
+		FOR UPDATE OF ut
+	`, startDate, endDate, req.UserID, req.ProjectID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении сессий для счета: %v", err)
+	}
+	defer rows.Close()
+
+	var sessions []billableSession
+	for rows.Next() {
+		var s billableSession
+		err := rows.Scan(&s.line.SessionID, &s.line.UserID, &s.line.IDTask, &s.line.TaskName, &s.line.StartTime, &s.line.EndTime, &s.seconds,
+			&s.line.HourlyRate, &s.currency, &s.rated)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сканирования сессии для счета: %v", err)
+		}
+		sessions = append(sessions, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по сессиям для счета: %v", err)
+	}
+	return sessions, nil
+}
+
+// insertInvoice создает счет в валюте currency со строками по сессиям и блокирует сессии.
+// Суммы строк считаются в базе данных с точностью NUMERIC и округляются до копеек.
+func insertInvoice(tx *sql.Tx, policy rounding.Policy, startDate, endDate time.Time, currency string, sessions []billableSession) (model.Invoice, error) {
+	invoice := model.Invoice{PeriodStart: startDate, PeriodEnd: endDate, Currency: currency}
+	err := tx.QueryRow(`
+		INSERT INTO invoices (period_start, period_end, currency)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`, startDate, endDate, currency).Scan(&invoice.ID, &invoice.CreatedAt)
+	if err != nil {
+		return invoice, fmt.Errorf("ошибка при создании счета: %v", err)
+	}
+
+	sessionIDs := make([]int, 0, len(sessions))
+	for _, s := range sessions {
+		line := s.line
+		line.BilledSeconds = policy.Apply(s.seconds)
+		err := tx.QueryRow(`
+			INSERT INTO invoice_lines (invoice_id, session_id, user_id, id_task, task_name, start_time, end_time, billed_seconds, hourly_rate, amount)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9::numeric, ROUND($8 * $9::numeric / 3600, 2))
+			RETURNING id, amount::text
+		`, invoice.ID, line.SessionID, line.UserID, line.IDTask, line.TaskName, line.StartTime, line.EndTime, line.BilledSeconds, line.HourlyRate).
+			Scan(&line.ID, &line.Amount)
+		if err != nil {
+			return invoice, fmt.Errorf("ошибка при добавлении строки счета по сессии %d: %v", line.SessionID, err)
+		}
+		invoice.Lines = append(invoice.Lines, line)
+		sessionIDs = append(sessionIDs, line.SessionID)
+	}
+
+	_, err = tx.Exec(`UPDATE users_tasks SET invoice_id = $1 WHERE id = ANY($2)`, invoice.ID, pq.Array(sessionIDs))
+	if err != nil {
+		return invoice, fmt.Errorf("ошибка при блокировке выставленных сессий: %v", err)
+	}
+
+	err = tx.QueryRow(`
+		UPDATE invoices
+		SET total = (SELECT COALESCE(SUM(amount), 0) FROM invoice_lines WHERE invoice_id = $1)
+		WHERE id = $1
+		RETURNING total::text
+	`, invoice.ID).Scan(&invoice.Total)
+	if err != nil {
+		return invoice, fmt.Errorf("ошибка при подсчете суммы счета: %v", err)
+	}
+	return invoice, nil
+}
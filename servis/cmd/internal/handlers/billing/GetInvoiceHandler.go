@@ -0,0 +1,89 @@
+package billing
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	model "main.go/tracker_model"
+)
+
+// GetInvoiceHandler обрабатывает запросы на получение счета со строками.
+// @Summary Получение счета
+// @Description Возвращает счет и строки по выставленным сессиям.
+// @Tags Billing
+// @Produce json
+// @Param id path int true "Идентификатор счета"
+// @Success 200 {object} model.Invoice "Счет"
+// @Failure 400 {string} string "Неверный идентификатор счета"
+// @Failure 404 {string} string "Счет не найден"
+// @Failure 500 {string} string "Ошибка при получении счета"
+// @Router /api/v1/invoices/{id} [get]
+func GetInvoiceHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		invoiceID, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			log.Error("Неверный идентификатор счета", slog.String("id", r.PathValue("id")), slog.String("error", err.Error()))
+			http.Error(w, "Неверный идентификатор счета", http.StatusBadRequest)
+			return
+		}
+
+		invoice, err := getInvoice(db, invoiceID)
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("Счет не найден", slog.Int("invoice_id", invoiceID))
+			http.Error(w, "Счет не найден", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Error("Ошибка при получении счета", slog.Int("invoice_id", invoiceID), slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Ошибка при получении счета: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(invoice)
+	}
+}
+
+// getInvoice получает счет и его строки. Если счет не найден, возвращается ошибка,
+// оборачивающая sql.ErrNoRows.
+func getInvoice(db *sql.DB, invoiceID int) (model.Invoice, error) {
+	var invoice model.Invoice
+	err := db.QueryRow(`
+		SELECT id, period_start, period_end, currency, total::text, created_at
+		FROM invoices WHERE id = $1
+	`, invoiceID).Scan(&invoice.ID, &invoice.PeriodStart, &invoice.PeriodEnd, &invoice.Currency, &invoice.Total, &invoice.CreatedAt)
+	if err != nil {
+		return invoice, fmt.Errorf("ошибка при получении счета: %w", err)
+	}
+
+	rows, err := db.Query(`
+		SELECT id, COALESCE(session_id, 0), user_id, id_task, task_name, start_time, end_time, billed_seconds, hourly_rate::text, amount::text
+		FROM invoice_lines
+		WHERE invoice_id = $1
+		ORDER BY start_time, id
+	`, invoiceID)
+	if err != nil {
+		return invoice, fmt.Errorf("ошибка при получении строк счета: %v", err)
+	}
+	defer rows.Close()
+
+	invoice.Lines = []model.InvoiceLine{}
+	for rows.Next() {
+		var line model.InvoiceLine
+		err := rows.Scan(&line.ID, &line.SessionID, &line.UserID, &line.IDTask, &line.TaskName, &line.StartTime, &line.EndTime,
+			&line.BilledSeconds, &line.HourlyRate, &line.Amount)
+		if err != nil {
+			return invoice, fmt.Errorf("ошибка сканирования строки счета: %v", err)
+		}
+		invoice.Lines = append(invoice.Lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return invoice, fmt.Errorf("ошибка итерации по строкам счета: %v", err)
+	}
+	return invoice, nil
+}
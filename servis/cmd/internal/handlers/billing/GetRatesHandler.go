@@ -0,0 +1,69 @@
+package billing
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"main.go/cmd/internal/storage"
+	model "main.go/tracker_model"
+)
+
+// rateColumns перечень колонок rates в порядке, ожидаемом scanRate.
+const rateColumns = "id, user_id, id_task, project_id, hourly_rate::text, currency, effective_from, created_at"
+
+// GetRatesHandler обрабатывает запросы на получение списка ставок.
+// @Summary Список ставок
+// @Description Возвращает все ставки, отсортированные по дате начала действия от новых к старым.
+// @Tags Billing
+// @Produce json
+// @Success 200 {array} model.Rate "Список ставок"
+// @Failure 500 {string} string "Ошибка при выполнении запроса к базе данных"
+// @Router /api/v1/rates [get]
+func GetRatesHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rows, err := db.Query("SELECT " + rateColumns + " FROM rates ORDER BY effective_from DESC, id DESC")
+		if err != nil {
+			log.Error("Ошибка выполнения запроса к базе данных", slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Database query failed: %v", err), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		rates := []model.Rate{}
+		for rows.Next() {
+			rate, err := scanRate(rows)
+			if err != nil {
+				log.Error("Ошибка сканирования строки результата", slog.String("error", err.Error()))
+				http.Error(w, fmt.Sprintf("Failed to scan row: %v", err), http.StatusInternalServerError)
+				return
+			}
+			rates = append(rates, rate)
+		}
+
+		if err = rows.Err(); err != nil {
+			log.Error("Ошибка итерации по строкам результата", slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Row iteration error: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rates)
+	}
+}
+
+// scanRate сканирует строку с колонками rateColumns в модель ставки.
+func scanRate(row storage.RowScanner) (model.Rate, error) {
+	var rate model.Rate
+	var userID, taskID, projectID sql.NullInt64
+	err := row.Scan(&rate.ID, &userID, &taskID, &projectID, &rate.HourlyRate, &rate.Currency, &rate.EffectiveFrom, &rate.CreatedAt)
+	if err != nil {
+		return rate, err
+	}
+	rate.UserID = int(userID.Int64)
+	rate.IDTask = int(taskID.Int64)
+	rate.ProjectID = int(projectID.Int64)
+	return rate, nil
+}
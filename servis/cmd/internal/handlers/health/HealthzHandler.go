@@ -0,0 +1,21 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+
+	model "main.go/tracker_model"
+)
+
+// HealthzHandler проверка живости: процесс запущен и обрабатывает запросы. Зависимости не проверяются.
+// @Summary Проверка живости
+// @Tags Health
+// @Produce json
+// @Success 200 {object} map[string]string "Процесс работает"
+// @Router /healthz [get]
+func HealthzHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": model.HealthOK})
+	}
+}
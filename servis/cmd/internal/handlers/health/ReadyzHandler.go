@@ -0,0 +1,125 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"main.go/cmd/internal/handlers/util"
+	"main.go/cmd/internal/storage"
+	"main.go/cmd/internal/storage/cache"
+	model "main.go/tracker_model"
+)
+
+// checkTimeout наибольшее время одной проверки зависимости.
+const checkTimeout = 2 * time.Second
+
+// Статусы готовности сервиса.
+const (
+	statusReady    = "ready"
+	statusDegraded = "degraded"
+	statusNotReady = "not_ready"
+)
+
+// check проверка одной зависимости. critical — при ошибке сервис не готов принимать запросы,
+// иначе он работает с ограничениями (degraded).
+type check struct {
+	name     string
+	critical bool
+	run      func(ctx context.Context) error
+}
+
+// ReadyzHandler проверка готовности: доступность базы данных, примененные миграции, загруженный кэш
+// и доступность внешнего API сведений о пользователе. Недоступность внешнего API не делает сервис
+// неготовым — без него не добавляются только новые пользователи, поэтому статус становится degraded.
+// @Summary Проверка готовности
+// @Description Возвращает общий статус (ready, degraded, not_ready) и результат проверки каждой зависимости.
+// @Tags Health
+// @Produce json
+// @Success 200 {object} model.Readiness "Сервис готов или работает с ограничениями"
+// @Failure 503 {object} model.Readiness "Сервис не готов"
+// @Router /readyz [get]
+func ReadyzHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
+	checks := []check{
+		{name: "database", critical: true, run: db.PingContext},
+		{name: "migrations", critical: true, run: func(ctx context.Context) error {
+			pending, err := storage.PendingMigrations(ctx, db)
+			if err != nil {
+				return err
+			}
+			if len(pending) > 0 {
+				return fmt.Errorf("не применены миграции: %s", strings.Join(pending, ", "))
+			}
+			return nil
+		}},
+		{name: "cache", critical: true, run: func(ctx context.Context) error {
+			if !cache.Warmed() {
+				return fmt.Errorf("кэш пользователей не загружен")
+			}
+			return nil
+		}},
+		{name: "user_info_api", critical: false, run: util.PingUserInfoAPI},
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		readiness := model.Readiness{Status: statusReady, Checks: make(map[string]model.HealthCheck, len(checks))}
+
+		// Проверки выполняются параллельно, чтобы ответ не превышал checkTimeout
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		for _, c := range checks {
+			wg.Add(1)
+			go func(c check) {
+				defer wg.Done()
+				result := runCheck(r.Context(), c)
+				mu.Lock()
+				readiness.Checks[c.name] = result
+				mu.Unlock()
+			}(c)
+		}
+		wg.Wait()
+
+		for _, c := range checks {
+			result := readiness.Checks[c.name]
+			if result.Status == model.HealthOK {
+				continue
+			}
+			if c.critical {
+				readiness.Status = statusNotReady
+			} else if readiness.Status == statusReady {
+				readiness.Status = statusDegraded
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if readiness.Status == statusNotReady {
+			log.Warn("Сервис не готов", slog.Any("checks", readiness.Checks))
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(readiness)
+	}
+}
+
+// runCheck выполняет проверку с ограничением checkTimeout.
+func runCheck(ctx context.Context, c check) model.HealthCheck {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := c.run(ctx)
+	result := model.HealthCheck{Status: model.HealthOK, DurationMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status = model.HealthFailed
+		if !c.critical {
+			result.Status = model.HealthDegraded
+		}
+		result.Error = err.Error()
+	}
+	return result
+}
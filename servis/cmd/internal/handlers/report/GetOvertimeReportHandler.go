@@ -0,0 +1,155 @@
+package report
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"main.go/cmd/internal/calendar"
+	"main.go/cmd/internal/config"
+	"main.go/cmd/internal/rounding"
+	"main.go/cmd/internal/storage"
+	model "main.go/tracker_model"
+)
+
+// maxOvertimeDays наибольшая длина периода отчета о переработках в днях: отчет содержит
+// строку на каждый день, поэтому период ограничен годом.
+const maxOvertimeDays = 366
+
+// GetOvertimeReportHandler обрабатывает запросы на получение отчета о переработках пользователя за период.
+// Норма дня берется из графика работы пользователя, а без графика — пятидневка по cfg.DailyHours;
+// праздничные дни из производственного календаря считаются выходными, а согласованные отсутствия
+// снижают норму дня. Сессия относится к дню по времени начала в часовом поясе tz, длительность
+// каждой округляется по политике policy.
+// @Summary Отчет о переработках
+// @Description Возвращает отработанное время по дням и неделям с разделением на норму и переработку и отметками выходных и праздников.
+// @Tags Report
+// @Produce json
+// @Param user_id query int true "Идентификатор пользователя"
+// @Param start_date query string true "Дата начала периода в формате YYYY-MM-DD"
+// @Param end_date query string true "Дата окончания периода в формате YYYY-MM-DD включительно, не более 366 дней от начала"
+// @Param tz query string false "Часовой пояс IANA (по умолчанию пояс пользователя)"
+// @Success 200 {object} model.OvertimeReport "Отчет"
+// @Failure 400 {string} string "Неверные параметры запроса"
+// @Failure 404 {string} string "Пользователь не найден"
+// @Failure 500 {string} string "Ошибка при выполнении запроса к базе данных"
+// @Router /api/v1/reports/overtime [get]
+func GetOvertimeReportHandler(db *sql.DB, log *slog.Logger, policy rounding.Policy, cfg config.CalendarConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		userID, err := strconv.Atoi(query.Get("user_id"))
+		if err != nil {
+			log.Error("Неверный формат user_id", slog.String("error", err.Error()))
+			http.Error(w, "Invalid user_id", http.StatusBadRequest)
+			return
+		}
+
+		startDate, err := time.Parse("2006-01-02", query.Get("start_date"))
+		if err != nil {
+			log.Error("Неверный формат start_date", slog.String("error", err.Error()))
+			http.Error(w, "Invalid start_date format", http.StatusBadRequest)
+			return
+		}
+		endDate, err := time.Parse("2006-01-02", query.Get("end_date"))
+		if err != nil {
+			log.Error("Неверный формат end_date", slog.String("error", err.Error()))
+			http.Error(w, "Invalid end_date format", http.StatusBadRequest)
+			return
+		}
+		if endDate.Before(startDate) {
+			log.Warn("end_date раньше start_date", slog.String("start_date", query.Get("start_date")), slog.String("end_date", query.Get("end_date")))
+			http.Error(w, "end_date must not be before start_date", http.StatusBadRequest)
+			return
+		}
+		if endDate.After(startDate.AddDate(0, 0, maxOvertimeDays-1)) {
+			log.Warn("Слишком длинный период отчета", slog.String("start_date", query.Get("start_date")), slog.String("end_date", query.Get("end_date")))
+			http.Error(w, fmt.Sprintf("Period too long, expected at most %d days", maxOvertimeDays), http.StatusBadRequest)
+			return
+		}
+
+		loc, err := storage.UserLocation(db, userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("Пользователь не найден", slog.Int("user_id", userID))
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Error("Ошибка при получении часового пояса пользователя", slog.Int("user_id", userID), slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Database query failed: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if tz := query.Get("tz"); tz != "" {
+			loc, err = storage.LoadTimezone(tz)
+			if err != nil {
+				log.Warn("Неверный часовой пояс", slog.String("error", err.Error()))
+				http.Error(w, "Invalid tz", http.StatusBadRequest)
+				return
+			}
+		}
+
+		report, err := buildOvertimeReport(db, policy, cfg, userID, startDate, endDate, loc)
+		if err != nil {
+			log.Error("Ошибка построения отчета о переработках", slog.Int("user_id", userID), slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Database query failed: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		log.Info("Отчет о переработках сформирован", slog.Int("user_id", userID), slog.Int64("worked_seconds", report.Total.WorkedSeconds), slog.Int64("overtime_seconds", report.Total.OvertimeSeconds))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	}
+}
+
+// buildOvertimeReport считает отработанное время пользователя по дням периода в часовом поясе loc
+// и распределяет его на норму и переработку по графику и производственному календарю.
+func buildOvertimeReport(db *sql.DB, policy rounding.Policy, cfg config.CalendarConfig, userID int, startDate, endDate time.Time, loc *time.Location) (model.OvertimeReport, error) {
+	schedule, _, err := calendar.UserSchedule(db, userID, calendar.DefaultSchedule(cfg.DailyHours))
+	if err != nil {
+		return model.OvertimeReport{}, err
+	}
+	holidays, err := calendar.Holidays(db, startDate, endDate)
+	if err != nil {
+		return model.OvertimeReport{}, err
+	}
+	absences, err := storage.ApprovedAbsences(db, userID, startDate.Format("2006-01-02"), endDate.Format("2006-01-02"))
+	if err != nil {
+		return model.OvertimeReport{}, err
+	}
+
+	rows, err := db.Query(`
+		SELECT to_char(start_time AT TIME ZONE $1, 'YYYY-MM-DD'), SUM(`+policy.SQL("total_seconds")+`)
+		FROM users_tasks
+		WHERE user_id = $2 AND end_time IS NOT NULL
+			AND start_time >= $3 AND start_time < $4
+		GROUP BY 1
+	`, loc.String(), userID, storage.LocalDate(startDate, loc), storage.LocalDate(endDate, loc).AddDate(0, 0, 1))
+	if err != nil {
+		return model.OvertimeReport{}, err
+	}
+	defer rows.Close()
+
+	worked := map[string]int64{}
+	for rows.Next() {
+		var date string
+		var seconds int64
+		if err := rows.Scan(&date, &seconds); err != nil {
+			return model.OvertimeReport{}, fmt.Errorf("ошибка сканирования строки отчета: %v", err)
+		}
+		worked[date] = seconds
+	}
+	if err := rows.Err(); err != nil {
+		return model.OvertimeReport{}, fmt.Errorf("ошибка итерации по строкам отчета: %v", err)
+	}
+
+	report := calendar.Overtime(worked, startDate, endDate, schedule, holidays, absences, cfg.WeeklyThreshold)
+	report.UserID = userID
+	report.Timezone = loc.String()
+	return report, nil
+}
@@ -0,0 +1,194 @@
+package report
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"main.go/cmd/internal/rounding"
+	"main.go/cmd/internal/storage"
+	model "main.go/tracker_model"
+)
+
+// grouping описывает выражения группировки отчета над CTE s.
+type grouping struct {
+	key   string // Ключ группы
+	label string // Подпись группы, например имя задачи
+	order string // Порядок строк отчета
+}
+
+// groupings допустимые значения параметра group_by. Выражения подставляются в запрос
+// только из этого перечня, пользовательский ввод в текст запроса не попадает.
+var groupings = map[string]grouping{
+	"day":   {key: "to_char(date_trunc('day', local_start), 'YYYY-MM-DD')", label: "''", order: "2"},
+	"week":  {key: "to_char(date_trunc('week', local_start), 'YYYY-MM-DD')", label: "''", order: "2"},
+	"month": {key: "to_char(date_trunc('month', local_start), 'YYYY-MM')", label: "''", order: "2"},
+	"task":  {key: "id_task::text", label: "MAX(task_name)", order: "4 DESC, 2"},
+	"user":  {key: "user_id::text", label: "MAX(user_name)", order: "4 DESC, 2"},
+}
+
+// GetReportHandler обрабатывает запросы на получение отчета о трудозатратах за период
+// с группировкой по дню, неделе, месяцу, задаче или пользователю.
+// Границы дней, недель и месяцев определяются в часовом поясе tz; сессия относится
+// к группе по времени начала. Учитываются только завершенные сессии, длительность
+// каждой округляется по политике policy.
+// @Summary Отчет о трудозатратах
+// @Description Возвращает трудозатраты по группам в часах и минутах с общим итогом и средним временем за рабочий день.
+// @Tags Report
+// @Produce json
+// @Param group_by query string true "Группировка: day, week, month, task, user"
+// @Param start_date query string true "Дата начала периода в формате YYYY-MM-DD"
+// @Param end_date query string true "Дата окончания периода в формате YYYY-MM-DD включительно"
+// @Param user_id query int false "Идентификатор пользователя"
+// @Param tz query string false "Часовой пояс IANA, например Europe/Moscow (по умолчанию пояс пользователя или UTC)"
+// @Success 200 {object} model.Report "Отчет"
+// @Failure 400 {string} string "Неверные параметры запроса"
+// @Failure 500 {string} string "Ошибка при выполнении запроса к базе данных"
+// @Router /api/v1/reports [get]
+func GetReportHandler(db *sql.DB, log *slog.Logger, policy rounding.Policy) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		groupBy := query.Get("group_by")
+		startDateStr := query.Get("start_date")
+		endDateStr := query.Get("end_date")
+
+		log.Info("Получен запрос на отчет о трудозатратах", slog.String("group_by", groupBy), slog.String("start_date", startDateStr), slog.String("end_date", endDateStr))
+
+		g, ok := groupings[groupBy]
+		if !ok {
+			log.Warn("Неверное значение group_by", slog.String("group_by", groupBy))
+			http.Error(w, "Invalid group_by, expected one of: day, week, month, task, user", http.StatusBadRequest)
+			return
+		}
+
+		startDate, err := time.Parse("2006-01-02", startDateStr)
+		if err != nil {
+			log.Error("Неверный формат start_date", slog.String("error", err.Error()))
+			http.Error(w, "Invalid start_date format", http.StatusBadRequest)
+			return
+		}
+		endDate, err := time.Parse("2006-01-02", endDateStr)
+		if err != nil {
+			log.Error("Неверный формат end_date", slog.String("error", err.Error()))
+			http.Error(w, "Invalid end_date format", http.StatusBadRequest)
+			return
+		}
+		if endDate.Before(startDate) {
+			log.Warn("end_date раньше start_date", slog.String("start_date", startDateStr), slog.String("end_date", endDateStr))
+			http.Error(w, "end_date must not be before start_date", http.StatusBadRequest)
+			return
+		}
+
+		userID := 0
+		if userIDStr := query.Get("user_id"); userIDStr != "" {
+			userID, err = strconv.Atoi(userIDStr)
+			if err != nil {
+				log.Error("Неверный формат user_id", slog.String("error", err.Error()))
+				http.Error(w, "Invalid user_id", http.StatusBadRequest)
+				return
+			}
+		}
+
+		// Без tz отчет по одному пользователю строится в его часовом поясе, по всем — в UTC
+		var loc *time.Location
+		if tz := query.Get("tz"); tz != "" || userID == 0 {
+			loc, err = storage.LoadTimezone(tz)
+		} else {
+			loc, err = storage.UserLocation(db, userID)
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("Пользователь не найден", slog.Int("user_id", userID))
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Warn("Неверный часовой пояс", slog.String("error", err.Error()))
+			http.Error(w, "Invalid tz", http.StatusBadRequest)
+			return
+		}
+
+		report, err := buildReport(db, g, policy, userID, startDate, endDate, loc)
+		if err != nil {
+			log.Error("Ошибка построения отчета", slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Database query failed: %v", err), http.StatusInternalServerError)
+			return
+		}
+		report.GroupBy = groupBy
+
+		log.Info("Отчет сформирован", slog.String("group_by", groupBy), slog.Int("rows", len(report.Rows)), slog.Int("total_minutes", report.Total.TotalMinutes))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	}
+}
+
+// buildReport считает трудозатраты по группам и общий итог одним запросом с GROUPING SETS.
+// Период [startDate, endDate] задается датами в часовом поясе loc; время начала сессии
+// переводится в loc перед усечением до дня, недели или месяца. Рабочим днем считается
+// день пользователя, в который есть хотя бы одна сессия.
+func buildReport(db *sql.DB, g grouping, policy rounding.Policy, userID int, startDate, endDate time.Time, loc *time.Location) (model.Report, error) {
+	report := model.Report{
+		StartDate: startDate.Format("2006-01-02"),
+		EndDate:   endDate.Format("2006-01-02"),
+		Timezone:  loc.String(),
+		Rows:      []model.ReportRow{},
+	}
+	from := storage.LocalDate(startDate, loc)
+	to := storage.LocalDate(endDate, loc).AddDate(0, 0, 1)
+
+	rows, err := db.Query(`
+		WITH s AS (
+			SELECT ut.user_id, ut.id_task, ut.task_name, ut.total_seconds,
+				COALESCE(u.surname || ' ' || u.name, '') AS user_name,
+				ut.start_time AT TIME ZONE $1 AS local_start
+			FROM users_tasks ut
+			LEFT JOIN users u ON u.id = ut.user_id
+			WHERE ut.end_time IS NOT NULL
+				AND ut.start_time >= $2 AND ut.start_time < $3
+				AND ($4 = 0 OR ut.user_id = $4)
+		)
+		SELECT GROUPING(`+g.key+`) = 1 AS is_total,
+			COALESCE(`+g.key+`, ''),
+			COALESCE(`+g.label+`, ''),
+			COALESCE(SUM(`+policy.SQL("total_seconds")+`), 0),
+			COUNT(*),
+			COUNT(DISTINCT (user_id, local_start::date))
+		FROM s
+		GROUP BY GROUPING SETS ((`+g.key+`), ())
+		ORDER BY 1, `+g.order,
+		loc.String(), from, to, userID)
+	if err != nil {
+		return report, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var isTotal bool
+		var row model.ReportRow
+		if err := rows.Scan(&isTotal, &row.Key, &row.Label, &row.TotalSeconds, &row.Sessions, &row.WorkingDays); err != nil {
+			return report, fmt.Errorf("ошибка сканирования строки отчета: %v", err)
+		}
+		row.TotalMinutes = int(row.TotalSeconds / 60)
+		row.Hours, row.Minutes = row.TotalMinutes/60, row.TotalMinutes%60
+		if row.WorkingDays > 0 {
+			row.AveragePerDayMin = math.Round(float64(row.TotalSeconds)/60/float64(row.WorkingDays)*10) / 10
+		}
+
+		if isTotal {
+			row.Key, row.Label = "total", ""
+			report.Total = row
+			continue
+		}
+		report.Rows = append(report.Rows, row)
+	}
+	if err := rows.Err(); err != nil {
+		return report, fmt.Errorf("ошибка итерации по строкам отчета: %v", err)
+	}
+	return report, nil
+}
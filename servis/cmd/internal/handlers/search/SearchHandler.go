@@ -0,0 +1,137 @@
+package search
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	model "main.go/tracker_model"
+)
+
+// Типы результатов поиска.
+const (
+	typeUser    = "user"
+	typeTask    = "task"
+	typeSession = "session"
+)
+
+// maxLimit наибольшее количество результатов поиска за один запрос.
+const maxLimit = 100
+
+// headlineOptions настройки ts_headline: найденные слова выделяются тегами <b></b>.
+const headlineOptions = "StartSel=<b>, StopSel=</b>, MinWords=5, MaxWords=20, MaxFragments=2"
+
+// searchQuery ищет по векторам search_vector пользователей, задач и описаний сессий.
+// Запрос разбирается websearch_to_tsquery в русской и английской конфигурациях, поэтому
+// поддерживаются кавычки для фраз, OR и минус для исключения слов.
+const searchQuery = `
+	WITH q AS (
+		SELECT websearch_to_tsquery('russian', $1) || websearch_to_tsquery('english', $1) AS query
+	)
+	SELECT type, id, user_id, title, headline, rank FROM (
+		SELECT 'user' AS type, u.id, u.id AS user_id,
+			TRIM(u.surname || ' ' || u.name || ' ' || COALESCE(u.patronymic, '')) AS title,
+			ts_headline('russian', u.surname || ' ' || u.name || ' ' || COALESCE(u.patronymic, '') || ', ' || u.address, q.query, '` + headlineOptions + `') AS headline,
+			ts_rank(u.search_vector, q.query) AS rank
+		FROM users u, q
+		WHERE $2 AND u.search_vector @@ q.query
+		UNION ALL
+		SELECT 'task', t.id_task, 0, t.task_name,
+			ts_headline('russian', t.task_name, q.query, '` + headlineOptions + `'),
+			ts_rank(t.search_vector, q.query)
+		FROM tasks t, q
+		WHERE $3 AND t.search_vector @@ q.query
+		UNION ALL
+		SELECT 'session', ut.id, ut.user_id, ut.task_name,
+			ts_headline('russian', ut.description, q.query, '` + headlineOptions + `'),
+			ts_rank(ut.search_vector, q.query)
+		FROM users_tasks ut, q
+		WHERE $4 AND ut.search_vector @@ q.query
+	) r
+	ORDER BY rank DESC, type, id
+	LIMIT $5`
+
+// SearchHandler обрабатывает запросы полнотекстового поиска по пользователям (ФИО и адрес),
+// задачам и описаниям сессий. Результаты упорядочены по релевантности.
+// @Summary Полнотекстовый поиск
+// @Description Ищет пользователей, задачи и сессии по словам в русской и английской морфологии, возвращает ранг и фрагменты с выделенными словами.
+// @Tags Search
+// @Produce json
+// @Param q query string true "Поисковый запрос, например \"код ревью\" или встреча -планирование"
+// @Param type query string false "Типы результатов через запятую: user, task, session (по умолчанию все)"
+// @Param limit query int false "Количество результатов, по умолчанию 20, не более 100"
+// @Success 200 {array} model.SearchResult "Результаты поиска"
+// @Failure 400 {string} string "Неверные параметры запроса"
+// @Failure 500 {string} string "Ошибка при выполнении запроса к базе данных"
+// @Router /api/v1/search [get]
+func SearchHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		text := strings.TrimSpace(query.Get("q"))
+		if text == "" {
+			log.Warn("Пустой поисковый запрос")
+			http.Error(w, "Missing q", http.StatusBadRequest)
+			return
+		}
+
+		types := []string{typeUser, typeTask, typeSession}
+		if typesStr := query.Get("type"); typesStr != "" {
+			types = strings.Split(typesStr, ",")
+			for _, t := range types {
+				if t != typeUser && t != typeTask && t != typeSession {
+					log.Warn("Неверный тип результатов поиска", slog.String("type", t))
+					http.Error(w, "Invalid type, expected: user, task, session", http.StatusBadRequest)
+					return
+				}
+			}
+		}
+
+		limit := 20
+		if limitStr := query.Get("limit"); limitStr != "" {
+			var err error
+			limit, err = strconv.Atoi(limitStr)
+			if err != nil || limit < 1 || limit > maxLimit {
+				log.Warn("Неверное значение limit", slog.String("limit", limitStr))
+				http.Error(w, fmt.Sprintf("Invalid limit, expected 1-%d", maxLimit), http.StatusBadRequest)
+				return
+			}
+		}
+
+		rows, err := db.Query(searchQuery, text,
+			slices.Contains(types, typeUser), slices.Contains(types, typeTask), slices.Contains(types, typeSession), limit)
+		if err != nil {
+			log.Error("Ошибка выполнения поискового запроса", slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Database query failed: %v", err), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		results := []model.SearchResult{}
+		for rows.Next() {
+			var result model.SearchResult
+			err := rows.Scan(&result.Type, &result.ID, &result.UserID, &result.Title, &result.Headline, &result.Rank)
+			if err != nil {
+				log.Error("Ошибка сканирования строки результата", slog.String("error", err.Error()))
+				http.Error(w, fmt.Sprintf("Failed to scan row: %v", err), http.StatusInternalServerError)
+				return
+			}
+			results = append(results, result)
+		}
+
+		if err = rows.Err(); err != nil {
+			log.Error("Ошибка итерации по строкам результата", slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Row iteration error: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		log.Info("Поиск выполнен", slog.String("q", text), slog.Any("types", types), slog.Int("results", len(results)))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(results)
+	}
+}
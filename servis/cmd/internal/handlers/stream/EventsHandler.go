@@ -0,0 +1,107 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"main.go/cmd/internal/events"
+)
+
+// heartbeatInterval период отправки комментариев-пингов, чтобы прокси не закрывали простаивающее соединение.
+const heartbeatInterval = 15 * time.Second
+
+// EventsHandler отдает поток событий по сессиям в формате Server-Sent Events.
+// Параметр user_id принимает один или несколько идентификаторов через запятую,
+// например всех участников команды; без него передаются события всех пользователей.
+// @Summary Поток событий по сессиям
+// @Description Server-Sent Events о начале, паузе, завершении, добавлении и исправлении сессий.
+// @Tags Events
+// @Produce text/event-stream
+// @Param user_id query string false "Идентификаторы пользователей через запятую"
+// @Success 200 {string} string "Поток событий"
+// @Failure 400 {string} string "Неверные параметры запроса"
+// @Failure 500 {string} string "Потоковая передача не поддерживается"
+// @Router /api/v1/events [get]
+func EventsHandler(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userIDs, err := parseUserIDs(r.URL.Query().Get("user_id"))
+		if err != nil {
+			log.Error("Неверный формат user_id", slog.String("error", err.Error()))
+			http.Error(w, "Invalid user_id", http.StatusBadRequest)
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			log.Error("Потоковая передача не поддерживается")
+			http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		// Поток живет дольше WriteTimeout сервера, поэтому дедлайн записи снимается
+		if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+			log.Warn("Не удалось снять дедлайн записи для потока событий", slog.String("error", err.Error()))
+		}
+
+		ch, unsubscribe := events.Subscribe()
+		defer unsubscribe()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		log.Info("Клиент подписался на поток событий", slog.Any("user_ids", userIDs))
+
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				log.Info("Клиент отписался от потока событий", slog.Any("user_ids", userIDs))
+				return
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": ping\n\n")
+				flusher.Flush()
+			case event, ok := <-ch:
+				if !ok {
+					return
+				}
+				if len(userIDs) > 0 && !userIDs[event.UserID] {
+					continue
+				}
+				data, err := json.Marshal(event)
+				if err != nil {
+					log.Error("Ошибка сериализации события", slog.String("error", err.Error()))
+					continue
+				}
+				fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// parseUserIDs разбирает список идентификаторов пользователей через запятую.
+// Пустая строка означает отсутствие фильтра.
+func parseUserIDs(s string) (map[int]bool, error) {
+	userIDs := map[int]bool{}
+	if s == "" {
+		return userIDs, nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		userIDs[id] = true
+	}
+	return userIDs, nil
+}
@@ -0,0 +1,180 @@
+package task
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/lib/pq"
+	"main.go/cmd/internal/audit"
+	"main.go/cmd/internal/events"
+	"main.go/cmd/internal/outbox"
+	"main.go/cmd/internal/storage"
+	"main.go/cmd/internal/storage/cache"
+	"main.go/cmd/internal/taskbudget"
+	model "main.go/tracker_model"
+)
+
+// SessionRequest представляет данные запроса для ручного добавления сессии
+// с явно заданными временем начала и окончания.
+type SessionRequest struct {
+	UserID      int       `json:"user_id"`     // Идентификатор пользователя
+	IDTask      int       `json:"id_task"`     // Идентификатор задачи
+	StartTime   time.Time `json:"start_time"`  // Время начала в формате RFC3339
+	EndTime     time.Time `json:"end_time"`    // Время окончания в формате RFC3339
+	Billable    *bool     `json:"billable"`    // Признак оплачиваемой сессии, по умолчанию true
+	Description string    `json:"description"` // Описание сессии
+	Tags        []string  `json:"tags"`        // Метки сессии
+}
+
+// AddSessionHandler обрабатывает HTTP запросы на ручное добавление сессии по задаче,
+// например если пользователь забыл начать отсчет времени.
+
+// Проверяет интервал, отсутствие пересечений с другими сессиями пользователя, сохраняет сессию и обновляет кэш.
+// @Summary Ручное добавление сессии
+// @Description Создает завершенную сессию по задаче с явно заданными временем начала и окончания.
+// @Tags Task
+// @Accept json
+// @Produce json
+// @Param session body SessionRequest true "Данные сессии"
+// @Success 201 {object} UserTask "Созданная сессия"
+// @Failure 400 {string} string "Неверный формат ввода или интервал"
+// @Failure 409 {string} string "Сессия пересекается с другой сессией или период согласован"
+// @Failure 500 {string} string "Ошибка при добавлении сессии"
+// @Router /api/v1/sessions [post]
+func AddSessionHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req SessionRequest
+
+		// Декодирование JSON данных из тела запроса в структуру SessionRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			log.Error("Неверный формат ввода", slog.String("error", err.Error()))
+			http.Error(w, "Неверный формат ввода", http.StatusBadRequest)
+			return
+		}
+
+		if err := validateSessionInterval(req.StartTime, req.EndTime); err != nil {
+			log.Warn("Неверный интервал сессии", slog.Any("request", req), slog.String("error", err.Error()))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		req.Tags, err = normalizeTags(req.Tags)
+		if err == nil {
+			err = validateDescription(req.Description)
+		}
+		if err != nil {
+			log.Warn("Неверное описание или метки сессии", slog.Any("request", req), slog.String("error", err.Error()))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		task, budget, err := addSessionToDB(r.Context(), db, req)
+		if errors.Is(err, errSessionOverlap) || errors.Is(err, storage.ErrPeriodLocked) {
+			log.Warn("Сессия не может быть добавлена", slog.Any("request", req), slog.String("error", err.Error()))
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Error("Ошибка при добавлении сессии в базу данных", slog.Any("request", req), slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Ошибка при добавлении сессии: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		log.Info("Сессия добавлена вручную", slog.Int("session_id", task.IDSession), slog.Int("user_id", task.UserID))
+		taskbudget.LogAlert(log, budget)
+
+		// Обновление кэша
+		cache.UserCacheMutex.Lock()
+		if user, exists := cache.UserCache[task.UserID]; exists {
+			user.UserTask = append(user.UserTask, task)
+			cache.UserCache[task.UserID] = user
+		} else {
+			log.Warn("Пользователь не найден в кэше", slog.Int("user_id", task.UserID))
+		}
+		cache.UserCacheMutex.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(task)
+	}
+}
+
+// validateSessionInterval проверяет, что время начала и окончания заданы,
+// окончание позже начала и интервал не находится в будущем.
+func validateSessionInterval(startTime, endTime time.Time) error {
+	if startTime.IsZero() || endTime.IsZero() {
+		return errors.New("необходимо указать start_time и end_time")
+	}
+	if !endTime.After(startTime) {
+		return errors.New("end_time должно быть позже start_time")
+	}
+	if endTime.After(time.Now()) {
+		return errors.New("end_time не может быть в будущем")
+	}
+	return nil
+}
+
+// addSessionToDB в одной транзакции проверяет отсутствие пересечений, вставляет завершенную сессию
+// и проверяет бюджет задачи. Возвращает бюджет задачи с достигнутым порогом либо nil.
+func addSessionToDB(ctx context.Context, db *sql.DB, req SessionRequest) (model.UserTask, *model.TaskBudget, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return model.UserTask{}, nil, fmt.Errorf("ошибка при начале транзакции: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockUser(tx, req.UserID); err != nil {
+		return model.UserTask{}, nil, err
+	}
+
+	if err := storage.CheckPeriodUnlocked(tx, req.UserID, req.StartTime, req.EndTime); err != nil {
+		return model.UserTask{}, nil, err
+	}
+
+	if err := checkSessionOverlap(tx, req.UserID, 0, req.StartTime, req.EndTime); err != nil {
+		return model.UserTask{}, nil, err
+	}
+
+	var taskName string
+	err = tx.QueryRow(`SELECT task_name FROM tasks WHERE id_task = $1`, req.IDTask).Scan(&taskName)
+	if err != nil {
+		return model.UserTask{}, nil, fmt.Errorf("ошибка при получении имени задачи из базы данных: %v", err)
+	}
+
+	billable := req.Billable == nil || *req.Billable
+
+	task, err := storage.ScanUserTask(tx.QueryRow(`
+		INSERT INTO users_tasks (user_id, id_task, task_name, start_time, end_time, total_seconds, billable, description, tags)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING `+storage.UserTaskColumns,
+		req.UserID, req.IDTask, taskName, req.StartTime, req.EndTime, sessionSeconds(req.StartTime, req.EndTime), billable,
+		req.Description, pq.Array(req.Tags)))
+	if err != nil {
+		return task, nil, fmt.Errorf("ошибка при вставке сессии в базу данных: %v", err)
+	}
+
+	if err := outbox.EnqueueSession(tx, events.SessionAdded, task); err != nil {
+		return task, nil, err
+	}
+	err = audit.Record(ctx, tx, audit.Change{Action: audit.ActionCreated, Entity: audit.EntitySession, EntityID: task.IDSession, UserID: task.UserID, After: task})
+	if err != nil {
+		return task, nil, err
+	}
+
+	budget, err := taskbudget.Check(tx, task.UserID, task.IDTask)
+	if err != nil {
+		return task, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return task, nil, fmt.Errorf("ошибка при фиксации транзакции: %v", err)
+	}
+	return task, budget, nil
+}
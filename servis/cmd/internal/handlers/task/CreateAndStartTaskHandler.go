@@ -1,34 +1,57 @@
 package task
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"time"
 
+	"github.com/lib/pq"
+	"main.go/cmd/internal/audit"
+	"main.go/cmd/internal/config"
+	"main.go/cmd/internal/events"
+	"main.go/cmd/internal/outbox"
+	"main.go/cmd/internal/storage"
 	"main.go/cmd/internal/storage/cache"
+	"main.go/cmd/internal/taskbudget"
 	"main.go/tracker_model"
 )
 
 // TaskRequest представляет данные запроса для начала отсчета времени по задаче.
 // Включает идентификатор пользователя и идентификатор задачи.
 type TaskRequest struct {
-	UserID int `json:"user_id"` // Идентификатор пользователя
-	IDTask int `json:"id_task"` // Идентификатор задачи
+	UserID          int      `json:"user_id"`          // Идентификатор пользователя
+	IDTask          int      `json:"id_task"`          // Идентификатор задачи
+	OverrideAbsence bool     `json:"override_absence"` // Начать задачу несмотря на отсутствие на весь день
+	Description     string   `json:"description"`      // Описание сессии; при завершении непустое описание заменяет прежнее
+	Tags            []string `json:"tags"`             // Метки сессии, например meetings; при завершении добавляются к прежним
 }
 
 type UserTask struct {
+	IDSession    int       `json:"id_session"`
 	UserID       int       `json:"id_user"`
 	IDTask       int       `json:"id_task"`
 	TaskName     string    `json:"task_name"`
 	StartTime    time.Time `json:"start_time"`
 	EndTime      time.Time `json:"end_time"`
+	TotalSeconds int64     `json:"total_seconds"`
 	TotalMinutes int       `json:"total_minutes"`
+	Status       string    `json:"status"`
+	AutoClosed   bool      `json:"auto_closed"`
+	Billable     bool      `json:"billable"`
+	InvoiceID    int       `json:"invoice_id,omitempty"`
+	Description  string    `json:"description,omitempty"`
+	Tags         []string  `json:"tags"`
 }
 
 // StartTaskHandler обрабатывает HTTP запросы для начала отсчета времени по задаче для пользователя.
+// Если включена политика single_active_session, текущая незавершенная сессия пользователя
+// завершается в тот же момент, в который начинается новая. В день согласованного отсутствия
+// на весь день задача не начинается, если в запросе не указан override_absence.
 
 // Декодирует запрос, добавляет задачу в базу данных, обновляет кэш и возвращает данные о задаче в формате JSON.
 // @Summary Start a task
@@ -39,9 +62,10 @@ type UserTask struct {
 // @Param task body TaskRequest true "Task Request"
 // @Success 200 {object} UserTask "Task details"
 // @Failure 400 {string} string "Invalid input"
+// @Failure 409 {string} string "Period is approved and locked or user is absent today"
 // @Failure 500 {string} string "Failed to start task"
 // @Router /api/v1/tasks/start [post]
-func StartTaskHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
+func StartTaskHandler(db *sql.DB, log *slog.Logger, cfg config.TasksConfig) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req TaskRequest
 
@@ -53,8 +77,28 @@ func StartTaskHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
 			return
 		}
 
+		req.Tags, err = normalizeTags(req.Tags)
+		if err == nil {
+			err = validateDescription(req.Description)
+		}
+		if err != nil {
+			log.Warn("Неверное описание или метки сессии", slog.Int("userID", req.UserID), slog.String("error", err.Error()))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		// Добавление новой задачи в базу данных с получением имени задачи
-		task, err := AddTaskToDBWithTaskName(log, db, req.UserID, req.IDTask)
+		task, stopped, err := AddTaskToDBWithTaskName(r.Context(), log, db, req, cfg.SingleActiveSession)
+		if errors.Is(err, errFullDayAbsence) {
+			log.Warn("Пользователь отсутствует весь день, начать задачу нельзя", slog.Int("userID", req.UserID), slog.Int("taskID", req.IDTask))
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if errors.Is(err, storage.ErrPeriodLocked) {
+			log.Warn("Период согласован, начать задачу нельзя", slog.Int("userID", req.UserID), slog.Int("taskID", req.IDTask))
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		if err != nil {
 			log.Error("Ошибка при добавлении задачи в базу данных", slog.Int("userID", req.UserID), slog.Int("taskID", req.IDTask), slog.String("error", err.Error()))
 			http.Error(w, fmt.Sprintf("Ошибка при добавлении задачи в базу данных: %v", err), http.StatusInternalServerError)
@@ -73,7 +117,10 @@ func StartTaskHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
 			return
 		}
 
-		// Добавление новой задачи в список задач пользователя
+		// Отражение автоматически завершенных сессий и добавление новой задачи в список задач пользователя
+		for _, s := range stopped {
+			user.UserTask = replaceSessionInCache(user.UserTask, s)
+		}
 		user.UserTask = append(user.UserTask, task)
 		cache.UserCache[req.UserID] = user
 
@@ -86,32 +133,160 @@ func StartTaskHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
 }
 
 // AddTaskToDBWithTaskName добавляет новую задачу в базу данных и возвращает созданную задачу.
-// Получает имя задачи из таблицы tasks по идентификатору задачи и вставляет запись в таблицу users_tasks.
-func AddTaskToDBWithTaskName(log *slog.Logger, db *sql.DB, userID int, taskID int) (tracker_model.UserTask, error) {
+// Получает имя задачи из таблицы tasks по идентификатору задачи и вставляет запись в таблицу users_tasks
+// с описанием и метками из запроса.
+// При singleActive в той же транзакции завершает незавершенные сессии пользователя временем начала
+// новой сессии и возвращает их вторым значением. Без req.OverrideAbsence возвращает errFullDayAbsence,
+// если на текущий день пользователя согласовано отсутствие на весь день.
+func AddTaskToDBWithTaskName(ctx context.Context, log *slog.Logger, db *sql.DB, req TaskRequest, singleActive bool) (tracker_model.UserTask, []tracker_model.UserTask, error) {
+	userID, taskID := req.UserID, req.IDTask
+
+	tx, err := db.Begin()
+	if err != nil {
+		return tracker_model.UserTask{}, nil, fmt.Errorf("ошибка при начале транзакции: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Блокировка пользователя, чтобы одновременные запуски задач выполнялись последовательно
+	if err := lockUser(tx, userID); err != nil {
+		return tracker_model.UserTask{}, nil, err
+	}
+
 	var taskName string
 	// Получение имени задачи из таблицы tasks по заданному ID
-	err := db.QueryRow(`SELECT task_name FROM tasks WHERE id_task = $1`, taskID).Scan(&taskName)
+	err = tx.QueryRow(`SELECT task_name FROM tasks WHERE id_task = $1`, taskID).Scan(&taskName)
 	if err != nil {
 		log.Error("Ошибка при получении имени задачи из базы данных", slog.Int("taskID", taskID), slog.String("error", err.Error()))
-		return tracker_model.UserTask{}, fmt.Errorf("ошибка при получении имени задачи из базы данных: %v", err)
+		return tracker_model.UserTask{}, nil, fmt.Errorf("ошибка при получении имени задачи из базы данных: %v", err)
 	}
 
 	startTime := time.Now() // Время начала отсчета
-	endTime := time.Time{}  // Время окончания пока не установлено
-	totalMinutes := 0       // Общее количество минут пока не установлено
-
-	var task tracker_model.UserTask
-	// Вставка новой задачи в таблицу users_tasks и возврат вставленной задачи
-	err = db.QueryRow(`
-		INSERT INTO users_tasks (user_id, id_task, task_name, start_time, end_time, total_minutes)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING user_id, id_task, task_name, start_time, end_time, total_minutes
-	`, userID, taskID, taskName, startTime, endTime, totalMinutes).Scan(&task.UserID, &task.IDTask, &task.TaskName, &task.StartTime, &task.EndTime, &task.TotalMinutes)
+
+	// Новая сессия не может появиться в уже согласованном периоде
+	if err := storage.CheckPeriodUnlocked(tx, userID, startTime, time.Time{}); err != nil {
+		return tracker_model.UserTask{}, nil, err
+	}
+
+	if !req.OverrideAbsence {
+		loc, err := storage.UserLocation(tx, userID)
+		if err != nil {
+			return tracker_model.UserTask{}, nil, err
+		}
+		absence, absent, err := storage.FullDayAbsence(tx, userID, startTime.In(loc).Format("2006-01-02"))
+		if err != nil {
+			return tracker_model.UserTask{}, nil, err
+		}
+		if absent {
+			return tracker_model.UserTask{}, nil, fmt.Errorf("%w: %s до %s", errFullDayAbsence, absence.Type, absence.EndDate.Format("2006-01-02"))
+		}
+	}
+
+	var stopped []tracker_model.UserTask
+	var budgets []tracker_model.TaskBudget
+	if singleActive {
+		stopped, budgets, err = stopOpenSessions(ctx, tx, userID, startTime)
+		if err != nil {
+			return tracker_model.UserTask{}, nil, err
+		}
+	}
+
+	// Вставка новой сессии в таблицу users_tasks и возврат вставленной сессии.
+	// Время окончания остается NULL, а длительность нулевой, пока сессия не будет завершена.
+	task, err := storage.ScanUserTask(tx.QueryRow(`
+		INSERT INTO users_tasks (user_id, id_task, task_name, start_time, end_time, total_seconds, description, tags)
+		VALUES ($1, $2, $3, $4, NULL, 0, $5, $6)
+		RETURNING `+storage.UserTaskColumns,
+		userID, taskID, taskName, startTime, req.Description, pq.Array(req.Tags)))
 	if err != nil {
 		log.Error("Ошибка при вставке задачи в базу данных", slog.Any("task", task), slog.String("error", err.Error()))
-		return task, fmt.Errorf("ошибка при вставке задачи в базу данных: %v", err)
+		return task, nil, fmt.Errorf("ошибка при вставке задачи в базу данных: %v", err)
 	}
 
+	// События о переключении и начале сессии сохраняются вместе с изменениями
+	for _, s := range stopped {
+		if err := outbox.EnqueueSession(tx, events.SessionPaused, s); err != nil {
+			return task, nil, err
+		}
+	}
+	if err := outbox.EnqueueSession(tx, events.SessionStarted, task); err != nil {
+		return task, nil, err
+	}
+	err = audit.Record(ctx, tx, audit.Change{Action: audit.ActionStarted, Entity: audit.EntitySession, EntityID: task.IDSession, UserID: userID, After: task})
+	if err != nil {
+		return task, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return task, nil, fmt.Errorf("ошибка при фиксации транзакции: %v", err)
+	}
+
+	for _, s := range stopped {
+		log.Info("Незавершенная сессия остановлена при переключении задачи", slog.Int("session_id", s.IDSession), slog.Int("taskID", s.IDTask), slog.Int64("total_seconds", s.TotalSeconds))
+	}
+	for _, budget := range budgets {
+		taskbudget.LogAlert(log, &budget)
+	}
 	log.Info("Задача успешно добавлена в базу данных", slog.Any("task", task))
-	return task, nil
+	return task, stopped, nil
+}
+
+// stopOpenSessions завершает все незавершенные сессии пользователя временем endTime
+// и возвращает их в обновленном виде вместе с бюджетами задач, достигшими очередного порога.
+// Сессия, начатая в согласованном периоде, не завершается: возвращается storage.ErrPeriodLocked.
+// Каждая остановка записывается в журнал аудита.
+func stopOpenSessions(ctx context.Context, tx *sql.Tx, userID int, endTime time.Time) ([]tracker_model.UserTask, []tracker_model.TaskBudget, error) {
+	rows, err := tx.Query(`
+		SELECT `+storage.UserTaskColumns+`
+		FROM users_tasks
+		WHERE user_id = $1 AND end_time IS NULL
+		FOR UPDATE
+	`, userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ошибка при получении незавершенных сессий: %v", err)
+	}
+	var open []tracker_model.UserTask
+	for rows.Next() {
+		s, err := storage.ScanUserTask(rows)
+		if err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("ошибка сканирования незавершенной сессии: %v", err)
+		}
+		open = append(open, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("ошибка итерации по незавершенным сессиям: %v", err)
+	}
+
+	var budgets []tracker_model.TaskBudget
+	for i := range open {
+		if err := storage.CheckPeriodUnlocked(tx, userID, open[i].StartTime, endTime); err != nil {
+			return nil, nil, err
+		}
+		before := open[i]
+		open[i].EndTime = endTime
+		open[i].TotalSeconds = sessionSeconds(open[i].StartTime, endTime)
+		open[i].TotalMinutes = int(open[i].TotalSeconds / 60)
+		_, err := tx.Exec(`
+			UPDATE users_tasks
+			SET end_time = $1, total_seconds = $2
+			WHERE id = $3
+		`, endTime, open[i].TotalSeconds, open[i].IDSession)
+		if err != nil {
+			return nil, nil, fmt.Errorf("ошибка при завершении сессии %d: %v", open[i].IDSession, err)
+		}
+		err = audit.Record(ctx, tx, audit.Change{Action: audit.ActionPaused, Entity: audit.EntitySession, EntityID: open[i].IDSession, UserID: userID, Before: before, After: open[i]})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		budget, err := taskbudget.Check(tx, userID, open[i].IDTask)
+		if err != nil {
+			return nil, nil, err
+		}
+		if budget != nil {
+			budgets = append(budgets, *budget)
+		}
+	}
+	return open, budgets, nil
 }
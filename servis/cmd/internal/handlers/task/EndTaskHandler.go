@@ -1,15 +1,23 @@
 package task
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
 	"log/slog"
 
+	"github.com/lib/pq"
+	"main.go/cmd/internal/audit"
+	"main.go/cmd/internal/events"
+	"main.go/cmd/internal/outbox"
+	"main.go/cmd/internal/storage"
 	"main.go/cmd/internal/storage/cache"
+	"main.go/cmd/internal/taskbudget"
 	model "main.go/tracker_model"
 )
 
@@ -24,7 +32,8 @@ import (
 // @Param request body TaskRequest true "Данные для завершения задачи"
 // @Success 200 {object} UserTask "Информация о задаче"
 // @Failure 400 {string} string "Неверный формат ввода"
-// @Failure 404 {string} string "Пользователь не найден в кэше"
+// @Failure 404 {string} string "Нет незавершенной сессии или пользователь не найден в кэше"
+// @Failure 409 {string} string "Период согласован и заблокирован"
 // @Failure 500 {string} string "Ошибка при обновлении задачи"
 // @Router /api/v1/tasks/end [post]
 func EndTaskHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
@@ -42,45 +51,45 @@ func EndTaskHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
 			return
 		}
 
-		log.Info("Начато обновление времени окончания задачи", slog.Int("user_id", req.UserID), slog.Int("task_id", req.IDTask))
-
-		// Обновление времени окончания задачи в базе данных
-		err = updateTaskEndTime(db, req.UserID, req.IDTask)
+		req.Tags, err = normalizeTags(req.Tags)
+		if err == nil {
+			err = validateDescription(req.Description)
+		}
 		if err != nil {
-			log.Error("Ошибка при обновлении времени окончания задачи в базе данных", slog.String("error", err.Error()))
-			http.Error(w, fmt.Sprintf("Ошибка при обновлении времени окончания задачи: %v", err), http.StatusInternalServerError)
+			log.Warn("Неверное описание или метки сессии", slog.Int("user_id", req.UserID), slog.String("error", err.Error()))
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		log.Info("Время окончания задачи обновлено", slog.Int("user_id", req.UserID), slog.Int("task_id", req.IDTask))
+		log.Info("Начато обновление времени окончания задачи", slog.Int("user_id", req.UserID), slog.Int("task_id", req.IDTask))
 
-		// Получение информации о задаче из базы данных для вычисления времени выполнения
-		task, err := getTaskFromDB(db, req.UserID, req.IDTask)
+		// Завершение незавершенной сессии в базе данных с вычислением общего времени выполнения
+		task, budget, err := endTaskInDB(r.Context(), db, req)
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("Нет незавершенной сессии по задаче", slog.Int("user_id", req.UserID), slog.Int("task_id", req.IDTask))
+			http.Error(w, "Нет незавершенной сессии по задаче", http.StatusNotFound)
+			return
+		}
+		var validationErr sessionValidationError
+		if errors.As(err, &validationErr) {
+			log.Warn("Неверные метки сессии", slog.Int("user_id", req.UserID), slog.String("error", err.Error()))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, storage.ErrPeriodLocked) {
+			log.Warn("Период согласован, завершить задачу нельзя", slog.Int("user_id", req.UserID), slog.Int("task_id", req.IDTask))
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		if err != nil {
-			log.Error("Ошибка при получении задачи из базы данных", slog.String("error", err.Error()))
-			http.Error(w, fmt.Sprintf("Ошибка при получении задачи из базы данных: %v", err), http.StatusInternalServerError)
+			log.Error("Ошибка при обновлении времени окончания задачи в базе данных", slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Ошибка при обновлении времени окончания задачи: %v", err), http.StatusInternalServerError)
 			return
 		}
 
-		log.Info("Задача получена из базы данных", slog.Int("user_id", req.UserID), slog.Int("task_id", req.IDTask))
+		log.Info("Время окончания задачи обновлено", slog.Int("user_id", req.UserID), slog.Int("task_id", req.IDTask), slog.Int64("total_seconds", task.TotalSeconds))
 		log.Debug("Информация о задаче", slog.Any("task", task))
-
-		if !task.StartTime.IsZero() && !task.EndTime.IsZero() {
-			// Вычисление общего времени выполнения задачи в минутах
-			task.TotalMinutes = int(task.EndTime.Sub(task.StartTime).Minutes())
-
-			log.Info("Общее время выполнения задачи вычислено", slog.Int("total_minutes", task.TotalMinutes))
-
-			// Обновление total_minutes в базе данных
-			err = updateTaskTotalMinutes(db, req.UserID, req.IDTask, task.TotalMinutes)
-			if err != nil {
-				log.Error("Ошибка при обновлении total_minutes задачи в базе данных", slog.String("error", err.Error()))
-				http.Error(w, fmt.Sprintf("Ошибка при обновлении total_minutes в базе данных: %v", err), http.StatusInternalServerError)
-				return
-			}
-
-			log.Info("total_minutes задачи обновлено в базе данных", slog.Int("user_id", req.UserID), slog.Int("task_id", req.IDTask))
-		}
+		taskbudget.LogAlert(log, budget)
 
 		// Обновление кэша
 		cache.UserCacheMutex.Lock()
@@ -93,14 +102,8 @@ func EndTaskHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
 			return
 		}
 
-		// Найти задачу в кэше и обновить время окончания и общее время выполнения
-		for i := range user.UserTask {
-			if user.UserTask[i].IDTask == req.IDTask {
-				user.UserTask[i].EndTime = time.Now()
-				user.UserTask[i].TotalMinutes = task.TotalMinutes
-				break
-			}
-		}
+		// Замена сессии в кэше завершенной: время окончания, общее время, описание и метки
+		user.UserTask = replaceSessionInCache(user.UserTask, task)
 
 		cache.UserCache[req.UserID] = user
 
@@ -117,44 +120,73 @@ func EndTaskHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
 	}
 }
 
-// updateTaskEndTime обновляет время окончания задачи в базе данных.
-// Устанавливает текущее время как время окончания задачи.
-func updateTaskEndTime(db *sql.DB, userID int, taskID int) error {
-	_, err := db.Exec(`
-		UPDATE users_tasks
-		SET end_time = $1
-		WHERE user_id = $2 AND id_task = $3
-	`, time.Now(), userID, taskID)
+// endTaskInDB в одной транзакции завершает последнюю незавершенную сессию по задаче текущим временем,
+// вычисляет общее время выполнения, дополняет описание и метки сессии из запроса
+// и записывает событие о завершении в outbox.
+// Если задача при этом достигла очередного порога бюджета, записывает и событие об этом
+// и возвращает бюджет задачи вторым значением, иначе второе значение nil.
+// Если незавершенной сессии нет, возвращается ошибка, оборачивающая sql.ErrNoRows,
+// если сессия относится к согласованному периоду — storage.ErrPeriodLocked.
+func endTaskInDB(ctx context.Context, db *sql.DB, req TaskRequest) (model.UserTask, *model.TaskBudget, error) {
+	userID, taskID := req.UserID, req.IDTask
+
+	tx, err := db.Begin()
 	if err != nil {
-		return fmt.Errorf("ошибка при обновлении времени окончания задачи в базе данных: %v", err)
+		return model.UserTask{}, nil, fmt.Errorf("ошибка при начале транзакции: %v", err)
 	}
-	return nil
-}
+	defer tx.Rollback()
 
-// getTaskFromDB получает информацию о задаче из базы данных по идентификатору пользователя и задачи.
-func getTaskFromDB(db *sql.DB, userID int, taskID int) (model.UserTask, error) {
-	var task model.UserTask
-	err := db.QueryRow(`
-		SELECT user_id, id_task, task_name, start_time, end_time, total_minutes
+	task, err := storage.ScanUserTask(tx.QueryRow(`
+		SELECT `+storage.UserTaskColumns+`
 		FROM users_tasks
-		WHERE user_id = $1 AND id_task = $2
-	`, userID, taskID).Scan(&task.UserID, &task.IDTask, &task.TaskName, &task.StartTime, &task.EndTime, &task.TotalMinutes)
+		WHERE user_id = $1 AND id_task = $2 AND end_time IS NULL
+		ORDER BY start_time DESC
+		LIMIT 1
+		FOR UPDATE
+	`, userID, taskID))
 	if err != nil {
-		return task, fmt.Errorf("ошибка при получении задачи из базы данных: %v", err)
+		return task, nil, fmt.Errorf("ошибка при получении незавершенной сессии из базы данных: %w", err)
+	}
+
+	endTime := time.Now()
+	if err := storage.CheckPeriodUnlocked(tx, userID, task.StartTime, endTime); err != nil {
+		return task, nil, err
+	}
+
+	before := task
+	if req.Description != "" {
+		task.Description = req.Description
+	}
+	tags, err := normalizeTags(append(task.Tags, req.Tags...))
+	if err != nil {
+		return task, nil, sessionValidationError{msg: err.Error()}
 	}
-	return task, nil
-}
 
-// updateTaskTotalMinutes обновляет общее время выполнения задачи в базе данных.
-// Устанавливает значение total_minutes для задачи.
-func updateTaskTotalMinutes(db *sql.DB, userID int, taskID int, totalMinutes int) error {
-	_, err := db.Exec(`
+	task, err = storage.ScanUserTask(tx.QueryRow(`
 		UPDATE users_tasks
-		SET total_minutes = $1
-		WHERE user_id = $2 AND id_task = $3
-	`, totalMinutes, userID, taskID)
+		SET end_time = $1, total_seconds = $2, description = $3, tags = $4
+		WHERE id = $5
+		RETURNING `+storage.UserTaskColumns,
+		endTime, sessionSeconds(task.StartTime, endTime), task.Description, pq.Array(tags), task.IDSession))
 	if err != nil {
-		return fmt.Errorf("ошибка при обновлении total_minutes задачи в базе данных: %v", err)
+		return task, nil, fmt.Errorf("ошибка при обновлении времени окончания задачи в базе данных: %v", err)
+	}
+
+	if err := outbox.EnqueueSession(tx, events.SessionEnded, task); err != nil {
+		return task, nil, err
+	}
+	err = audit.Record(ctx, tx, audit.Change{Action: audit.ActionEnded, Entity: audit.EntitySession, EntityID: task.IDSession, UserID: userID, Before: before, After: task})
+	if err != nil {
+		return task, nil, err
+	}
+
+	budget, err := taskbudget.Check(tx, userID, taskID)
+	if err != nil {
+		return task, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return task, nil, fmt.Errorf("ошибка при фиксации транзакции: %v", err)
 	}
-	return nil
+	return task, budget, nil
 }
@@ -0,0 +1,76 @@
+package task
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"main.go/cmd/internal/storage"
+	model "main.go/tracker_model"
+)
+
+// GetReviewSessionsHandler обрабатывает запросы на получение сессий, требующих проверки:
+// сессий, завершенных автоматически и еще не исправленных через /update_session.
+// @Summary Сессии, требующие проверки
+// @Description Возвращает автоматически завершенные сессии, опционально по одному пользователю.
+// @Tags Task
+// @Produce json
+// @Param user_id query int false "Идентификатор пользователя"
+// @Success 200 {array} UserTask "Список сессий"
+// @Failure 400 {string} string "Неверные параметры запроса"
+// @Failure 500 {string} string "Ошибка при выполнении запроса к базе данных"
+// @Router /api/v1/sessions/review [get]
+func GetReviewSessionsHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userIDStr := r.URL.Query().Get("user_id")
+
+		query := "SELECT " + storage.UserTaskColumns + " FROM users_tasks WHERE auto_closed"
+		args := []interface{}{}
+
+		if userIDStr != "" {
+			userID, err := strconv.Atoi(userIDStr)
+			if err != nil {
+				log.Error("Неверный формат user_id", slog.String("error", err.Error()))
+				http.Error(w, "Invalid user_id", http.StatusBadRequest)
+				return
+			}
+			query += " AND user_id = $1"
+			args = append(args, userID)
+		}
+
+		query += " ORDER BY start_time"
+
+		rows, err := db.Query(query, args...)
+		if err != nil {
+			log.Error("Ошибка выполнения запроса к базе данных", slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Database query failed: %v", err), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		sessions := []model.UserTask{}
+		for rows.Next() {
+			session, err := storage.ScanUserTask(rows)
+			if err != nil {
+				log.Error("Ошибка сканирования строки результата", slog.String("error", err.Error()))
+				http.Error(w, fmt.Sprintf("Failed to scan row: %v", err), http.StatusInternalServerError)
+				return
+			}
+			sessions = append(sessions, session)
+		}
+
+		if err = rows.Err(); err != nil {
+			log.Error("Ошибка итерации по строкам результата", slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Row iteration error: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		log.Info("Получен список сессий, требующих проверки", slog.Int("count", len(sessions)))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(sessions)
+	}
+}
@@ -0,0 +1,70 @@
+package task
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"main.go/cmd/internal/taskbudget"
+	model "main.go/tracker_model"
+)
+
+// GetTaskBudgetsHandler обрабатывает запросы на получение затраченного времени в сравнении с оценкой.
+// Без id_task возвращает все задачи с оценкой, с id_task — указанную задачу, даже если оценки у нее нет.
+// @Summary Бюджеты задач
+// @Description Возвращает оценку, затраченное время, остаток и процент использования бюджета по задачам.
+// @Tags Task
+// @Produce json
+// @Param id_task query int false "Идентификатор задачи"
+// @Success 200 {array} model.TaskBudget "Бюджеты задач"
+// @Failure 400 {string} string "Неверный идентификатор задачи"
+// @Failure 500 {string} string "Ошибка при выполнении запроса к базе данных"
+// @Router /api/v1/tasks/budgets [get]
+func GetTaskBudgetsHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		taskID := 0
+		if s := r.URL.Query().Get("id_task"); s != "" {
+			var err error
+			taskID, err = strconv.Atoi(s)
+			if err != nil {
+				log.Error("Неверный идентификатор задачи", slog.String("id_task", s), slog.String("error", err.Error()))
+				http.Error(w, "Invalid id_task", http.StatusBadRequest)
+				return
+			}
+		}
+
+		rows, err := db.Query(taskbudget.Query+`
+			WHERE ($1 = 0 AND t.estimated_seconds IS NOT NULL) OR t.id_task = $1
+			ORDER BY t.id_task
+		`, taskID)
+		if err != nil {
+			log.Error("Ошибка выполнения запроса к базе данных", slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Database query failed: %v", err), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		budgets := []model.TaskBudget{}
+		for rows.Next() {
+			budget, err := taskbudget.Scan(rows)
+			if err != nil {
+				log.Error("Ошибка сканирования строки результата", slog.String("error", err.Error()))
+				http.Error(w, fmt.Sprintf("Failed to scan row: %v", err), http.StatusInternalServerError)
+				return
+			}
+			budgets = append(budgets, budget)
+		}
+
+		if err = rows.Err(); err != nil {
+			log.Error("Ошибка итерации по строкам результата", slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Row iteration error: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(budgets)
+	}
+}
@@ -3,17 +3,29 @@ package task
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"log/slog"
 
+	"github.com/lib/pq"
+	"main.go/cmd/internal/rounding"
+	"main.go/cmd/internal/storage"
 	model "main.go/tracker_model"
 )
 
-// GetUserTaskSummaryHandler обрабатывает запросы на получение трудозатрат по пользователю за период
+// GetUserTaskSummaryHandler обрабатывает запросы на получение трудозатрат по пользователю за период.
+// В ответ попадают все сессии, пересекающиеся с периодом, а total_seconds и total_minutes
+// содержат только время внутри периода, округленное по политике policy.
+// Сессии можно отфильтровать по меткам (tag, все указанные) и подстроке описания (q).
+// С include=absences ответ содержит объект с сессиями и согласованными отсутствиями за период,
+// с group_by=tag — трудозатраты по меткам.
 
 // @Summary Получение трудозатрат по пользователю за период
 // @Description Возвращает список задач пользователя с их трудозатратами за указанный период времени.
@@ -21,13 +33,20 @@ import (
 // @Accept json
 // @Produce json
 // @Param user_id query int true "Идентификатор пользователя"
-// @Param start_date query string true "Дата начала периода в формате YYYY-MM-DD"
-// @Param end_date query string true "Дата окончания периода в формате YYYY-MM-DD"
+// @Param start_date query string true "Начало периода: дата YYYY-MM-DD или время RFC3339"
+// @Param end_date query string true "Конец периода включительно: дата YYYY-MM-DD или время RFC3339"
+// @Param tz query string false "Часовой пояс IANA для дат периода, по умолчанию часовой пояс пользователя"
+// @Param include query string false "absences — добавить согласованные отсутствия за период"
+// @Param tag query []string false "Метки сессии, сессия должна иметь все указанные метки"
+// @Param q query string false "Подстрока описания сессии"
+// @Param group_by query string false "tag — трудозатраты по меткам"
 // @Success 200 {array} UserTask "Список трудозатрат пользователя"
+// @Success 200 {array} model.ReportRow "Трудозатраты по меткам при group_by=tag"
+// @Success 200 {object} model.UserSummary "Трудозатраты и отсутствия при include=absences"
 // @Failure 400 {string} string "Неверные параметры запроса"
 // @Failure 500 {string} string "Ошибка при выполнении запроса к базе данных"
 // @Router /api/v1/tasks/summary [get]
-func GetUserTaskSummaryHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
+func GetUserTaskSummaryHandler(db *sql.DB, log *slog.Logger, policy rounding.Policy) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Получение параметров запроса
 		userIDStr := r.URL.Query().Get("user_id")
@@ -51,41 +70,88 @@ func GetUserTaskSummaryHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
 			return
 		}
 
-		startDate, err := time.Parse("2006-01-02", startDateStr)
+		// Даты периода интерпретируются в часовом поясе tz, по умолчанию — в поясе пользователя
+		var loc *time.Location
+		if tz := r.URL.Query().Get("tz"); tz != "" {
+			loc, err = storage.LoadTimezone(tz)
+		} else {
+			loc, err = storage.UserLocation(db, userID)
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("Пользователь не найден", slog.Int("user_id", userID))
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Warn("Неверный часовой пояс", slog.String("error", err.Error()))
+			http.Error(w, "Invalid tz", http.StatusBadRequest)
+			return
+		}
+
+		startDate, _, err := parseRangeBound(startDateStr, false, loc)
 		if err != nil {
 			log.Error("Неверный формат start_date", slog.String("error", err.Error()))
 			http.Error(w, "Invalid start_date format", http.StatusBadRequest)
 			return
 		}
 
-		endDate, err := time.Parse("2006-01-02", endDateStr)
+		endDate, endInclusive, err := parseRangeBound(endDateStr, true, loc)
 		if err != nil {
 			log.Error("Неверный формат end_date", slog.String("error", err.Error()))
 			http.Error(w, "Invalid end_date format", http.StatusBadRequest)
 			return
 		}
 
+		if !endDate.After(startDate) {
+			log.Warn("Пустой период", slog.Time("start_date", startDate), slog.Time("end_date", endDate))
+			http.Error(w, "end_date must be after start_date", http.StatusBadRequest)
+			return
+		}
+
+		groupBy := r.URL.Query().Get("group_by")
+		if groupBy != "" && groupBy != "tag" {
+			log.Warn("Неверное значение group_by", slog.String("group_by", groupBy))
+			http.Error(w, "Invalid group_by, expected: tag", http.StatusBadRequest)
+			return
+		}
+		if groupBy != "" && r.URL.Query().Get("include") != "" {
+			log.Warn("group_by нельзя использовать вместе с include")
+			http.Error(w, "group_by cannot be combined with include", http.StatusBadRequest)
+			return
+		}
+
+		tagFilter := []string{}
+		for _, tag := range r.URL.Query()["tag"] {
+			tagFilter = append(tagFilter, strings.ToLower(strings.TrimSpace(tag)))
+		}
+		// Подстрока описания ищется буквально: % и _ в q не являются шаблоном
+		descriptionFilter := storage.EscapeLike(r.URL.Query().Get("q"))
+
 		log.Debug("Параметры запроса успешно преобразованы", slog.Int("user_id", userID), slog.Time("start_date", startDate), slog.Time("end_date", endDate))
 
-		// Выполнение запроса к базе данных
+		// Выполнение запроса к базе данных. Выбираются сессии, пересекающиеся с периодом
+		// [start_date, end_date) или [start_date, end_date], если конец задан временем RFC3339;
+		// незавершенные сессии считаются длящимися до текущего момента.
+		// Последней колонкой вычисляются секунды сессии внутри периода.
+		startBefore := "<"
+		if endInclusive {
+			startBefore = "<="
+		}
 		query := `
-		SELECT 
-			user_id,
-			id_task,
-			task_name,
-			start_time,
-			end_time,
-			total_minutes
+		SELECT ` + storage.UserTaskColumns + `,
+			GREATEST(0, FLOOR(EXTRACT(EPOCH FROM LEAST(COALESCE(end_time, $4), $3) - GREATEST(start_time, $2))))::bigint AS seconds_in_range
 		FROM 
 			users_tasks
 		WHERE 
 			user_id = $1 AND
-			start_time >= $2 AND 
-			(end_time <= $3 OR end_time IS NULL)
+			start_time ` + startBefore + ` $3 AND 
+			COALESCE(end_time, $4) > $2 AND
+			tags @> $5 AND
+			($6 = '' OR description ILIKE '%' || $6 || '%')
 		ORDER BY 
-			total_minutes DESC;
+			seconds_in_range DESC;
 		`
-		rows, err := db.Query(query, userID, startDate, endDate)
+		rows, err := db.Query(query, userID, startDate, endDate, time.Now(), pq.Array(tagFilter), descriptionFilter)
 		if err != nil {
 			log.Error("Ошибка выполнения запроса к базе данных", slog.String("error", err.Error()))
 			http.Error(w, fmt.Sprintf("Database query failed: %v", err), http.StatusInternalServerError)
@@ -97,13 +163,16 @@ func GetUserTaskSummaryHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
 
 		var summaries []model.UserTask
 		for rows.Next() {
-			var summary model.UserTask
-			err := rows.Scan(&summary.UserID, &summary.IDTask, &summary.TaskName, &summary.StartTime, &summary.EndTime, &summary.TotalMinutes)
+			var secondsInRange int64
+			summary, err := storage.ScanUserTask(clippedRow{rows: rows, seconds: &secondsInRange})
 			if err != nil {
 				log.Error("Ошибка сканирования строки результата", slog.String("error", err.Error()))
 				http.Error(w, fmt.Sprintf("Failed to scan row: %v", err), http.StatusInternalServerError)
 				return
 			}
+			// В трудозатраты попадает только время внутри периода
+			summary.TotalSeconds = policy.Apply(secondsInRange)
+			summary.TotalMinutes = int(summary.TotalSeconds / 60)
 			summaries = append(summaries, summary)
 		}
 
@@ -116,6 +185,30 @@ func GetUserTaskSummaryHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
 
 		log.Debug("Сформирован список трудозатрат", slog.Any("summaries", summaries))
 
+		if groupBy == "tag" {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(groupByTag(summaries, loc))
+			log.Info("Ответ успешно отправлен", slog.Int("user_id", userID), slog.String("group_by", groupBy))
+			return
+		}
+
+		if r.URL.Query().Get("include") == "absences" {
+			lastMoment := endDate
+			if !endInclusive {
+				lastMoment = endDate.Add(-time.Nanosecond)
+			}
+			summary, err := withAbsences(db, userID, summaries, startDate, lastMoment, loc)
+			if err != nil {
+				log.Error("Ошибка при получении отсутствий", slog.Int("user_id", userID), slog.String("error", err.Error()))
+				http.Error(w, fmt.Sprintf("Database query failed: %v", err), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(summary)
+			log.Info("Ответ успешно отправлен", slog.Int("user_id", userID), slog.Float64("absence_days", summary.AbsenceDays))
+			return
+		}
+
 		// Установка заголовка и кодирование ответа в JSON
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(summaries)
@@ -123,3 +216,91 @@ func GetUserTaskSummaryHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
 		log.Info("Ответ успешно отправлен", slog.Int("user_id", userID))
 	}
 }
+
+// withAbsences дополняет сессии согласованными отсутствиями пользователя, пересекающимися с периодом
+// [startDate, lastMoment], и считает дни отсутствия внутри периода по календарю часового пояса loc.
+func withAbsences(db *sql.DB, userID int, sessions []model.UserTask, startDate, lastMoment time.Time, loc *time.Location) (model.UserSummary, error) {
+	summary := model.UserSummary{Sessions: sessions}
+	if summary.Sessions == nil {
+		summary.Sessions = []model.UserTask{}
+	}
+
+	// Календарные даты периода в поясе loc, представленные в UTC, как и даты отсутствий
+	from := storage.LocalDate(startDate.In(loc), time.UTC)
+	to := storage.LocalDate(lastMoment.In(loc), time.UTC)
+
+	absences, err := storage.ApprovedAbsences(db, userID, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return summary, err
+	}
+	summary.Absences = absences
+	for _, absence := range absences {
+		summary.AbsenceDays += storage.AbsenceDays(absence, from, to)
+	}
+	return summary, nil
+}
+
+// groupByTag суммирует трудозатраты сессий по меткам. Сессия с несколькими метками учитывается
+// в каждой из них, сессии без меток собираются в группу с пустым ключом. Рабочие дни считаются
+// по дате начала сессии в часовом поясе loc. Группы упорядочены по убыванию времени.
+func groupByTag(sessions []model.UserTask, loc *time.Location) []model.ReportRow {
+	rows := []model.ReportRow{}
+	index := map[string]int{}
+	days := map[string]map[string]bool{}
+	for _, s := range sessions {
+		tags := s.Tags
+		if len(tags) == 0 {
+			tags = []string{""}
+		}
+		for _, tag := range tags {
+			i, ok := index[tag]
+			if !ok {
+				i = len(rows)
+				index[tag] = i
+				rows = append(rows, model.ReportRow{Key: tag})
+				days[tag] = map[string]bool{}
+			}
+			rows[i].TotalSeconds += s.TotalSeconds
+			rows[i].Sessions++
+			days[tag][s.StartTime.In(loc).Format("2006-01-02")] = true
+		}
+	}
+	for i := range rows {
+		row := &rows[i]
+		row.TotalMinutes = int(row.TotalSeconds / 60)
+		row.Hours, row.Minutes = row.TotalMinutes/60, row.TotalMinutes%60
+		row.WorkingDays = len(days[row.Key])
+		row.AveragePerDayMin = math.Round(float64(row.TotalSeconds)/60/float64(row.WorkingDays)*10) / 10
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].TotalSeconds > rows[j].TotalSeconds })
+	return rows
+}
+
+// parseRangeBound разбирает границу периода, заданную датой YYYY-MM-DD или временем RFC3339.
+// Время RFC3339 входит в период, и inclusive равен true. Дата интерпретируется в часовом поясе loc;
+// для конца периода (end) дата включается целиком, то есть граница сдвигается на начало
+// следующего дня, не входящее в период.
+func parseRangeBound(s string, end bool, loc *time.Location) (t time.Time, inclusive bool, err error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, true, nil
+	}
+	t, err = time.ParseInLocation("2006-01-02", s, loc)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, false, nil
+}
+
+// clippedRow дополняет строку результата колонкой секунд внутри периода,
+// следующей за колонками storage.UserTaskColumns.
+type clippedRow struct {
+	rows    *sql.Rows
+	seconds *int64
+}
+
+func (c clippedRow) Scan(dest ...interface{}) error {
+	return c.rows.Scan(append(dest, c.seconds)...)
+}
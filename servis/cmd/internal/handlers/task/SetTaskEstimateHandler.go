@@ -0,0 +1,133 @@
+package task
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"main.go/cmd/internal/audit"
+	"main.go/cmd/internal/taskbudget"
+	model "main.go/tracker_model"
+)
+
+// EstimateInput представляет данные запроса на установку оценки задачи.
+type EstimateInput struct {
+	EstimatedHours *float64 `json:"estimated_hours"` // Оценка трудозатрат в часах, null — снять оценку
+}
+
+// SetTaskEstimateHandler обрабатывает запросы на установку оценки трудозатрат по задаче.
+// Изменение оценки сбрасывает отправленные оповещения, и пороги бюджета отслеживаются заново.
+// @Summary Установка оценки задачи
+// @Description Устанавливает или снимает оценку трудозатрат по задаче в часах и возвращает бюджет задачи.
+// @Tags Task
+// @Accept json
+// @Produce json
+// @Param id path int true "Идентификатор задачи"
+// @Param estimate body EstimateInput true "Оценка"
+// @Success 200 {object} model.TaskBudget "Бюджет задачи"
+// @Failure 400 {string} string "Неверный формат ввода"
+// @Failure 404 {string} string "Задача не найдена"
+// @Failure 500 {string} string "Ошибка при установке оценки"
+// @Router /api/v1/tasks/{id}/estimate [put]
+func SetTaskEstimateHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		taskID, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			log.Error("Неверный идентификатор задачи", slog.String("id", r.PathValue("id")), slog.String("error", err.Error()))
+			http.Error(w, "Неверный идентификатор задачи", http.StatusBadRequest)
+			return
+		}
+
+		var input EstimateInput
+		err = json.NewDecoder(r.Body).Decode(&input)
+		if err != nil {
+			log.Error("Неверный формат ввода", slog.String("error", err.Error()))
+			http.Error(w, "Неверный формат ввода", http.StatusBadRequest)
+			return
+		}
+
+		var estimated sql.NullInt64
+		if input.EstimatedHours != nil {
+			seconds := int64(*input.EstimatedHours * 3600)
+			if seconds <= 0 {
+				log.Warn("Оценка задачи должна быть положительной", slog.Int("task_id", taskID), slog.Float64("estimated_hours", *input.EstimatedHours))
+				http.Error(w, "estimated_hours must be positive", http.StatusBadRequest)
+				return
+			}
+			estimated = sql.NullInt64{Int64: seconds, Valid: true}
+		}
+
+		budget, err := setTaskEstimate(r.Context(), db, taskID, estimated)
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("Задача не найдена", slog.Int("task_id", taskID))
+			http.Error(w, "Задача не найдена", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Error("Ошибка при установке оценки задачи", slog.Int("task_id", taskID), slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Ошибка при установке оценки задачи: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		log.Info("Оценка задачи установлена", slog.Int("task_id", taskID), slog.Int64("estimated_seconds", budget.EstimatedSeconds))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(budget)
+	}
+}
+
+// setTaskEstimate сохраняет оценку задачи, сбрасывает порог отправленных оповещений и возвращает бюджет задачи.
+// Если задача не найдена, возвращается ошибка, оборачивающая sql.ErrNoRows.
+func setTaskEstimate(ctx context.Context, db *sql.DB, taskID int, estimated sql.NullInt64) (model.TaskBudget, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return model.TaskBudget{}, fmt.Errorf("ошибка при начале транзакции: %v", err)
+	}
+	defer tx.Rollback()
+
+	var previous sql.NullInt64
+	err = tx.QueryRow(`SELECT estimated_seconds FROM tasks WHERE id_task = $1 FOR UPDATE`, taskID).Scan(&previous)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.TaskBudget{}, fmt.Errorf("задача %d: %w", taskID, sql.ErrNoRows)
+	}
+	if err != nil {
+		return model.TaskBudget{}, fmt.Errorf("ошибка при получении оценки задачи: %v", err)
+	}
+
+	_, err = tx.Exec(`
+		UPDATE tasks SET estimated_seconds = $1, budget_alert_percent = 0 WHERE id_task = $2
+	`, estimated, taskID)
+	if err != nil {
+		return model.TaskBudget{}, fmt.Errorf("ошибка при сохранении оценки задачи: %v", err)
+	}
+
+	err = audit.Record(ctx, tx, audit.Change{Action: audit.ActionUpdated, Entity: audit.EntityTask, EntityID: taskID,
+		Before: map[string]interface{}{"estimated_seconds": nullSeconds(previous)},
+		After:  map[string]interface{}{"estimated_seconds": nullSeconds(estimated)}})
+	if err != nil {
+		return model.TaskBudget{}, err
+	}
+
+	budget, err := taskbudget.Scan(tx.QueryRow(taskbudget.Query+` WHERE t.id_task = $1`, taskID))
+	if err != nil {
+		return budget, fmt.Errorf("ошибка при получении бюджета задачи: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return budget, fmt.Errorf("ошибка при фиксации транзакции: %v", err)
+	}
+	return budget, nil
+}
+
+// nullSeconds возвращает оценку для журнала аудита: nil, если оценка не задана.
+func nullSeconds(v sql.NullInt64) interface{} {
+	if !v.Valid {
+		return nil
+	}
+	return v.Int64
+}
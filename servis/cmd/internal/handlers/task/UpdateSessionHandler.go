@@ -0,0 +1,240 @@
+package task
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"main.go/cmd/internal/audit"
+	"main.go/cmd/internal/events"
+	"main.go/cmd/internal/outbox"
+	"main.go/cmd/internal/storage"
+	"main.go/cmd/internal/storage/cache"
+	"main.go/cmd/internal/taskbudget"
+	model "main.go/tracker_model"
+)
+
+// SessionTimeRequest представляет данные запроса на исправление времени сессии.
+// Нулевое значение поля означает, что соответствующее значение не изменяется.
+type SessionTimeRequest struct {
+	StartTime   time.Time `json:"start_time"`  // Новое время начала в формате RFC3339
+	EndTime     time.Time `json:"end_time"`    // Новое время окончания в формате RFC3339
+	Billable    *bool     `json:"billable"`    // Признак оплачиваемой сессии
+	Description *string   `json:"description"` // Новое описание сессии
+	Tags        *[]string `json:"tags"`        // Новый список меток, заменяющий прежний
+}
+
+// UpdateSessionHandler обрабатывает HTTP запросы на исправление времени существующей сессии.
+// Исправление снимает отметку auto_closed: сессия считается проверенной пользователем.
+
+// Проверяет отсутствие пересечений с другими сессиями пользователя, пересчитывает total_seconds и обновляет кэш.
+// @Summary Исправление времени сессии
+// @Description Изменяет время начала и/или окончания, описание и метки сессии и пересчитывает общее время выполнения.
+// @Tags Task
+// @Accept json
+// @Produce json
+// @Param id path int true "Идентификатор сессии"
+// @Param session body SessionTimeRequest true "Новое время сессии"
+// @Success 200 {object} UserTask "Обновленная сессия"
+// @Failure 400 {string} string "Неверный формат ввода или интервал"
+// @Failure 404 {string} string "Сессия не найдена"
+// @Failure 409 {string} string "Сессия пересекается с другой сессией, период согласован или сессия выставлена в счете"
+// @Failure 500 {string} string "Ошибка при обновлении сессии"
+// @Router /api/v1/sessions/{id} [put]
+func UpdateSessionHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := strings.TrimPrefix(r.URL.Path, "/update_session/")
+		sessionID, err := strconv.Atoi(idStr)
+		if err != nil {
+			log.Error("Неверный идентификатор сессии", slog.String("idStr", idStr), slog.String("error", err.Error()))
+			http.Error(w, "Неверный идентификатор сессии", http.StatusBadRequest)
+			return
+		}
+
+		var req SessionTimeRequest
+		err = json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			log.Error("Неверный формат ввода", slog.String("error", err.Error()))
+			http.Error(w, "Неверный формат ввода", http.StatusBadRequest)
+			return
+		}
+
+		if req.Tags != nil {
+			tags, err := normalizeTags(*req.Tags)
+			if err != nil {
+				log.Warn("Неверные метки сессии", slog.Int("session_id", sessionID), slog.String("error", err.Error()))
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			req.Tags = &tags
+		}
+		if req.Description != nil {
+			if err := validateDescription(*req.Description); err != nil {
+				log.Warn("Неверное описание сессии", slog.Int("session_id", sessionID), slog.String("error", err.Error()))
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		task, budget, err := updateSessionTimeInDB(r.Context(), db, sessionID, req)
+		var validationErr sessionValidationError
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			log.Warn("Сессия не найдена", slog.Int("session_id", sessionID))
+			http.Error(w, "Сессия не найдена", http.StatusNotFound)
+			return
+		case errors.As(err, &validationErr):
+			log.Warn("Неверный интервал сессии", slog.Int("session_id", sessionID), slog.String("error", err.Error()))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, errSessionOverlap), errors.Is(err, storage.ErrPeriodLocked), errors.Is(err, errSessionInvoiced):
+			log.Warn("Сессия не может быть изменена", slog.Int("session_id", sessionID), slog.String("error", err.Error()))
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			log.Error("Ошибка при обновлении сессии", slog.Int("session_id", sessionID), slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Ошибка при обновлении сессии: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		log.Info("Время сессии исправлено", slog.Int("session_id", sessionID), slog.Int64("total_seconds", task.TotalSeconds))
+		taskbudget.LogAlert(log, budget)
+
+		// Обновление кэша
+		cache.UserCacheMutex.Lock()
+		if user, exists := cache.UserCache[task.UserID]; exists {
+			user.UserTask = replaceSessionInCache(user.UserTask, task)
+			cache.UserCache[task.UserID] = user
+		} else {
+			log.Warn("Пользователь не найден в кэше", slog.Int("user_id", task.UserID))
+		}
+		cache.UserCacheMutex.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(task)
+	}
+}
+
+// sessionValidationError ошибка проверки интервала сессии, возвращаемая клиенту как 400.
+type sessionValidationError struct {
+	msg string
+}
+
+func (e sessionValidationError) Error() string {
+	return e.msg
+}
+
+// updateSessionTimeInDB в одной транзакции блокирует сессию, проверяет новый интервал
+// и отсутствие пересечений, затем сохраняет время и пересчитанный total_seconds. Для завершенной
+// сессии проверяется бюджет задачи; возвращается бюджет с достигнутым порогом либо nil.
+func updateSessionTimeInDB(ctx context.Context, db *sql.DB, sessionID int, req SessionTimeRequest) (model.UserTask, *model.TaskBudget, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return model.UserTask{}, nil, fmt.Errorf("ошибка при начале транзакции: %v", err)
+	}
+	defer tx.Rollback()
+
+	task, err := storage.ScanUserTask(tx.QueryRow(`
+		SELECT `+storage.UserTaskColumns+`
+		FROM users_tasks
+		WHERE id = $1
+		FOR UPDATE
+	`, sessionID))
+	if err != nil {
+		return task, nil, fmt.Errorf("ошибка при получении сессии из базы данных: %w", err)
+	}
+
+	if err := lockUser(tx, task.UserID); err != nil {
+		return task, nil, err
+	}
+
+	if task.Status == model.StatusApproved {
+		return task, nil, storage.ErrPeriodLocked
+	}
+	if task.InvoiceID != 0 {
+		return task, nil, errSessionInvoiced
+	}
+	// Сессия не может ни покидать согласованный период, ни попадать в него:
+	// проверяются все недели прежнего и нового интервала
+	if err := storage.CheckPeriodUnlocked(tx, task.UserID, task.StartTime, task.EndTime); err != nil {
+		return task, nil, err
+	}
+
+	before := task
+	if !req.StartTime.IsZero() {
+		task.StartTime = req.StartTime
+	}
+	if !req.EndTime.IsZero() {
+		task.EndTime = req.EndTime
+	}
+	if err := storage.CheckPeriodUnlocked(tx, task.UserID, task.StartTime, task.EndTime); err != nil {
+		return task, nil, err
+	}
+
+	if !task.EndTime.IsZero() {
+		if err := validateSessionInterval(task.StartTime, task.EndTime); err != nil {
+			return task, nil, sessionValidationError{msg: err.Error()}
+		}
+	} else if task.StartTime.After(time.Now()) {
+		return task, nil, sessionValidationError{msg: "start_time не может быть в будущем"}
+	}
+
+	if err := checkSessionOverlap(tx, task.UserID, task.IDSession, task.StartTime, task.EndTime); err != nil {
+		return task, nil, err
+	}
+
+	var endTime sql.NullTime
+	if !task.EndTime.IsZero() {
+		endTime = sql.NullTime{Time: task.EndTime, Valid: true}
+	}
+	task.TotalSeconds = sessionSeconds(task.StartTime, task.EndTime)
+	if req.Billable != nil {
+		task.Billable = *req.Billable
+	}
+	if req.Description != nil {
+		task.Description = *req.Description
+	}
+	if req.Tags != nil {
+		task.Tags = *req.Tags
+	}
+
+	task, err = storage.ScanUserTask(tx.QueryRow(`
+		UPDATE users_tasks
+		SET start_time = $1, end_time = $2, total_seconds = $3, billable = $4, auto_closed = FALSE,
+			description = $5, tags = $6
+		WHERE id = $7
+		RETURNING `+storage.UserTaskColumns,
+		task.StartTime, endTime, task.TotalSeconds, task.Billable, task.Description, pq.Array(task.Tags), sessionID))
+	if err != nil {
+		return task, nil, fmt.Errorf("ошибка при обновлении сессии в базе данных: %v", err)
+	}
+
+	if err := outbox.EnqueueSession(tx, events.SessionEdited, task); err != nil {
+		return task, nil, err
+	}
+	err = audit.Record(ctx, tx, audit.Change{Action: audit.ActionUpdated, Entity: audit.EntitySession, EntityID: task.IDSession, UserID: task.UserID, Before: before, After: task})
+	if err != nil {
+		return task, nil, err
+	}
+
+	var budget *model.TaskBudget
+	if !task.EndTime.IsZero() {
+		budget, err = taskbudget.Check(tx, task.UserID, task.IDTask)
+		if err != nil {
+			return task, nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return task, nil, fmt.Errorf("ошибка при фиксации транзакции: %v", err)
+	}
+	return task, budget, nil
+}
@@ -0,0 +1,117 @@
+package task
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	model "main.go/tracker_model"
+)
+
+// errSessionOverlap возвращается, если интервал сессии пересекается с другой сессией пользователя.
+var errSessionOverlap = errors.New("сессия пересекается с другой сессией пользователя")
+
+// errSessionInvoiced возвращается при попытке изменить сессию, уже вошедшую в счет.
+var errSessionInvoiced = errors.New("сессия уже выставлена в счете и не может быть изменена")
+
+// errFullDayAbsence возвращается при запуске задачи в день согласованного отсутствия на весь день.
+var errFullDayAbsence = errors.New("на сегодня согласовано отсутствие на весь день")
+
+// sessionSeconds вычисляет длительность сессии в секундах.
+// Для незавершенной сессии возвращает 0.
+func sessionSeconds(startTime, endTime time.Time) int64 {
+	if startTime.IsZero() || endTime.IsZero() {
+		return 0
+	}
+	return int64(endTime.Sub(startTime) / time.Second)
+}
+
+// lockUser блокирует строку пользователя до конца транзакции, чтобы проверки пересечения
+// сессий одного пользователя выполнялись последовательно.
+func lockUser(tx *sql.Tx, userID int) error {
+	var id int
+	err := tx.QueryRow(`SELECT id FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&id)
+	if err != nil {
+		return fmt.Errorf("ошибка при блокировке пользователя: %w", err)
+	}
+	return nil
+}
+
+// checkSessionOverlap проверяет, что интервал [startTime, endTime) не пересекается с другими
+// сессиями пользователя. Нулевой endTime означает незавершенную сессию без верхней границы,
+// незавершенные сессии в базе также считаются продолжающимися. Сессия excludeID из проверки исключается.
+func checkSessionOverlap(tx *sql.Tx, userID, excludeID int, startTime, endTime time.Time) error {
+	var end sql.NullTime
+	if !endTime.IsZero() {
+		end = sql.NullTime{Time: endTime, Valid: true}
+	}
+
+	var overlap bool
+	err := tx.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM users_tasks
+			WHERE user_id = $1 AND id <> $2
+				AND ($4::timestamptz IS NULL OR start_time < $4)
+				AND (end_time IS NULL OR end_time > $3)
+		)
+	`, userID, excludeID, startTime, end).Scan(&overlap)
+	if err != nil {
+		return fmt.Errorf("ошибка при проверке пересечения сессий: %v", err)
+	}
+	if overlap {
+		return errSessionOverlap
+	}
+	return nil
+}
+
+// replaceSessionInCache заменяет сессию в списке задач пользователя или добавляет ее, если она не найдена.
+func replaceSessionInCache(tasks []model.UserTask, task model.UserTask) []model.UserTask {
+	for i := range tasks {
+		if tasks[i].IDSession == task.IDSession {
+			tasks[i] = task
+			return tasks
+		}
+	}
+	return append(tasks, task)
+}
+
+// Ограничения на метки и описание сессии.
+const (
+	maxTags           = 20
+	maxTagLength      = 50
+	maxDescriptionLen = 2000
+)
+
+// normalizeTags приводит метки к нижнему регистру, удаляет пробелы по краям и повторы
+// с сохранением порядка и проверяет ограничения на количество и длину меток.
+func normalizeTags(tags []string) ([]string, error) {
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" {
+			return nil, errors.New("метка не может быть пустой")
+		}
+		if utf8.RuneCountInString(tag) > maxTagLength {
+			return nil, fmt.Errorf("метка %q длиннее %d символов", tag, maxTagLength)
+		}
+		if !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	if len(normalized) > maxTags {
+		return nil, fmt.Errorf("у сессии может быть не более %d меток", maxTags)
+	}
+	return normalized, nil
+}
+
+// validateDescription проверяет длину описания сессии.
+func validateDescription(description string) error {
+	if utf8.RuneCountInString(description) > maxDescriptionLen {
+		return fmt.Errorf("описание длиннее %d символов", maxDescriptionLen)
+	}
+	return nil
+}
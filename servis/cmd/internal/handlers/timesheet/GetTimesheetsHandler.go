@@ -0,0 +1,84 @@
+package timesheet
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"main.go/cmd/internal/storage"
+	model "main.go/tracker_model"
+)
+
+// GetTimesheetsHandler обрабатывает запросы на получение списка табелей с фильтрацией
+// по пользователю и статусу, например табелей, ожидающих согласования.
+// @Summary Список табелей
+// @Description Возвращает табели, отсортированные по неделе от новых к старым.
+// @Tags Timesheet
+// @Produce json
+// @Param user_id query int false "Идентификатор пользователя"
+// @Param status query string false "Статус табеля (submitted, approved, rejected)"
+// @Success 200 {array} model.Timesheet "Список табелей"
+// @Failure 400 {string} string "Неверные параметры запроса"
+// @Failure 500 {string} string "Ошибка при выполнении запроса к базе данных"
+// @Router /api/v1/timesheets [get]
+func GetTimesheetsHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userIDStr := r.URL.Query().Get("user_id")
+		status := r.URL.Query().Get("status")
+
+		query := "SELECT " + storage.TimesheetColumns + " FROM timesheets WHERE 1=1"
+		args := []interface{}{}
+		argID := 1
+
+		if userIDStr != "" {
+			userID, err := strconv.Atoi(userIDStr)
+			if err != nil {
+				log.Error("Неверный формат user_id", slog.String("error", err.Error()))
+				http.Error(w, "Invalid user_id", http.StatusBadRequest)
+				return
+			}
+			query += fmt.Sprintf(" AND user_id = $%d", argID)
+			args = append(args, userID)
+			argID++
+		}
+
+		if status != "" {
+			query += fmt.Sprintf(" AND status = $%d", argID)
+			args = append(args, status)
+			argID++
+		}
+
+		query += " ORDER BY week_start DESC, id DESC"
+
+		rows, err := db.Query(query, args...)
+		if err != nil {
+			log.Error("Ошибка выполнения запроса к базе данных", slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Database query failed: %v", err), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		timesheets := []model.Timesheet{}
+		for rows.Next() {
+			timesheet, err := storage.ScanTimesheet(rows)
+			if err != nil {
+				log.Error("Ошибка сканирования строки результата", slog.String("error", err.Error()))
+				http.Error(w, fmt.Sprintf("Failed to scan row: %v", err), http.StatusInternalServerError)
+				return
+			}
+			timesheets = append(timesheets, timesheet)
+		}
+
+		if err = rows.Err(); err != nil {
+			log.Error("Ошибка итерации по строкам результата", slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Row iteration error: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(timesheets)
+	}
+}
@@ -0,0 +1,207 @@
+package timesheet
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"main.go/cmd/internal/audit"
+	"main.go/cmd/internal/handlers/util"
+	"main.go/cmd/internal/storage"
+	model "main.go/tracker_model"
+)
+
+// ReviewRequest представляет решение руководителя по табелю.
+type ReviewRequest struct {
+	Comment string `json:"comment"` // Комментарий руководителя, обязателен при отклонении
+}
+
+// ApproveTimesheetHandler обрабатывает запросы руководителя на согласование табеля.
+// После согласования сессии недели блокируются от изменений.
+// @Summary Согласование табеля
+// @Description Согласует табель; руководитель передается в заголовке X-User-ID.
+// @Tags Timesheet
+// @Accept json
+// @Produce json
+// @Param X-User-ID header int true "Идентификатор руководителя"
+// @Param id path int true "Идентификатор табеля"
+// @Param request body ReviewRequest false "Комментарий"
+// @Success 200 {object} model.Timesheet "Согласованный табель"
+// @Failure 400 {string} string "Неверный формат ввода"
+// @Failure 403 {string} string "Недостаточно прав"
+// @Failure 404 {string} string "Табель не найден"
+// @Failure 409 {string} string "Табель не ожидает согласования"
+// @Failure 500 {string} string "Ошибка при согласовании табеля"
+// @Router /api/v1/timesheets/{id}/approve [post]
+func ApproveTimesheetHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
+	return reviewTimesheetHandler(db, log, "/approve_timesheet/", model.StatusApproved)
+}
+
+// RejectTimesheetHandler обрабатывает запросы руководителя на отклонение табеля.
+// Отклоненные сессии снова доступны для исправления и повторной отправки.
+// @Summary Отклонение табеля
+// @Description Отклоняет табель с обязательным комментарием; руководитель передается в заголовке X-User-ID.
+// @Tags Timesheet
+// @Accept json
+// @Produce json
+// @Param X-User-ID header int true "Идентификатор руководителя"
+// @Param id path int true "Идентификатор табеля"
+// @Param request body ReviewRequest true "Комментарий"
+// @Success 200 {object} model.Timesheet "Отклоненный табель"
+// @Failure 400 {string} string "Неверный формат ввода"
+// @Failure 403 {string} string "Недостаточно прав"
+// @Failure 404 {string} string "Табель не найден"
+// @Failure 409 {string} string "Табель не ожидает согласования"
+// @Failure 500 {string} string "Ошибка при отклонении табеля"
+// @Router /api/v1/timesheets/{id}/reject [post]
+func RejectTimesheetHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
+	return reviewTimesheetHandler(db, log, "/reject_timesheet/", model.StatusRejected)
+}
+
+// reviewTimesheetHandler общая реализация согласования и отклонения табеля.
+func reviewTimesheetHandler(db *sql.DB, log *slog.Logger, prefix string, decision string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := strings.TrimPrefix(r.URL.Path, prefix)
+		timesheetID, err := strconv.Atoi(idStr)
+		if err != nil {
+			log.Error("Неверный идентификатор табеля", slog.String("idStr", idStr), slog.String("error", err.Error()))
+			http.Error(w, "Неверный идентификатор табеля", http.StatusBadRequest)
+			return
+		}
+
+		managerID, err := util.ActorID(r)
+		if err != nil {
+			log.Warn("Не указан руководитель", slog.String("error", err.Error()))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var req ReviewRequest
+		if r.ContentLength != 0 {
+			err = json.NewDecoder(r.Body).Decode(&req)
+			if err != nil {
+				log.Error("Неверный формат ввода", slog.String("error", err.Error()))
+				http.Error(w, "Неверный формат ввода", http.StatusBadRequest)
+				return
+			}
+		}
+
+		if decision == model.StatusRejected && strings.TrimSpace(req.Comment) == "" {
+			log.Warn("Не указан комментарий к отклонению", slog.Int("timesheet_id", timesheetID))
+			http.Error(w, "Комментарий обязателен при отклонении табеля", http.StatusBadRequest)
+			return
+		}
+
+		var role string
+		err = db.QueryRow(`SELECT role FROM users WHERE id = $1`, managerID).Scan(&role)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Error("Ошибка при получении роли пользователя", slog.Int("manager_id", managerID), slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Ошибка при получении роли пользователя: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if role != model.RoleManager {
+			log.Warn("Пользователь не является руководителем", slog.Int("manager_id", managerID))
+			http.Error(w, "Недостаточно прав", http.StatusForbidden)
+			return
+		}
+
+		timesheet, err := reviewTimesheet(r.Context(), db, timesheetID, managerID, decision, req.Comment)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			log.Warn("Табель не найден", slog.Int("timesheet_id", timesheetID))
+			http.Error(w, "Табель не найден", http.StatusNotFound)
+			return
+		case errors.Is(err, errSelfReview):
+			log.Warn("Руководитель не может согласовать собственный табель", slog.Int("timesheet_id", timesheetID))
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		case errors.Is(err, errNotSubmitted):
+			log.Warn("Табель не ожидает согласования", slog.Int("timesheet_id", timesheetID))
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			log.Error("Ошибка при рассмотрении табеля", slog.Int("timesheet_id", timesheetID), slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Ошибка при рассмотрении табеля: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		if decision == model.StatusApproved {
+			updateCachedStatuses(timesheet.UserID, timesheet.WeekStart, model.StatusApproved,
+				model.StatusDraft, model.StatusSubmitted, model.StatusRejected)
+		} else {
+			updateCachedStatuses(timesheet.UserID, timesheet.WeekStart, model.StatusRejected,
+				model.StatusDraft, model.StatusSubmitted)
+		}
+
+		log.Info("Решение по табелю принято", slog.Int("timesheet_id", timesheet.ID), slog.String("status", timesheet.Status), slog.Int("manager_id", managerID))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(timesheet)
+	}
+}
+
+// errSelfReview возвращается, если руководитель пытается рассмотреть собственный табель.
+var errSelfReview = errors.New("руководитель не может рассматривать собственный табель")
+
+// reviewTimesheet в одной транзакции фиксирует решение по табелю и обновляет статусы сессий недели.
+// При согласовании в статус approved переводятся все сессии недели, при отклонении — все несогласованные.
+// Решение записывается в журнал аудита как действие.
+func reviewTimesheet(ctx context.Context, db *sql.DB, timesheetID, managerID int, decision, comment string) (model.Timesheet, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return model.Timesheet{}, fmt.Errorf("ошибка при начале транзакции: %v", err)
+	}
+	defer tx.Rollback()
+
+	timesheet, err := storage.ScanTimesheet(tx.QueryRow(`
+		SELECT `+storage.TimesheetColumns+` FROM timesheets WHERE id = $1 FOR UPDATE
+	`, timesheetID))
+	if err != nil {
+		return timesheet, fmt.Errorf("ошибка при получении табеля: %w", err)
+	}
+	if timesheet.UserID == managerID {
+		return timesheet, errSelfReview
+	}
+	if timesheet.Status != model.StatusSubmitted {
+		return timesheet, errNotSubmitted
+	}
+
+	before := timesheet
+	timesheet, err = storage.ScanTimesheet(tx.QueryRow(`
+		UPDATE timesheets
+		SET status = $1, comment = $2, manager_id = $3, decided_at = $4
+		WHERE id = $5
+		RETURNING `+storage.TimesheetColumns,
+		decision, comment, managerID, time.Now(), timesheetID))
+	if err != nil {
+		return timesheet, fmt.Errorf("ошибка при обновлении табеля: %v", err)
+	}
+
+	if decision == model.StatusApproved {
+		err = setSessionStatuses(tx, timesheet.UserID, timesheet.WeekStart, model.StatusApproved,
+			model.StatusDraft, model.StatusSubmitted, model.StatusRejected)
+	} else {
+		err = setSessionStatuses(tx, timesheet.UserID, timesheet.WeekStart, model.StatusRejected,
+			model.StatusDraft, model.StatusSubmitted)
+	}
+	if err != nil {
+		return timesheet, err
+	}
+
+	err = audit.Record(ctx, tx, audit.Change{Action: decision, Entity: audit.EntityTimesheet, EntityID: timesheet.ID, UserID: timesheet.UserID, Before: before, After: timesheet})
+	if err != nil {
+		return timesheet, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return timesheet, fmt.Errorf("ошибка при фиксации транзакции: %v", err)
+	}
+	return timesheet, nil
+}
@@ -0,0 +1,223 @@
+package timesheet
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/lib/pq"
+	"main.go/cmd/internal/audit"
+	"main.go/cmd/internal/storage"
+	"main.go/cmd/internal/storage/cache"
+	model "main.go/tracker_model"
+)
+
+var (
+	// errAlreadySubmitted возвращается при повторной отправке табеля, ожидающего согласования.
+	errAlreadySubmitted = errors.New("табель уже отправлен на согласование")
+	// errRunningSessions возвращается, если в неделе есть незавершенные сессии.
+	errRunningSessions = errors.New("в неделе есть незавершенные сессии")
+	// errNotSubmitted возвращается при согласовании табеля, который не ожидает решения.
+	errNotSubmitted = errors.New("табель не ожидает согласования")
+)
+
+// SubmitRequest представляет данные запроса на отправку недельного табеля на согласование.
+type SubmitRequest struct {
+	UserID    int    `json:"user_id"`    // Идентификатор пользователя
+	WeekStart string `json:"week_start"` // Любая дата недели в формате YYYY-MM-DD
+}
+
+// SubmitTimesheetHandler обрабатывает запросы на отправку недельного табеля пользователя на согласование.
+
+// Переводит табель и все черновые и отклоненные сессии недели в статус submitted.
+// @Summary Отправка табеля на согласование
+// @Description Отправляет сессии пользователя за неделю на согласование руководителю.
+// @Tags Timesheet
+// @Accept json
+// @Produce json
+// @Param request body SubmitRequest true "Пользователь и неделя"
+// @Success 200 {object} model.Timesheet "Отправленный табель"
+// @Failure 400 {string} string "Неверный формат ввода"
+// @Failure 404 {string} string "Пользователь не найден"
+// @Failure 409 {string} string "Табель уже отправлен, согласован или в неделе есть незавершенные сессии"
+// @Failure 500 {string} string "Ошибка при отправке табеля"
+// @Router /api/v1/timesheets/submit [post]
+func SubmitTimesheetHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req SubmitRequest
+
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			log.Error("Неверный формат ввода", slog.String("error", err.Error()))
+			http.Error(w, "Неверный формат ввода", http.StatusBadRequest)
+			return
+		}
+
+		weekDate, err := time.Parse("2006-01-02", req.WeekStart)
+		if err != nil {
+			log.Error("Неверный формат week_start", slog.String("error", err.Error()))
+			http.Error(w, "Invalid week_start format", http.StatusBadRequest)
+			return
+		}
+		weekStart := storage.WeekStart(weekDate)
+
+		timesheet, err := submitTimesheet(r.Context(), db, req.UserID, weekStart)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			log.Warn("Пользователь не найден", slog.Int("user_id", req.UserID))
+			http.Error(w, "Пользователь не найден", http.StatusNotFound)
+			return
+		case errors.Is(err, errAlreadySubmitted), errors.Is(err, errRunningSessions), errors.Is(err, storage.ErrPeriodLocked):
+			log.Warn("Табель не может быть отправлен", slog.Int("user_id", req.UserID), slog.String("error", err.Error()))
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			log.Error("Ошибка при отправке табеля", slog.Int("user_id", req.UserID), slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Ошибка при отправке табеля: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		updateCachedStatuses(req.UserID, weekStart, model.StatusSubmitted, model.StatusDraft, model.StatusRejected)
+
+		log.Info("Табель отправлен на согласование", slog.Int("timesheet_id", timesheet.ID), slog.Int("user_id", req.UserID))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(timesheet)
+	}
+}
+
+// submitTimesheet в одной транзакции создает или повторно отправляет табель за неделю
+// и переводит сессии недели в статус submitted.
+func submitTimesheet(ctx context.Context, db *sql.DB, userID int, weekStart time.Time) (model.Timesheet, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return model.Timesheet{}, fmt.Errorf("ошибка при начале транзакции: %v", err)
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRow(`SELECT id FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&id)
+	if err != nil {
+		return model.Timesheet{}, fmt.Errorf("ошибка при получении пользователя: %w", err)
+	}
+
+	// Неделя начинается в полночь понедельника по часовому поясу пользователя
+	loc, err := storage.UserLocation(tx, userID)
+	if err != nil {
+		return model.Timesheet{}, err
+	}
+	weekFrom := storage.LocalDate(weekStart, loc)
+	weekTo := weekFrom.AddDate(0, 0, 7)
+
+	var running bool
+	err = tx.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM users_tasks
+			WHERE user_id = $1 AND start_time >= $2 AND start_time < $3 AND end_time IS NULL
+		)
+	`, userID, weekFrom, weekTo).Scan(&running)
+	if err != nil {
+		return model.Timesheet{}, fmt.Errorf("ошибка при проверке незавершенных сессий: %v", err)
+	}
+	if running {
+		return model.Timesheet{}, errRunningSessions
+	}
+
+	// Прежнее состояние повторно отправляемого табеля сохраняется в журнале аудита
+	var before *model.Timesheet
+	previous, err := storage.ScanTimesheet(tx.QueryRow(`
+		SELECT `+storage.TimesheetColumns+` FROM timesheets WHERE user_id = $1 AND week_start = $2 FOR UPDATE
+	`, userID, weekStart))
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return model.Timesheet{}, fmt.Errorf("ошибка при получении табеля: %v", err)
+	case previous.Status == model.StatusApproved:
+		return model.Timesheet{}, storage.ErrPeriodLocked
+	case previous.Status == model.StatusSubmitted:
+		return model.Timesheet{}, errAlreadySubmitted
+	default:
+		before = &previous
+	}
+
+	timesheet, err := storage.ScanTimesheet(tx.QueryRow(`
+		INSERT INTO timesheets (user_id, week_start, status, comment, submitted_at)
+		VALUES ($1, $2, $3, '', $4)
+		ON CONFLICT (user_id, week_start) DO UPDATE
+		SET status = EXCLUDED.status, comment = '', manager_id = NULL,
+			submitted_at = EXCLUDED.submitted_at, decided_at = NULL
+		RETURNING `+storage.TimesheetColumns,
+		userID, weekStart, model.StatusSubmitted, time.Now()))
+	if err != nil {
+		return timesheet, fmt.Errorf("ошибка при сохранении табеля: %v", err)
+	}
+
+	err = setSessionStatuses(tx, userID, weekStart, model.StatusSubmitted, model.StatusDraft, model.StatusRejected)
+	if err != nil {
+		return timesheet, err
+	}
+
+	err = audit.Record(ctx, tx, audit.Change{Action: audit.ActionSubmitted, Entity: audit.EntityTimesheet, EntityID: timesheet.ID, UserID: userID, Before: before, After: timesheet})
+	if err != nil {
+		return timesheet, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return timesheet, fmt.Errorf("ошибка при фиксации транзакции: %v", err)
+	}
+	return timesheet, nil
+}
+
+// setSessionStatuses переводит сессии пользователя, начатые в течение недели по его часовому поясу,
+// в статус status. Изменяются только сессии в статусах from.
+func setSessionStatuses(tx *sql.Tx, userID int, weekStart time.Time, status string, from ...string) error {
+	loc, err := storage.UserLocation(tx, userID)
+	if err != nil {
+		return err
+	}
+	weekFrom := storage.LocalDate(weekStart, loc)
+
+	_, err = tx.Exec(`
+		UPDATE users_tasks
+		SET status = $1
+		WHERE user_id = $2 AND start_time >= $3 AND start_time < $4 AND status = ANY($5)
+	`, status, userID, weekFrom, weekFrom.AddDate(0, 0, 7), pq.Array(from))
+	if err != nil {
+		return fmt.Errorf("ошибка при обновлении статусов сессий: %v", err)
+	}
+	return nil
+}
+
+// updateCachedStatuses повторяет в кэше изменение статусов сессий, выполненное setSessionStatuses.
+func updateCachedStatuses(userID int, weekStart time.Time, status string, from ...string) {
+	cache.UserCacheMutex.Lock()
+	defer cache.UserCacheMutex.Unlock()
+
+	user, exists := cache.UserCache[userID]
+	if !exists {
+		return
+	}
+
+	loc, err := storage.LoadTimezone(user.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	for i, task := range user.UserTask {
+		if !storage.WeekStart(task.StartTime.In(loc)).Equal(weekStart) {
+			continue
+		}
+		for _, s := range from {
+			if task.Status == s {
+				user.UserTask[i].Status = status
+				break
+			}
+		}
+	}
+	cache.UserCache[userID] = user
+}
@@ -3,6 +3,7 @@ package user
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
@@ -10,12 +11,20 @@ import (
 	"log/slog"
 
 	"main.go/cmd/internal/handlers/util"
+	"main.go/cmd/internal/passport"
+	"main.go/cmd/internal/storage"
 	"main.go/cmd/internal/storage/cache"
 	model "main.go/tracker_model"
 )
 
 type UserInput struct {
 	PassportNumber string `json:"passportNumber"`
+	Timezone       string `json:"timezone"` // Часовой пояс IANA, по умолчанию UTC
+}
+
+// LogValue implements slog.LogValuer so that the passport never reaches the log.
+func (i UserInput) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("passportNumber", passport.Redacted), slog.String("timezone", i.Timezone))
 }
 
 // @Summary Add a new user
@@ -26,10 +35,11 @@ type UserInput struct {
 // @Param user body UserInput true "User Input"
 // @Success 201 {integer} int "User ID"
 // @Failure 400 {string} string "Invalid input"
+// @Failure 409 {string} string "User with this passport already exists"
 // @Failure 500 {string} string "Failed to add user"
 // @Router /api/v1/users [post]
 // addUserHandler обрабатывает запросы на добавление нового пользователя
-func AddUserHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
+func AddUserHandler(db *sql.DB, log *slog.Logger, cipher *passport.Cipher) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var input UserInput
 
@@ -43,6 +53,15 @@ func AddUserHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
 
 		log.Debug("Received user input", slog.Any("input", input))
 
+		if input.Timezone == "" {
+			input.Timezone = storage.DefaultTimezone
+		}
+		if _, err := storage.LoadTimezone(input.Timezone); err != nil {
+			log.Warn("Invalid timezone", slog.String("timezone", input.Timezone))
+			http.Error(w, "Invalid timezone", http.StatusBadRequest)
+			return
+		}
+
 		// Разделение серии и номера паспорта
 		parts := strings.Split(input.PassportNumber, " ")
 		if len(parts) != 2 {
@@ -65,6 +84,12 @@ func AddUserHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
 			return
 		}
 
+		if err := passport.Validate(passportSerie, passportNumber); err != nil {
+			log.Warn("Invalid passport", slog.String("error", err.Error()))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		log.Debug("Parsed passport details", slog.Int("passportSerie", passportSerie), slog.Int("passportNumber", passportNumber))
 
 		// Получение информации о пользователе из внешнего API
@@ -78,7 +103,12 @@ func AddUserHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
 		log.Debug("Received API response", slog.Any("apiResponse", apiResponse))
 
 		// Вставка нового пользователя в базу данных
-		userID, err := util.AddUserToDB(log, db, passportSerie, passportNumber, apiResponse)
+		userID, err := util.AddUserToDB(r.Context(), log, db, cipher, passportSerie, passportNumber, input.Timezone, apiResponse)
+		if errors.Is(err, util.ErrPassportExists) {
+			log.Warn("User with this passport already exists")
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		if err != nil {
 			log.Error("Failed to add user to database", slog.String("error", err.Error()))
 			http.Error(w, "Failed to add user", http.StatusInternalServerError)
@@ -87,16 +117,17 @@ func AddUserHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
 
 		log.Info("User added to database", slog.Int("userID", userID))
 
-		// Обновление кэша
-		cache.CacheUser(model.Users{
-			UserID:         userID,
-			PassportSerie:  passportSerie,
-			PassportNumber: passportNumber,
-			Surname:        apiResponse.Surname,
-			Name:           apiResponse.Name,
-			Patronymic:     apiResponse.Patronymic,
-			Address:        apiResponse.Address,
-		})
+		// Обновление кэша; паспортные данные в кэше не хранятся
+		user := model.Users{
+			UserID:     userID,
+			Surname:    apiResponse.Surname,
+			Name:       apiResponse.Name,
+			Patronymic: apiResponse.Patronymic,
+			Address:    apiResponse.Address,
+			Role:       model.RoleEmployee,
+			Timezone:   input.Timezone,
+		}
+		cache.CacheUser(user)
 
 		log.Info("User cached", slog.Int("userID", userID))
 
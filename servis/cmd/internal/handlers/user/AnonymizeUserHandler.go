@@ -0,0 +1,189 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"main.go/cmd/internal/audit"
+	"main.go/cmd/internal/events"
+	"main.go/cmd/internal/handlers/util"
+	"main.go/cmd/internal/outbox"
+	"main.go/cmd/internal/storage/cache"
+	model "main.go/tracker_model"
+)
+
+// errAlreadyAnonymized is returned when the user has already been anonymized.
+var errAlreadyAnonymized = errors.New("user is already anonymized")
+
+// AnonymizeResult describes what was scrubbed by the anonymization.
+type AnonymizeResult struct {
+	UserID       int       `json:"user_id"`
+	AnonymizedAt time.Time `json:"anonymized_at"`
+	Sessions     int64     `json:"sessions"`
+	Events       int64     `json:"events"`
+	AuditEntries int64     `json:"audit_entries"`
+}
+
+// @Summary Anonymize a user
+// @Description Erase personal data of a user on their request: name, address and passport are cleared,
+// @Description session descriptions and absence and timesheet comments are removed, user data and session
+// @Description descriptions are removed from all outbox events of the user, published or not, and personal values
+// @Description in the audit log are redacted. Sessions keep their hours, task and tags for aggregate reports.
+// @Description Only managers may anonymize users. The operation cannot be undone.
+// @Tags User
+// @Produce json
+// @Param id path int true "User ID"
+// @Param X-User-ID header int true "Manager ID"
+// @Success 200 {object} AnonymizeResult "Anonymization result"
+// @Failure 400 {string} string "Invalid user ID or caller"
+// @Failure 403 {string} string "Access denied"
+// @Failure 404 {string} string "User not found"
+// @Failure 409 {string} string "User is already anonymized"
+// @Failure 500 {string} string "Failed to anonymize user"
+// @Router /api/v1/users/{id}/anonymize [post]
+func AnonymizeUserHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := r.PathValue("id")
+		userID, err := strconv.Atoi(idStr)
+		if err != nil {
+			log.Error("Invalid user ID", slog.String("idStr", idStr), slog.String("error", err.Error()))
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		actorID, err := util.ActorID(r)
+		if err != nil {
+			log.Warn("Missing caller", slog.String("error", err.Error()))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		role, err := userRole(db, actorID)
+		if err != nil {
+			log.Error("Failed to check caller role", slog.Int("actorID", actorID), slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Failed to check caller role: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if role != model.RoleManager {
+			log.Warn("Only managers may anonymize users", slog.Int("userID", userID), slog.Int("actorID", actorID))
+			http.Error(w, "Access denied", http.StatusForbidden)
+			return
+		}
+
+		result, err := anonymizeUser(r.Context(), db, userID)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			log.Warn("User not found", slog.Int("userID", userID))
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		case errors.Is(err, errAlreadyAnonymized):
+			log.Warn("User is already anonymized", slog.Int("userID", userID))
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			log.Error("Failed to anonymize user", slog.Int("userID", userID), slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Failed to anonymize user: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		// Обновление кэша: личные поля и описания сессий очищаются так же, как в базе
+		cache.UserCacheMutex.Lock()
+		if user, ok := cache.UserCache[userID]; ok {
+			user.Surname, user.Name, user.Patronymic, user.Address = "", "", "", ""
+			for i := range user.UserTask {
+				user.UserTask[i].Description = ""
+			}
+			cache.UserCache[userID] = user
+		}
+		cache.UserCacheMutex.Unlock()
+
+		log.Info("User anonymized", slog.Int("userID", userID), slog.Int("actorID", actorID),
+			slog.Int64("sessions", result.Sessions), slog.Int64("events", result.Events))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+// anonymizeUser scrubs personal data of the user in one transaction.
+func anonymizeUser(ctx context.Context, db *sql.DB, userID int) (AnonymizeResult, error) {
+	result := AnonymizeResult{UserID: userID}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return result, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var anonymizedAt sql.NullTime
+	err = tx.QueryRow(`SELECT anonymized_at FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&anonymizedAt)
+	if err != nil {
+		return result, err
+	}
+	if anonymizedAt.Valid {
+		return result, errAlreadyAnonymized
+	}
+
+	err = tx.QueryRow(`
+		UPDATE users
+		SET surname = '', name = '', patronymic = NULL, address = '',
+			passport_serie = NULL, passport_number = NULL, passport_encrypted = NULL,
+			passport_hash = NULL, passport_serie_hash = NULL, passport_number_hash = NULL,
+			anonymized_at = NOW()
+		WHERE id = $1
+		RETURNING anonymized_at`, userID).Scan(&result.AnonymizedAt)
+	if err != nil {
+		return result, fmt.Errorf("failed to scrub user: %v", err)
+	}
+
+	// Часы, задачи и метки сессий сохраняются для отчетов, свободный текст удаляется
+	res, err := tx.Exec(`UPDATE users_tasks SET description = '' WHERE user_id = $1`, userID)
+	if err != nil {
+		return result, fmt.Errorf("failed to scrub sessions: %v", err)
+	}
+	result.Sessions, _ = res.RowsAffected()
+
+	if _, err := tx.Exec(`UPDATE absences SET comment = '' WHERE user_id = $1`, userID); err != nil {
+		return result, fmt.Errorf("failed to scrub absences: %v", err)
+	}
+	if _, err := tx.Exec(`UPDATE timesheets SET comment = '' WHERE user_id = $1`, userID); err != nil {
+		return result, fmt.Errorf("failed to scrub timesheets: %v", err)
+	}
+
+	// Из событий пользователя в любом состоянии (опубликованных, ожидающих и исчерпавших попытки)
+	// удаляются данные пользователя и описания сессий. Доставки по webhook-подпискам берут тело
+	// события из outbox, поэтому еще не доставленные события уходят уже очищенными.
+	res, err = tx.Exec(`
+		UPDATE outbox
+		SET payload = ((payload::jsonb #- '{session,description}') - 'user')::text
+		WHERE user_id = $1`, userID)
+	if err != nil {
+		return result, fmt.Errorf("failed to scrub events: %v", err)
+	}
+	result.Events, _ = res.RowsAffected()
+
+	if err := outbox.EnqueueUser(tx, events.UserAnonymized, model.Users{UserID: userID}); err != nil {
+		return result, err
+	}
+
+	// Прежние значения личных полей скрываются и в журнале аудита; сама запись об обезличивании их не содержит
+	result.AuditEntries, err = audit.Redact(tx, userID)
+	if err != nil {
+		return result, err
+	}
+	err = audit.Record(ctx, tx, audit.Change{Action: audit.ActionAnonymized, Entity: audit.EntityUser, EntityID: userID, UserID: userID,
+		After: map[string]interface{}{"anonymized_at": result.AnonymizedAt}})
+	if err != nil {
+		return result, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return result, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return result, nil
+}
@@ -2,10 +2,17 @@ package user
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"strconv"
+
+	"main.go/cmd/internal/audit"
+	"main.go/cmd/internal/events"
+	"main.go/cmd/internal/outbox"
+	"main.go/cmd/internal/storage/cache"
+	model "main.go/tracker_model"
 )
 
 // @Summary Delete a user
@@ -34,8 +41,30 @@ func DeleteUserHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
 			return
 		}
 
+		tx, err := db.Begin()
+		if err != nil {
+			log.Error("Failed to begin transaction", slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Failed to delete user: %v", err), http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+
+		// Состояние пользователя до удаления сохраняется в журнале аудита, личные поля в нем скрываются
+		var before model.Users
+		err = tx.QueryRow(`
+			SELECT id, surname, name, COALESCE(patronymic, ''), address, role, timezone
+			FROM users
+			WHERE id = $1
+			FOR UPDATE`, userID).Scan(&before.UserID, &before.Surname, &before.Name, &before.Patronymic, &before.Address, &before.Role, &before.Timezone)
+		found := err == nil
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Error("Failed to get user", slog.String("error", err.Error()), slog.Int("userID", userID))
+			http.Error(w, fmt.Sprintf("Failed to delete user: %v", err), http.StatusInternalServerError)
+			return
+		}
+
 		log.Debug("Deleting user's tasks", slog.Int("userID", userID))
-		_, err = db.Exec("DELETE FROM users_tasks WHERE user_id = $1", userID)
+		_, err = tx.Exec("DELETE FROM users_tasks WHERE user_id = $1", userID)
 		if err != nil {
 			log.Error("Failed to delete user's tasks", slog.String("error", err.Error()), slog.Int("userID", userID))
 			http.Error(w, fmt.Sprintf("Failed to delete user's tasks: %v", err), http.StatusInternalServerError)
@@ -43,14 +72,49 @@ func DeleteUserHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
 		}
 
 		log.Debug("Deleting user", slog.Int("userID", userID))
-		_, err = db.Exec("DELETE FROM users WHERE id = $1", userID)
+		_, err = tx.Exec("DELETE FROM users WHERE id = $1", userID)
 		if err != nil {
 			log.Error("Failed to delete user", slog.String("error", err.Error()), slog.Int("userID", userID))
 			http.Error(w, fmt.Sprintf("Failed to delete user: %v", err), http.StatusInternalServerError)
 			return
 		}
 
+		if err := outbox.EnqueueUser(tx, events.UserDeleted, model.Users{UserID: userID}); err != nil {
+			log.Error("Failed to enqueue user event", slog.String("error", err.Error()), slog.Int("userID", userID))
+			http.Error(w, fmt.Sprintf("Failed to delete user: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		if found {
+			err = audit.Record(r.Context(), tx, audit.Change{Action: audit.ActionDeleted, Entity: audit.EntityUser, EntityID: userID, UserID: userID, Before: before})
+			if err != nil {
+				log.Error("Failed to record audit entry", slog.String("error", err.Error()), slog.Int("userID", userID))
+				http.Error(w, fmt.Sprintf("Failed to delete user: %v", err), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		// Личные данные удаленного пользователя не должны оставаться и в журнале аудита,
+		// включая только что записанное состояние до удаления
+		if _, err := audit.Redact(tx, userID); err != nil {
+			log.Error("Failed to redact audit log", slog.String("error", err.Error()), slog.Int("userID", userID))
+			http.Error(w, fmt.Sprintf("Failed to delete user: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			log.Error("Failed to commit transaction", slog.String("error", err.Error()), slog.Int("userID", userID))
+			http.Error(w, fmt.Sprintf("Failed to delete user: %v", err), http.StatusInternalServerError)
+			return
+		}
+
 		log.Info("User and their tasks deleted", slog.Int("userID", userID))
+
+		// Обновление кэша
+		cache.UserCacheMutex.Lock()
+		delete(cache.UserCache, userID)
+		cache.UserCacheMutex.Unlock()
+
 		w.WriteHeader(http.StatusOK)
 		fmt.Fprintf(w, "User with ID %s and their tasks have been deleted", userIDStr)
 	}
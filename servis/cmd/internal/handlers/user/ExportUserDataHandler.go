@@ -0,0 +1,272 @@
+package user
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"main.go/cmd/internal/audit"
+	"main.go/cmd/internal/calendar"
+	"main.go/cmd/internal/config"
+	"main.go/cmd/internal/handlers/util"
+	"main.go/cmd/internal/passport"
+	"main.go/cmd/internal/storage"
+	model "main.go/tracker_model"
+)
+
+// @Summary Export personal data
+// @Description Download all personal data of a user: profile with the unmasked passport, work schedule,
+// @Description sessions, timesheets, absences, the history of events and audit log entries. Available to the user themselves
+// @Description and to managers. format=zip returns an archive with one JSON file per section.
+// @Tags User
+// @Produce json
+// @Produce application/zip
+// @Param id path int true "User ID"
+// @Param format query string false "json (default) or zip"
+// @Param X-User-ID header int true "Caller ID"
+// @Success 200 {object} model.UserExport "Exported data"
+// @Failure 400 {string} string "Invalid user ID, format or caller"
+// @Failure 403 {string} string "Access denied"
+// @Failure 404 {string} string "User not found"
+// @Failure 500 {string} string "Failed to export data"
+// @Router /api/v1/users/{id}/export [get]
+func ExportUserDataHandler(db *sql.DB, log *slog.Logger, cipher *passport.Cipher, cfg config.CalendarConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := r.PathValue("id")
+		userID, err := strconv.Atoi(idStr)
+		if err != nil {
+			log.Error("Invalid user ID", slog.String("idStr", idStr), slog.String("error", err.Error()))
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = "json"
+		}
+		if format != "json" && format != "zip" {
+			log.Warn("Invalid export format", slog.String("format", format))
+			http.Error(w, "Invalid format, expected json or zip", http.StatusBadRequest)
+			return
+		}
+
+		actorID, err := util.ActorID(r)
+		if err != nil {
+			log.Warn("Missing caller", slog.String("error", err.Error()))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if actorID != userID {
+			role, err := userRole(db, actorID)
+			if err != nil {
+				log.Error("Failed to check caller role", slog.Int("actorID", actorID), slog.String("error", err.Error()))
+				http.Error(w, fmt.Sprintf("Failed to check caller role: %v", err), http.StatusInternalServerError)
+				return
+			}
+			if role != model.RoleManager {
+				log.Warn("Access to personal data denied", slog.Int("userID", userID), slog.Int("actorID", actorID))
+				http.Error(w, "Access denied", http.StatusForbidden)
+				return
+			}
+		}
+
+		export, err := collectUserData(r.Context(), db, cipher, cfg, userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("User not found", slog.Int("userID", userID))
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Error("Failed to collect user data", slog.Int("userID", userID), slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Failed to export data: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		log.Info("Personal data exported", slog.Int("userID", userID), slog.Int("actorID", actorID), slog.String("format", format))
+
+		filename := fmt.Sprintf("user-%d-export.%s", userID, format)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		if format == "json" {
+			w.Header().Set("Content-Type", "application/json")
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			enc.Encode(export)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/zip")
+		if err := writeExportZip(w, userID, export); err != nil {
+			// Заголовки уже отправлены, остается только записать ошибку в журнал
+			log.Error("Failed to write export archive", slog.Int("userID", userID), slog.String("error", err.Error()))
+		}
+	}
+}
+
+// collectUserData reads all personal data of the user in one read-only snapshot.
+func collectUserData(ctx context.Context, db *sql.DB, cipher *passport.Cipher, cfg config.CalendarConfig, userID int) (model.UserExport, error) {
+	export := model.UserExport{
+		GeneratedAt: time.Now().UTC(),
+		Sessions:    []model.UserTask{},
+		Timesheets:  []model.Timesheet{},
+		Absences:    []model.Absence{},
+		Events:      []model.ExportEvent{},
+		Audit:       []model.AuditEntry{},
+	}
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return export, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	profile := &export.Profile
+	var encrypted []byte
+	err = tx.QueryRow(`
+		SELECT id, passport_encrypted, surname, name, COALESCE(patronymic, ''), address, role, timezone, anonymized_at IS NOT NULL
+		FROM users
+		WHERE id = $1`, userID).Scan(&profile.UserID, &encrypted, &profile.Surname, &profile.Name, &profile.Patronymic,
+		&profile.Address, &profile.Role, &profile.Timezone, &profile.Anonymized)
+	if err != nil {
+		return export, err
+	}
+	if encrypted != nil {
+		serie, number, err := cipher.Decrypt(encrypted)
+		if err != nil {
+			return export, err
+		}
+		profile.PassportSerie, profile.PassportNumber, profile.Passport = passportFields(serie, number, true)
+	}
+
+	schedule, found, err := calendar.UserSchedule(tx, userID, calendar.DefaultSchedule(cfg.DailyHours))
+	if err != nil {
+		return export, err
+	}
+	export.Schedule = workScheduleModel(userID, schedule, !found)
+
+	rows, err := tx.Query("SELECT "+storage.UserTaskColumns+" FROM users_tasks WHERE user_id = $1 ORDER BY start_time, id", userID)
+	if err != nil {
+		return export, fmt.Errorf("failed to query sessions: %v", err)
+	}
+	for rows.Next() {
+		task, err := storage.ScanUserTask(rows)
+		if err != nil {
+			rows.Close()
+			return export, fmt.Errorf("failed to scan session: %v", err)
+		}
+		export.Sessions = append(export.Sessions, task)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return export, err
+	}
+
+	rows, err = tx.Query("SELECT "+storage.TimesheetColumns+" FROM timesheets WHERE user_id = $1 ORDER BY week_start", userID)
+	if err != nil {
+		return export, fmt.Errorf("failed to query timesheets: %v", err)
+	}
+	for rows.Next() {
+		timesheet, err := storage.ScanTimesheet(rows)
+		if err != nil {
+			rows.Close()
+			return export, fmt.Errorf("failed to scan timesheet: %v", err)
+		}
+		export.Timesheets = append(export.Timesheets, timesheet)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return export, err
+	}
+
+	rows, err = tx.Query("SELECT "+storage.AbsenceColumns+" FROM absences WHERE user_id = $1 ORDER BY start_date, id", userID)
+	if err != nil {
+		return export, fmt.Errorf("failed to query absences: %v", err)
+	}
+	for rows.Next() {
+		absence, err := storage.ScanAbsence(rows)
+		if err != nil {
+			rows.Close()
+			return export, fmt.Errorf("failed to scan absence: %v", err)
+		}
+		export.Absences = append(export.Absences, absence)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return export, err
+	}
+
+	rows, err = tx.Query(`SELECT id, event_type, created_at, payload FROM outbox WHERE user_id = $1 ORDER BY id`, userID)
+	if err != nil {
+		return export, fmt.Errorf("failed to query events: %v", err)
+	}
+	for rows.Next() {
+		var event model.ExportEvent
+		var payload string
+		if err := rows.Scan(&event.ID, &event.Type, &event.CreatedAt, &payload); err != nil {
+			rows.Close()
+			return export, fmt.Errorf("failed to scan event: %v", err)
+		}
+		event.Payload = json.RawMessage(payload)
+		export.Events = append(export.Events, event)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return export, err
+	}
+
+	rows, err = tx.Query("SELECT "+audit.Columns+" FROM audit_log WHERE user_id = $1 ORDER BY id", userID)
+	if err != nil {
+		return export, fmt.Errorf("failed to query audit log: %v", err)
+	}
+	for rows.Next() {
+		entry, err := audit.Scan(rows)
+		if err != nil {
+			rows.Close()
+			return export, fmt.Errorf("failed to scan audit entry: %v", err)
+		}
+		export.Audit = append(export.Audit, entry)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return export, err
+	}
+
+	return export, nil
+}
+
+// writeExportZip writes the export as a ZIP archive with one JSON file per section.
+func writeExportZip(w io.Writer, userID int, export model.UserExport) error {
+	archive := zip.NewWriter(w)
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"manifest.json", map[string]interface{}{"user_id": userID, "generated_at": export.GeneratedAt}},
+		{"profile.json", export.Profile},
+		{"schedule.json", export.Schedule},
+		{"sessions.json", export.Sessions},
+		{"timesheets.json", export.Timesheets},
+		{"absences.json", export.Absences},
+		{"events.json", export.Events},
+		{"audit.json", export.Audit},
+	}
+	for _, file := range files {
+		f, err := archive.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: export.GeneratedAt})
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(file.data); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
@@ -0,0 +1,133 @@
+package user
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"main.go/cmd/internal/storage"
+	"main.go/cmd/internal/storage/cache"
+	model "main.go/tracker_model"
+)
+
+// CurrentStatus describes what a user is working on right now.
+type CurrentStatus struct {
+	UserID         int             `json:"user_id"`
+	Surname        string          `json:"surname"`
+	Name           string          `json:"name"`
+	Working        bool            `json:"working"`
+	TaskName       string          `json:"task_name,omitempty"`
+	ElapsedMinutes int             `json:"elapsed_minutes"`
+	Session        *model.UserTask `json:"session,omitempty"`
+}
+
+// @Summary Get current user status
+// @Description Get the running session of a user, its task and elapsed time
+// @Tags User
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} CurrentStatus "Current status"
+// @Failure 400 {string} string "Invalid user ID"
+// @Failure 404 {string} string "User not found"
+// @Failure 500 {string} string "Failed to retrieve status"
+// @Router /api/v1/users/{id}/current [get]
+func GetCurrentStatusHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := r.PathValue("id")
+		userID, err := strconv.Atoi(idStr)
+		if err != nil {
+			log.Error("Invalid user ID", slog.String("idStr", idStr), slog.String("error", err.Error()))
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		user, session, ok := cache.GetRunningSession(userID)
+		if !ok {
+			log.Debug("User not found in cache, querying database", slog.Int("userID", userID))
+			user, session, err = getRunningSessionFromDB(db, userID)
+			if errors.Is(err, sql.ErrNoRows) {
+				log.Info("No user found with the given ID", slog.Int("userID", userID))
+				http.Error(w, "No user found with the given ID", http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				log.Error("Failed to retrieve current status", slog.Int("userID", userID), slog.String("error", err.Error()))
+				http.Error(w, fmt.Sprintf("Failed to retrieve current status: %v", err), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		status := newCurrentStatus(user, session, time.Now())
+
+		log.Debug("Current status retrieved", slog.Int("userID", userID), slog.Bool("working", status.Working))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(status)
+	}
+}
+
+// @Summary Get who is working now
+// @Description Get all users with a running session, served from the cache
+// @Tags User
+// @Produce json
+// @Success 200 {array} CurrentStatus "Users working now"
+// @Router /api/v1/users/current [get]
+func GetWorkingNowHandler(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		users, sessions := cache.GetAllRunningSessions()
+
+		now := time.Now()
+		statuses := make([]CurrentStatus, 0, len(users))
+		for i := range users {
+			statuses = append(statuses, newCurrentStatus(users[i], &sessions[i], now))
+		}
+
+		log.Debug("Working users retrieved", slog.Int("count", len(statuses)))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(statuses)
+	}
+}
+
+// newCurrentStatus builds the status of a user from their running session, nil meaning idle.
+func newCurrentStatus(user model.Users, session *model.UserTask, now time.Time) CurrentStatus {
+	status := CurrentStatus{
+		UserID:  user.UserID,
+		Surname: user.Surname,
+		Name:    user.Name,
+	}
+	if session != nil {
+		status.Working = true
+		status.TaskName = session.TaskName
+		status.ElapsedMinutes = int(now.Sub(session.StartTime).Minutes())
+		status.Session = session
+	}
+	return status
+}
+
+// getRunningSessionFromDB loads the user and their latest running session bypassing the cache.
+func getRunningSessionFromDB(db *sql.DB, userID int) (model.Users, *model.UserTask, error) {
+	user := model.Users{UserID: userID}
+	err := db.QueryRow("SELECT surname, name FROM users WHERE id = $1", userID).Scan(&user.Surname, &user.Name)
+	if err != nil {
+		return user, nil, err
+	}
+
+	session, err := storage.ScanUserTask(db.QueryRow(`
+		SELECT `+storage.UserTaskColumns+`
+		FROM users_tasks
+		WHERE user_id = $1 AND end_time IS NULL
+		ORDER BY start_time DESC
+		LIMIT 1
+	`, userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return user, nil, nil
+	}
+	if err != nil {
+		return user, nil, err
+	}
+	return user, &session, nil
+}
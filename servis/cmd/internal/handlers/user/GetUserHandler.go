@@ -3,36 +3,54 @@ package user
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"strconv"
 	"time"
 
+	"github.com/lib/pq"
+	"main.go/cmd/internal/filter"
+	"main.go/cmd/internal/passport"
+	"main.go/cmd/internal/rounding"
 	model "main.go/tracker_model"
 )
 
 type Users struct {
 	UserID         int        `json:"id"`
-	PassportSerie  int        `json:"passport_serie"`
-	PassportNumber int        `json:"passport_number"`
+	PassportSerie  int        `json:"passport_serie,omitempty"`
+	PassportNumber int        `json:"passport_number,omitempty"`
+	Passport       string     `json:"passport,omitempty"`
 	Surname        string     `json:"surname"`
 	Name           string     `json:"name"`
 	Patronymic     string     `json:"patronymic"`
 	Address        string     `json:"address"`
+	Role           string     `json:"role"`
+	Timezone       string     `json:"timezone"`
 	UserTask       []UserTask `json:"userTask"`
 }
 type UserTask struct {
+	IDSession    int       `json:"id_session"`
 	UserID       int       `json:"id_user"`
 	IDTask       int       `json:"id_task"`
 	TaskName     string    `json:"task_name"`
 	StartTime    time.Time `json:"start_time"`
 	EndTime      time.Time `json:"end_time"`
+	TotalSeconds int64     `json:"total_seconds"`
 	TotalMinutes int       `json:"total_minutes"`
+	Status       string    `json:"status"`
+	AutoClosed   bool      `json:"auto_closed"`
+	Billable     bool      `json:"billable"`
+	InvoiceID    int       `json:"invoice_id,omitempty"`
+	Description  string    `json:"description,omitempty"`
+	Tags         []string  `json:"tags"`
 }
 
 // @Summary Get users
-// @Description Get a list of users with optional filters and pagination
+// @Description Get a page of users with optional filters, stable sorting and keyset pagination.
+// @Description The next page is requested by passing next_cursor from the response as cursor with the same sort.
 // @Tags User
 // @Accept json
 // @Produce json
@@ -42,113 +60,147 @@ type UserTask struct {
 // @Param name query string false "Name"
 // @Param patronymic query string false "Patronymic"
 // @Param address query string false "Address"
-// @Param page query int false "Page number"
-// @Param limit query int false "Limit per page"
-// @Success 200 {array} Users "List of users"
+// @Param filter query string false "Filter expression, e.g. surname~\"Ivan\" and (address~\"Moscow\" or passport_serie=1234)"
+// @Param sort query string false "id (default), surname, name, patronymic or address; prefix - for descending"
+// @Param cursor query string false "Cursor from next_cursor of the previous page"
+// @Param limit query int false "Limit per page (default 10, max 100)"
+// @Param include_total query bool false "Return the total number of users matching the filters"
+// @Param include query string false "summary - embed total time per task for each user"
+// @Param X-User-ID header int false "Caller ID; managers see full passport data, others get it masked"
+// @Success 200 {object} model.UserPage "Page of users"
 // @Failure 400 {string} string "Invalid query parameters"
 // @Failure 500 {string} string "Failed to retrieve users"
 // @Router /api/v1/users [get]
-func GetUsersHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
+func GetUsersHandler(db *sql.DB, log *slog.Logger, policy rounding.Policy, cipher *passport.Cipher) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Получение параметров фильтрации и пагинации из URL
-		passportSerieStr := r.URL.Query().Get("passport_serie")
-		passportNumberStr := r.URL.Query().Get("passport_number")
-		surname := r.URL.Query().Get("surname")
-		name := r.URL.Query().Get("name")
-		patronymic := r.URL.Query().Get("patronymic")
-		address := r.URL.Query().Get("address")
-		pageStr := r.URL.Query().Get("page")
-		limitStr := r.URL.Query().Get("limit")
+		query := r.URL.Query()
+
+		sort, err := parseSort(query.Get("sort"))
+		if err != nil {
+			log.Warn("Invalid sort", slog.String("sort", query.Get("sort")))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 
 		// Установка значений по умолчанию для пагинации
-		page := 1
 		limit := 10
-		var err error
-
-		if pageStr != "" {
-			page, err = strconv.Atoi(pageStr)
-			if err != nil || page < 1 {
-				log.Error("Invalid page number", slog.String("pageStr", pageStr), slog.String("error", err.Error()))
-				http.Error(w, "Invalid page number", http.StatusBadRequest)
+		if limitStr := query.Get("limit"); limitStr != "" {
+			limit, err = strconv.Atoi(limitStr)
+			if err != nil || limit < 1 || limit > maxUsersLimit {
+				log.Warn("Invalid limit number", slog.String("limitStr", limitStr))
+				http.Error(w, fmt.Sprintf("Invalid limit number, expected 1-%d", maxUsersLimit), http.StatusBadRequest)
 				return
 			}
 		}
 
-		if limitStr != "" {
-			limit, err = strconv.Atoi(limitStr)
-			if err != nil || limit < 1 {
-				log.Error("Invalid limit number", slog.String("limitStr", limitStr), slog.String("error", err.Error()))
-				http.Error(w, "Invalid limit number", http.StatusBadRequest)
+		includeTotal := false
+		if totalStr := query.Get("include_total"); totalStr != "" {
+			includeTotal, err = strconv.ParseBool(totalStr)
+			if err != nil {
+				log.Warn("Invalid include_total", slog.String("include_total", totalStr))
+				http.Error(w, "Invalid include_total, expected true or false", http.StatusBadRequest)
 				return
 			}
 		}
 
-		offset := (page - 1) * limit
-
-		// Построение запроса с учетом фильтров
-		query := "SELECT passport_serie, passport_number, surname, name, patronymic, address FROM users WHERE 1=1"
-		args := []interface{}{}
-		argID := 1
-
-		if passportSerieStr != "" {
-			query += fmt.Sprintf(" AND passport_serie = $%d", argID)
-			args = append(args, passportSerieStr)
-			argID++
+		include := query.Get("include")
+		if include != "" && include != "summary" {
+			log.Warn("Invalid include", slog.String("include", include))
+			http.Error(w, "Invalid include, expected summary", http.StatusBadRequest)
+			return
 		}
 
-		if passportNumberStr != "" {
-			query += fmt.Sprintf(" AND passport_number = $%d", argID)
-			args = append(args, passportNumberStr)
-			argID++
+		// Построение условий с учетом фильтров
+		where, args, err := userFilters(query, cipher)
+		if err != nil {
+			log.Warn("Invalid filter", slog.String("filter", query.Get("filter")), slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Invalid filter: %v", err), http.StatusBadRequest)
+			return
 		}
+		page := model.UserPage{Items: []model.UserListItem{}}
 
-		if surname != "" {
-			query += fmt.Sprintf(" AND surname ILIKE $%d", argID)
-			args = append(args, "%"+surname+"%")
-			argID++
+		visible, err := passportVisible(db, r)
+		if err != nil {
+			log.Error("Failed to check caller role", slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Failed to check caller role: %v", err), http.StatusInternalServerError)
+			return
 		}
 
-		if name != "" {
-			query += fmt.Sprintf(" AND name ILIKE $%d", argID)
-			args = append(args, "%"+name+"%")
-			argID++
+		if includeTotal {
+			var total int
+			err := db.QueryRow("SELECT COUNT(*) FROM users WHERE "+where, args...).Scan(&total)
+			if err != nil {
+				log.Error("Failed to count users", slog.String("error", err.Error()))
+				http.Error(w, fmt.Sprintf("Failed to count users: %v", err), http.StatusInternalServerError)
+				return
+			}
+			page.Total = &total
 		}
 
-		if patronymic != "" {
-			query += fmt.Sprintf(" AND patronymic ILIKE $%d", argID)
-			args = append(args, "%"+patronymic+"%")
-			argID++
+		sortExpr := sortColumns[sort.field]
+		order, cmp := "ASC", ">"
+		if sort.desc {
+			order, cmp = "DESC", "<"
 		}
 
-		if address != "" {
-			query += fmt.Sprintf(" AND address ILIKE $%d", argID)
-			args = append(args, "%"+address+"%")
-			argID++
+		if cursorStr := query.Get("cursor"); cursorStr != "" {
+			cursor, err := decodeCursor(cursorStr, sort)
+			if err != nil {
+				log.Warn("Invalid cursor", slog.String("cursor", cursorStr), slog.String("error", err.Error()))
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			where += fmt.Sprintf(" AND (%s, id) %s ($%d, $%d)", sortExpr, cmp, len(args)+1, len(args)+2)
+			args = append(args, cursor.Key, cursor.ID)
 		}
 
-		query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", argID, argID+1)
-		args = append(args, limit, offset)
+		// Запрашивается на одну строку больше, чтобы узнать, есть ли следующая страница
+		sqlQuery := fmt.Sprintf(`
+			SELECT id, passport_encrypted, surname, name, COALESCE(patronymic, ''), address, role, timezone,
+				anonymized_at IS NOT NULL, (%s)::text
+			FROM users
+			WHERE %s
+			ORDER BY %s %s, id %s
+			LIMIT $%d`, sortExpr, where, sortExpr, order, order, len(args)+1)
+		args = append(args, limit+1)
 
-		log.Debug("Executing database query", slog.String("query", query), slog.Any("args", args))
+		log.Debug("Executing database query", slog.String("query", sqlQuery), slog.Any("args", args))
 		// Выполнение запроса к базе данных
-		rows, err := db.Query(query, args...)
+		rows, err := db.Query(sqlQuery, args...)
 		if err != nil {
-			log.Error("Database query failed", slog.String("query", query), slog.String("error", err.Error()))
+			log.Error("Database query failed", slog.String("query", sqlQuery), slog.String("error", err.Error()))
 			http.Error(w, fmt.Sprintf("Database query failed: %v", err), http.StatusInternalServerError)
 			return
 		}
 		defer rows.Close()
 
-		var users []model.Users
+		var lastKey string
 		for rows.Next() {
-			var user model.Users
-			err := rows.Scan(&user.PassportSerie, &user.PassportNumber, &user.Surname, &user.Name, &user.Patronymic, &user.Address)
+			var user model.UserListItem
+			var encrypted []byte
+			var key string
+			err := rows.Scan(&user.UserID, &encrypted, &user.Surname, &user.Name,
+				&user.Patronymic, &user.Address, &user.Role, &user.Timezone, &user.Anonymized, &key)
 			if err != nil {
 				log.Error("Failed to scan row", slog.String("error", err.Error()))
 				http.Error(w, fmt.Sprintf("Failed to scan row: %v", err), http.StatusInternalServerError)
 				return
 			}
-			users = append(users, user)
+			if len(page.Items) == limit {
+				page.NextCursor = encodeCursor(userCursor{Sort: sort.String(), Key: lastKey, ID: page.Items[limit-1].UserID})
+				break
+			}
+			if encrypted != nil {
+				serie, number, err := cipher.Decrypt(encrypted)
+				if err != nil {
+					log.Error("Failed to decrypt passport", slog.Int("userID", user.UserID), slog.String("error", err.Error()))
+					http.Error(w, "Failed to decrypt passport data", http.StatusInternalServerError)
+					return
+				}
+				user.PassportSerie, user.PassportNumber, user.Passport = passportFields(serie, number, visible)
+			}
+			page.Items = append(page.Items, user)
+			lastKey = key
 		}
 
 		// Проверка на ошибки, возникшие при итерации по строкам
@@ -158,9 +210,122 @@ func GetUsersHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
 			return
 		}
 
-		log.Info("Users retrieved successfully", slog.Int("count", len(users)))
+		if include == "summary" {
+			if err := embedTaskSummaries(db, policy, page.Items); err != nil {
+				log.Error("Failed to load task summaries", slog.String("error", err.Error()))
+				http.Error(w, fmt.Sprintf("Failed to load task summaries: %v", err), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		log.Info("Users retrieved successfully", slog.Int("count", len(page.Items)), slog.Bool("has_next", page.NextCursor != ""))
 		// Установка заголовка и кодирование ответа в JSON
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(users)
+		json.NewEncoder(w).Encode(page)
+	}
+}
+
+// maxUsersLimit is the largest page size accepted by GetUsersHandler.
+const maxUsersLimit = 100
+
+// userFilterFields returns the fields allowed in the filter expression. Passport fields are
+// encrypted, so they only support equality and are matched by their blind indexes.
+func userFilterFields(cipher *passport.Cipher) filter.Fields {
+	return filter.Fields{
+		"id": {Column: "id", Type: filter.Int},
+		"passport_serie": {Column: "passport_serie_hash", Type: filter.Int, Value: func(v interface{}) interface{} {
+			return cipher.SerieIndex(int(v.(int64)))
+		}},
+		"passport_number": {Column: "passport_number_hash", Type: filter.Int, Value: func(v interface{}) interface{} {
+			return cipher.NumberIndex(int(v.(int64)))
+		}},
+		"surname":    {Column: "surname", Type: filter.String},
+		"name":       {Column: "name", Type: filter.String},
+		"patronymic": {Column: "COALESCE(patronymic, '')", Type: filter.String},
+		"address":    {Column: "address", Type: filter.String},
+		"role":       {Column: "role", Type: filter.String},
+		"timezone":   {Column: "timezone", Type: filter.String},
+	}
+}
+
+// userFilters builds the WHERE condition and its arguments from the filter query parameters.
+// The filter expression, if given, is ANDed with the simple field parameters.
+func userFilters(query url.Values, cipher *passport.Cipher) (string, []interface{}, error) {
+	where := "1=1"
+	args := []interface{}{}
+
+	if passportSerie := query.Get("passport_serie"); passportSerie != "" {
+		serie, err := strconv.Atoi(passportSerie)
+		if err != nil {
+			return "", nil, errors.New("invalid passport_serie")
+		}
+		args = append(args, cipher.SerieIndex(serie))
+		where += fmt.Sprintf(" AND passport_serie_hash = $%d", len(args))
+	}
+
+	if passportNumber := query.Get("passport_number"); passportNumber != "" {
+		number, err := strconv.Atoi(passportNumber)
+		if err != nil {
+			return "", nil, errors.New("invalid passport_number")
+		}
+		args = append(args, cipher.NumberIndex(number))
+		where += fmt.Sprintf(" AND passport_number_hash = $%d", len(args))
+	}
+
+	for _, column := range []string{"surname", "name", "patronymic", "address"} {
+		if value := query.Get(column); value != "" {
+			args = append(args, "%"+value+"%")
+			where += fmt.Sprintf(" AND %s ILIKE $%d", column, len(args))
+		}
+	}
+
+	if expr := query.Get("filter"); expr != "" {
+		condition, filterArgs, err := filter.Parse(expr, userFilterFields(cipher), args)
+		if err != nil {
+			return "", nil, err
+		}
+		where += " AND " + condition
+		args = filterArgs
+	}
+
+	return where, args, nil
+}
+
+// embedTaskSummaries loads total time per task over finished sessions for all users of the page
+// in one query and attaches it to the items. Durations are rounded per session by the policy.
+func embedTaskSummaries(db *sql.DB, policy rounding.Policy, items []model.UserListItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(items))
+	index := make(map[int]int, len(items))
+	for i := range items {
+		ids[i] = int64(items[i].UserID)
+		index[items[i].UserID] = i
+		items[i].Summary = []model.TaskTotal{}
+	}
+
+	rows, err := db.Query(`
+		SELECT user_id, id_task, MAX(task_name), COALESCE(SUM(`+policy.SQL("total_seconds")+`), 0), COUNT(*)
+		FROM users_tasks
+		WHERE user_id = ANY($1) AND end_time IS NOT NULL
+		GROUP BY user_id, id_task
+		ORDER BY user_id, 4 DESC, id_task`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID int
+		var total model.TaskTotal
+		if err := rows.Scan(&userID, &total.IDTask, &total.TaskName, &total.TotalSeconds, &total.Sessions); err != nil {
+			return err
+		}
+		total.TotalMinutes = int(total.TotalSeconds / 60)
+		i := index[userID]
+		items[i].Summary = append(items[i].Summary, total)
 	}
+	return rows.Err()
 }
@@ -0,0 +1,70 @@
+package user
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"main.go/cmd/internal/calendar"
+	"main.go/cmd/internal/config"
+	"main.go/cmd/internal/storage"
+	model "main.go/tracker_model"
+)
+
+// @Summary Get work schedule
+// @Description Get scheduled working hours per weekday; users without a schedule get the default five-day week
+// @Tags User
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} model.WorkSchedule "Work schedule"
+// @Failure 400 {string} string "Invalid user ID"
+// @Failure 404 {string} string "User not found"
+// @Failure 500 {string} string "Failed to retrieve schedule"
+// @Router /api/v1/users/{id}/schedule [get]
+func GetWorkScheduleHandler(db *sql.DB, log *slog.Logger, cfg config.CalendarConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := r.PathValue("id")
+		userID, err := strconv.Atoi(idStr)
+		if err != nil {
+			log.Error("Invalid user ID", slog.String("idStr", idStr), slog.String("error", err.Error()))
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		// Existence check, the schedule itself may be empty
+		if _, err := storage.UserLocation(db, userID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				log.Warn("User not found", slog.Int("userID", userID))
+				http.Error(w, "User not found", http.StatusNotFound)
+				return
+			}
+			log.Error("Failed to retrieve user", slog.Int("userID", userID), slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Failed to retrieve user: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		schedule, found, err := calendar.UserSchedule(db, userID, calendar.DefaultSchedule(cfg.DailyHours))
+		if err != nil {
+			log.Error("Failed to retrieve schedule", slog.Int("userID", userID), slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Failed to retrieve schedule: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(workScheduleModel(userID, schedule, !found))
+	}
+}
+
+// workScheduleModel converts a schedule to its API representation in hours.
+func workScheduleModel(userID int, schedule calendar.Schedule, isDefault bool) model.WorkSchedule {
+	ws := model.WorkSchedule{UserID: userID, Hours: map[string]float64{}, Default: isDefault}
+	for d, seconds := range schedule {
+		ws.Hours[calendar.WeekdayName(time.Weekday(d))] = float64(seconds) / 3600
+	}
+	return ws
+}
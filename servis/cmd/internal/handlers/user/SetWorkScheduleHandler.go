@@ -0,0 +1,117 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"main.go/cmd/internal/audit"
+	"main.go/cmd/internal/calendar"
+)
+
+// ScheduleInput is the request body for replacing a work schedule.
+type ScheduleInput struct {
+	Hours map[string]float64 `json:"hours"` // Hours per weekday (monday … sunday), missing days are days off
+}
+
+// @Summary Set work schedule
+// @Description Replace scheduled working hours per weekday used for overtime reports
+// @Tags User
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param schedule body ScheduleInput true "Hours per weekday"
+// @Success 200 {object} model.WorkSchedule "Work schedule"
+// @Failure 400 {string} string "Invalid user ID or input"
+// @Failure 404 {string} string "User not found"
+// @Failure 500 {string} string "Failed to update schedule"
+// @Router /api/v1/users/{id}/schedule [put]
+func SetWorkScheduleHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := r.PathValue("id")
+		userID, err := strconv.Atoi(idStr)
+		if err != nil {
+			log.Error("Invalid user ID", slog.String("idStr", idStr), slog.String("error", err.Error()))
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		var input ScheduleInput
+		err = json.NewDecoder(r.Body).Decode(&input)
+		if err != nil {
+			log.Error("Invalid input", slog.String("error", err.Error()))
+			http.Error(w, "Invalid input", http.StatusBadRequest)
+			return
+		}
+
+		var schedule calendar.Schedule
+		for name, hours := range input.Hours {
+			d, ok := calendar.ParseWeekday(name)
+			if !ok || hours < 0 || hours > 24 {
+				log.Warn("Invalid schedule entry", slog.String("weekday", name), slog.Float64("hours", hours))
+				http.Error(w, fmt.Sprintf("Invalid schedule entry %q: expected monday … sunday with 0-24 hours", name), http.StatusBadRequest)
+				return
+			}
+			schedule[d] = int64(hours * 3600)
+		}
+
+		err = setWorkSchedule(r.Context(), db, userID, schedule)
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Warn("User not found", slog.Int("userID", userID))
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Error("Failed to update schedule", slog.Int("userID", userID), slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Failed to update schedule: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		log.Info("Work schedule updated", slog.Int("userID", userID), slog.Any("hours", input.Hours))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(workScheduleModel(userID, schedule, false))
+	}
+}
+
+// setWorkSchedule replaces the schedule of an existing user in a single transaction.
+// A missing user is reported as an error wrapping sql.ErrNoRows.
+func setWorkSchedule(ctx context.Context, db *sql.DB, userID int, schedule calendar.Schedule) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var id int
+	if err := tx.QueryRow(`SELECT id FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&id); err != nil {
+		return fmt.Errorf("failed to lock user: %w", err)
+	}
+	previous, found, err := calendar.UserSchedule(tx, userID, calendar.Schedule{})
+	if err != nil {
+		return err
+	}
+	if err := calendar.SetUserSchedule(tx, userID, schedule); err != nil {
+		return err
+	}
+
+	// The audit diff is keyed by weekday, a previously unset schedule is recorded as empty
+	var before map[string]float64
+	if found {
+		before = workScheduleModel(userID, previous, false).Hours
+	}
+	err = audit.Record(ctx, tx, audit.Change{Action: audit.ActionUpdated, Entity: audit.EntitySchedule, EntityID: userID, UserID: userID,
+		Before: before, After: workScheduleModel(userID, schedule, false).Hours})
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
@@ -1,32 +1,41 @@
 package user
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
 
+	"main.go/cmd/internal/audit"
+	"main.go/cmd/internal/handlers/util"
+	"main.go/cmd/internal/passport"
+	"main.go/cmd/internal/storage"
 	"main.go/cmd/internal/storage/cache"
 	model "main.go/tracker_model"
 )
 
 // UpdateUserHandler обрабатывает запросы на изменение данных пользователя.
 // @Summary Update a user
-// @Description Update user details
+// @Description Update user details. The passport is changed only when passport_serie and passport_number
+// @Description are given; in the response it is masked unless the caller (X-User-ID) is a manager.
 // @Tags User
 // @Accept json
 // @Produce json
 // @Param id path int true "User ID"
 // @Param user body Users true "User details"
+// @Param X-User-ID header int false "Caller ID"
 // @Success 200 {object} Users "Updated user details"
 // @Failure 400 {string} string "Invalid user ID or input"
 // @Failure 404 {string} string "User not found"
+// @Failure 409 {string} string "User with this passport already exists"
 // @Failure 500 {string} string "Failed to update user"
 // @Router /api/v1/users/{id} [put]
-func UpdateUserHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
+func UpdateUserHandler(db *sql.DB, log *slog.Logger, cipher *passport.Cipher) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		idStr := strings.TrimPrefix(r.URL.Path, "/update_user/")
 		userID, err := strconv.Atoi(idStr)
@@ -46,41 +55,69 @@ func UpdateUserHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
 
 		user.UserID = userID
 
-		log.Debug("Updating user", slog.Any("user", user))
-		result, err := db.Exec(`
-			UPDATE users
-			SET passport_serie = $2, passport_number = $3, surname = $4, name = $5, patronymic = $6, address = $7
-			WHERE id = $1
-		`, user.UserID, user.PassportSerie, user.PassportNumber, user.Surname, user.Name, user.Patronymic, user.Address)
-		if err != nil {
-			log.Error("Failed to update user", slog.Any("user", user), slog.String("error", err.Error()))
-			http.Error(w, fmt.Sprintf("Failed to update user: %v", err), http.StatusInternalServerError)
+		if user.Role != "" && user.Role != model.RoleEmployee && user.Role != model.RoleManager {
+			log.Warn("Invalid role", slog.String("role", user.Role))
+			http.Error(w, "Invalid role", http.StatusBadRequest)
 			return
 		}
 
-		rowsAffected, err := result.RowsAffected()
-		if err != nil {
-			log.Error("Error checking rows affected", slog.String("error", err.Error()))
-			http.Error(w, fmt.Sprintf("Error checking rows affected: %v", err), http.StatusInternalServerError)
-			return
+		if user.Timezone != "" {
+			if _, err := storage.LoadTimezone(user.Timezone); err != nil {
+				log.Warn("Invalid timezone", slog.String("timezone", user.Timezone))
+				http.Error(w, "Invalid timezone", http.StatusBadRequest)
+				return
+			}
+		}
+
+		updatePassport := user.PassportSerie != 0 || user.PassportNumber != 0
+		if updatePassport {
+			if err := passport.Validate(user.PassportSerie, user.PassportNumber); err != nil {
+				log.Warn("Invalid passport", slog.String("error", err.Error()))
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
 		}
 
-		if rowsAffected == 0 {
+		log.Debug("Updating user", slog.Any("user", user))
+		err = updateUser(r.Context(), db, cipher, user)
+		if errors.Is(err, sql.ErrNoRows) {
 			log.Info("No user found with the given ID", slog.Int("userID", userID))
 			http.Error(w, "No user found with the given ID", http.StatusNotFound)
 			return
 		}
+		if errors.Is(err, util.ErrPassportExists) {
+			log.Warn("User with this passport already exists", slog.Int("userID", userID))
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Error("Failed to update user", slog.Any("user", user), slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Failed to update user: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		if updatePassport {
+			visible, err := passportVisible(db, r)
+			if err != nil {
+				log.Error("Failed to check caller role", slog.String("error", err.Error()))
+			}
+			user.PassportSerie, user.PassportNumber, user.Passport = passportFields(user.PassportSerie, user.PassportNumber, visible)
+		}
 
 		cache.UserCacheMutex.Lock()
 		defer cache.UserCacheMutex.Unlock()
 
 		if existingUser, ok := cache.UserCache[user.UserID]; ok {
-			existingUser.PassportSerie = user.PassportSerie
-			existingUser.PassportNumber = user.PassportNumber
 			existingUser.Surname = user.Surname
 			existingUser.Name = user.Name
 			existingUser.Patronymic = user.Patronymic
 			existingUser.Address = user.Address
+			if user.Role != "" {
+				existingUser.Role = user.Role
+			}
+			if user.Timezone != "" {
+				existingUser.Timezone = user.Timezone
+			}
 			cache.UserCache[user.UserID] = existingUser
 			log.Debug("Updated user in cache", slog.Any("user", existingUser))
 		} else {
@@ -92,3 +129,79 @@ func UpdateUserHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
 		json.NewEncoder(w).Encode(user)
 	}
 }
+
+// updateUser updates the user and records the change in the audit log in one transaction.
+// The passport is replaced only when given; in the audit log it is stored masked.
+func updateUser(ctx context.Context, db *sql.DB, cipher *passport.Cipher, user model.Users) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var before model.Users
+	var encrypted []byte
+	err = tx.QueryRow(`
+		SELECT id, passport_encrypted, surname, name, COALESCE(patronymic, ''), address, role, timezone
+		FROM users
+		WHERE id = $1
+		FOR UPDATE`, user.UserID).Scan(&before.UserID, &encrypted, &before.Surname, &before.Name, &before.Patronymic,
+		&before.Address, &before.Role, &before.Timezone)
+	if err != nil {
+		return err
+	}
+	if encrypted != nil {
+		serie, number, err := cipher.Decrypt(encrypted)
+		if err != nil {
+			return err
+		}
+		before.Passport = passport.Mask(serie, number)
+	}
+
+	after := before
+	after.Surname, after.Name, after.Patronymic, after.Address = user.Surname, user.Name, user.Patronymic, user.Address
+	if user.Role != "" {
+		after.Role = user.Role
+	}
+	if user.Timezone != "" {
+		after.Timezone = user.Timezone
+	}
+
+	// Паспорт шифруется и меняется только если передан; иначе остается прежним
+	var newEncrypted, passportHash, serieHash, numberHash interface{}
+	if user.PassportSerie != 0 || user.PassportNumber != 0 {
+		data, err := cipher.Encrypt(user.PassportSerie, user.PassportNumber)
+		if err != nil {
+			return err
+		}
+		newEncrypted, passportHash = data, cipher.Index(user.PassportSerie, user.PassportNumber)
+		serieHash, numberHash = cipher.SerieIndex(user.PassportSerie), cipher.NumberIndex(user.PassportNumber)
+		after.Passport = passport.Mask(user.PassportSerie, user.PassportNumber)
+	}
+
+	_, err = tx.Exec(`
+		UPDATE users
+		SET passport_encrypted = COALESCE($2, passport_encrypted),
+			passport_hash = COALESCE($3, passport_hash),
+			passport_serie_hash = COALESCE($4, passport_serie_hash),
+			passport_number_hash = COALESCE($5, passport_number_hash),
+			surname = $6, name = $7, patronymic = $8, address = $9, role = $10, timezone = $11
+		WHERE id = $1
+	`, user.UserID, newEncrypted, passportHash, serieHash, numberHash, after.Surname, after.Name, after.Patronymic, after.Address, after.Role, after.Timezone)
+	if storage.IsUniqueViolation(err) {
+		return util.ErrPassportExists
+	}
+	if err != nil {
+		return err
+	}
+
+	err = audit.Record(ctx, tx, audit.Change{Action: audit.ActionUpdated, Entity: audit.EntityUser, EntityID: user.UserID, UserID: user.UserID, Before: before, After: after})
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
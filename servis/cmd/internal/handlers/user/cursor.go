@@ -0,0 +1,76 @@
+package user
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// sortColumns maps the allowed values of the sort parameter to SQL expressions.
+// Only these expressions are ever placed into the query text. Nullable columns are
+// coalesced so that keyset comparisons never see NULL. Passport fields are encrypted
+// and cannot be sorted by.
+var sortColumns = map[string]string{
+	"id":         "id",
+	"surname":    "surname",
+	"name":       "name",
+	"patronymic": "COALESCE(patronymic, '')",
+	"address":    "address",
+}
+
+// userSort is a parsed sort parameter: a column from sortColumns and a direction.
+type userSort struct {
+	field string
+	desc  bool
+}
+
+// parseSort parses values like "surname" or "-surname" (descending). Empty means "id".
+func parseSort(s string) (userSort, error) {
+	if s == "" {
+		return userSort{field: "id"}, nil
+	}
+	sort := userSort{field: strings.TrimPrefix(s, "-"), desc: strings.HasPrefix(s, "-")}
+	if _, ok := sortColumns[sort.field]; !ok {
+		return sort, errors.New("invalid sort, expected one of: id, surname, name, patronymic, address with optional - prefix")
+	}
+	return sort, nil
+}
+
+// String returns the sort in the form accepted by parseSort.
+func (s userSort) String() string {
+	if s.desc {
+		return "-" + s.field
+	}
+	return s.field
+}
+
+// userCursor is the position after the last item of a page: the sort it was issued for,
+// the sort key of the last row as text and its id as a tie-breaker.
+type userCursor struct {
+	Sort string `json:"s"`
+	Key  string `json:"k"`
+	ID   int    `json:"id"`
+}
+
+// encodeCursor returns an opaque cursor string.
+func encodeCursor(c userCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses a cursor and checks that it was issued for the same sort.
+func decodeCursor(s string, sort userSort) (userCursor, error) {
+	var c userCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, errors.New("invalid cursor")
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, errors.New("invalid cursor")
+	}
+	if c.Sort != sort.String() {
+		return c, errors.New("cursor was issued for a different sort")
+	}
+	return c, nil
+}
@@ -0,0 +1,44 @@
+package user
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"main.go/cmd/internal/handlers/util"
+	"main.go/cmd/internal/passport"
+	model "main.go/tracker_model"
+)
+
+// passportVisible reports whether the caller identified by the X-User-ID header may see
+// full passport data. Only managers may; anonymous and unknown callers get masked data.
+func passportVisible(db *sql.DB, r *http.Request) (bool, error) {
+	actorID, err := util.ActorID(r)
+	if err != nil {
+		return false, nil
+	}
+	role, err := userRole(db, actorID)
+	if err != nil {
+		return false, err
+	}
+	return role == model.RoleManager, nil
+}
+
+// userRole returns the role of the user, or an empty string if there is no such user.
+func userRole(db *sql.DB, userID int) (string, error) {
+	var role string
+	err := db.QueryRow(`SELECT role FROM users WHERE id = $1`, userID).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return role, err
+}
+
+// passportFields returns the passport serie, number and display value for a response:
+// full for privileged callers, masked for everyone else.
+func passportFields(serie, number int, visible bool) (int, int, string) {
+	if visible {
+		return serie, number, passport.Format(serie, number)
+	}
+	return 0, 0, passport.Mask(serie, number)
+}
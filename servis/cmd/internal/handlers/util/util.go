@@ -1,12 +1,38 @@
 package util
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log/slog"
 	"net/http"
+	neturl "net/url"
+	"strconv"
+	"time"
+
+	"main.go/cmd/internal/audit"
+	"main.go/cmd/internal/events"
+	"main.go/cmd/internal/metrics"
+	"main.go/cmd/internal/outbox"
+	"main.go/cmd/internal/passport"
+	"main.go/cmd/internal/storage"
+	model "main.go/tracker_model"
+)
+
+// UserInfoAPIURL адрес внешнего API сведений о пользователе.
+const UserInfoAPIURL = "http://localhost:8081/userinfo"
+
+// ActorHeader заголовок запроса с идентификатором пользователя, выполняющего действие.
+const ActorHeader = "X-User-ID"
+
+var (
+	userInfoRequests = metrics.NewCounterVec("time_tracker_user_info_api_requests_total",
+		"Количество запросов к внешнему API сведений о пользователе по результату (ok, error, bad_status, invalid_response).", "outcome")
+	userInfoDuration = metrics.NewHistogramVec("time_tracker_user_info_api_duration_seconds",
+		"Длительность запросов к внешнему API сведений о пользователе в секундах.", metrics.DefaultBuckets, "outcome")
 )
 
 type APIResponse struct {
@@ -16,32 +42,61 @@ type APIResponse struct {
 	Address    string `json:"address"`
 }
 
+// LogValue реализует slog.LogValuer: персональные данные в журнал не попадают,
+// записывается только то, какие поля заполнены.
+func (r APIResponse) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Bool("has_surname", r.Surname != ""),
+		slog.Bool("has_name", r.Name != ""),
+		slog.Bool("has_patronymic", r.Patronymic != ""),
+		slog.Bool("has_address", r.Address != ""),
+	)
+}
+
 // getUserInfoFromAPI выполняет запрос к внешнему API для получения информации о пользователе
 func GetUserInfoFromAPI(log *slog.Logger, passportSerie, passportNumber int) (APIResponse, error) {
 	var apiResponse APIResponse
-	url := fmt.Sprintf("http://localhost:8081/userinfo?passportSerie=%d&passportNumber=%d", passportSerie, passportNumber)
+	// Результат запроса учитывается в метриках по виду ошибки
+	start := time.Now()
+	outcome := "ok"
+	defer func() {
+		userInfoRequests.Inc(outcome)
+		userInfoDuration.Observe(time.Since(start).Seconds(), outcome)
+	}()
+
+	// Адрес запроса содержит паспортные данные, поэтому в журнал и ошибки попадает только адрес без параметров
+	url := UserInfoAPIURL
+	requestURL := fmt.Sprintf("%s?passportSerie=%d&passportNumber=%d", url, passportSerie, passportNumber)
 
 	log.Debug("Sending request to external API", slog.String("url", url))
-	resp, err := http.Get(url)
+	resp, err := http.Get(requestURL)
 	if err != nil {
+		outcome = "error"
+		var urlErr *neturl.Error
+		if errors.As(err, &urlErr) {
+			err = fmt.Errorf("request to %s failed: %v", url, urlErr.Err)
+		}
 		log.Error("Failed to get response from API", slog.String("url", url), slog.String("error", err.Error()))
 		return apiResponse, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
+		outcome = "bad_status"
 		log.Error("Failed to get user info from API", slog.String("url", url), slog.Int("status_code", resp.StatusCode))
 		return apiResponse, fmt.Errorf("failed to get user info, status code: %d", resp.StatusCode)
 	}
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
+		outcome = "error"
 		log.Error("Failed to read API response body", slog.String("url", url), slog.String("error", err.Error()))
 		return apiResponse, err
 	}
 
 	err = json.Unmarshal(body, &apiResponse)
 	if err != nil {
+		outcome = "invalid_response"
 		log.Error("Failed to unmarshal API response", slog.String("url", url), slog.String("error", err.Error()))
 		return apiResponse, err
 	}
@@ -50,19 +105,94 @@ func GetUserInfoFromAPI(log *slog.Logger, passportSerie, passportNumber int) (AP
 	return apiResponse, nil
 }
 
-// addUserToDB добавляет нового пользователя в базу данных и возвращает его ID
-func AddUserToDB(log *slog.Logger, db *sql.DB, passportSerie, passportNumber int, apiResponse APIResponse) (int, error) {
+// PingUserInfoAPI проверяет, что внешнее API сведений о пользователе отвечает.
+// Запрос отправляется без паспортных данных, поэтому любой HTTP-ответ считается успехом.
+func PingUserInfoAPI(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, UserInfoAPIURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		var urlErr *neturl.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("request to %s failed: %v", UserInfoAPIURL, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// ErrPassportExists возвращается при добавлении пользователя с уже зарегистрированным паспортом.
+var ErrPassportExists = errors.New("user with this passport already exists")
+
+// addUserToDB добавляет нового пользователя в базу данных и возвращает его ID.
+// Паспорт сохраняется зашифрованным вместе со слепыми индексами.
+func AddUserToDB(ctx context.Context, log *slog.Logger, db *sql.DB, cipher *passport.Cipher, passportSerie, passportNumber int, timezone string, apiResponse APIResponse) (int, error) {
 	var userID int
 	log.Debug("Adding user to database", slog.Any("apiResponse", apiResponse))
-	err := db.QueryRow(`
-		INSERT INTO users (passport_serie, passport_number, surname, name, patronymic, address)
-		VALUES ($1, $2, $3, $4, $5, $6)
+	encrypted, err := cipher.Encrypt(passportSerie, passportNumber)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		INSERT INTO users (passport_encrypted, passport_hash, passport_serie_hash, passport_number_hash,
+			surname, name, patronymic, address, timezone)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 		RETURNING id
-	`, passportSerie, passportNumber, apiResponse.Surname, apiResponse.Name, apiResponse.Patronymic, apiResponse.Address).Scan(&userID)
+	`, encrypted, cipher.Index(passportSerie, passportNumber), cipher.SerieIndex(passportSerie), cipher.NumberIndex(passportNumber),
+		apiResponse.Surname, apiResponse.Name, apiResponse.Patronymic, apiResponse.Address, timezone).Scan(&userID)
+	if storage.IsUniqueViolation(err) {
+		return 0, ErrPassportExists
+	}
 	if err != nil {
 		log.Error("Failed to add user to database", slog.Any("apiResponse", apiResponse), slog.String("error", err.Error()))
 		return 0, err
 	}
+
+	// Событие о новом пользователе сохраняется вместе с ним; паспорт передается внешним получателям только маскированным
+	user := model.Users{
+		UserID:     userID,
+		Passport:   passport.Mask(passportSerie, passportNumber),
+		Surname:    apiResponse.Surname,
+		Name:       apiResponse.Name,
+		Patronymic: apiResponse.Patronymic,
+		Address:    apiResponse.Address,
+		Role:       model.RoleEmployee,
+		Timezone:   timezone,
+	}
+	if err := outbox.EnqueueUser(tx, events.UserAdded, user); err != nil {
+		return 0, err
+	}
+	err = audit.Record(ctx, tx, audit.Change{Action: audit.ActionCreated, Entity: audit.EntityUser, EntityID: userID, UserID: userID, After: user})
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %v", err)
+	}
 	log.Info("User successfully added to database", slog.Int("userID", userID))
 	return userID, nil
 }
+
+// ActorID возвращает идентификатор пользователя, выполняющего запрос, из заголовка ActorHeader.
+func ActorID(r *http.Request) (int, error) {
+	actorStr := r.Header.Get(ActorHeader)
+	if actorStr == "" {
+		return 0, fmt.Errorf("missing %s header", ActorHeader)
+	}
+	actorID, err := strconv.Atoi(actorStr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s header: %v", ActorHeader, err)
+	}
+	return actorID, nil
+}
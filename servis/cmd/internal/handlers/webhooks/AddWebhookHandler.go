@@ -0,0 +1,137 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+
+	"github.com/lib/pq"
+	"main.go/cmd/internal/audit"
+	"main.go/cmd/internal/events"
+	model "main.go/tracker_model"
+)
+
+// WebhookInput представляет данные запроса на создание подписки.
+type WebhookInput struct {
+	URL        string   `json:"url"`         // Адрес получателя, http или https
+	EventTypes []string `json:"event_types"` // Типы событий, например session.ended или user.added
+	Secret     string   `json:"secret"`      // Секрет подписи; если не задан, генерируется
+}
+
+// AddWebhookHandler обрабатывает запросы на создание webhook-подписки.
+// Секрет возвращается в ответе только при создании.
+// @Summary Создание webhook-подписки
+// @Description Подписывает внешнюю систему на события трекера; доставки подписываются HMAC-SHA256.
+// @Tags Webhook
+// @Accept json
+// @Produce json
+// @Param webhook body WebhookInput true "Подписка"
+// @Success 201 {object} model.Webhook "Созданная подписка"
+// @Failure 400 {string} string "Неверный формат ввода"
+// @Failure 500 {string} string "Ошибка при создании подписки"
+// @Router /api/v1/webhooks [post]
+func AddWebhookHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var input WebhookInput
+
+		err := json.NewDecoder(r.Body).Decode(&input)
+		if err != nil {
+			log.Error("Неверный формат ввода", slog.String("error", err.Error()))
+			http.Error(w, "Неверный формат ввода", http.StatusBadRequest)
+			return
+		}
+
+		if err := validateWebhookInput(input); err != nil {
+			log.Warn("Неверные параметры подписки", slog.String("error", err.Error()))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if input.Secret == "" {
+			input.Secret, err = generateSecret()
+			if err != nil {
+				log.Error("Ошибка генерации секрета", slog.String("error", err.Error()))
+				http.Error(w, "Ошибка генерации секрета", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		webhook := model.Webhook{URL: input.URL, EventTypes: input.EventTypes, Secret: input.Secret, Active: true}
+		err = addWebhook(r.Context(), db, &webhook)
+		if err != nil {
+			log.Error("Ошибка при создании подписки", slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Ошибка при создании подписки: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		log.Info("Webhook-подписка создана", slog.Int("webhook_id", webhook.ID), slog.String("url", webhook.URL), slog.Any("event_types", webhook.EventTypes))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(webhook)
+	}
+}
+
+// addWebhook в одной транзакции сохраняет подписку и запись о ней в журнале аудита.
+// Секрет подписи в журнал не попадает.
+func addWebhook(ctx context.Context, db *sql.DB, webhook *model.Webhook) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("ошибка при начале транзакции: %v", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		INSERT INTO webhooks (url, event_types, secret)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`, webhook.URL, pq.Array(webhook.EventTypes), webhook.Secret).Scan(&webhook.ID, &webhook.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	logged := *webhook
+	logged.Secret = ""
+	err = audit.Record(ctx, tx, audit.Change{Action: audit.ActionCreated, Entity: audit.EntityWebhook, EntityID: webhook.ID, After: logged})
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ошибка при фиксации транзакции: %v", err)
+	}
+	return nil
+}
+
+// validateWebhookInput проверяет адрес получателя и типы событий подписки.
+func validateWebhookInput(input WebhookInput) error {
+	u, err := url.Parse(input.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("неверный url: %q", input.URL)
+	}
+	if len(input.EventTypes) == 0 {
+		return fmt.Errorf("необходимо указать event_types")
+	}
+	for _, t := range input.EventTypes {
+		if !slices.Contains(events.Types, t) {
+			return fmt.Errorf("неизвестный тип события: %q", t)
+		}
+	}
+	return nil
+}
+
+// generateSecret генерирует случайный секрет подписи.
+func generateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,80 @@
+package webhooks
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/lib/pq"
+	"main.go/cmd/internal/audit"
+	model "main.go/tracker_model"
+)
+
+// DeleteWebhookHandler обрабатывает запросы на удаление webhook-подписки вместе с журналом доставки.
+// @Summary Удаление webhook-подписки
+// @Tags Webhook
+// @Param id path int true "Идентификатор подписки"
+// @Success 204 "Подписка удалена"
+// @Failure 400 {string} string "Неверный идентификатор подписки"
+// @Failure 404 {string} string "Подписка не найдена"
+// @Failure 500 {string} string "Ошибка при удалении подписки"
+// @Router /api/v1/webhooks/{id} [delete]
+func DeleteWebhookHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := r.PathValue("id")
+		webhookID, err := strconv.Atoi(idStr)
+		if err != nil {
+			log.Error("Неверный идентификатор подписки", slog.String("idStr", idStr), slog.String("error", err.Error()))
+			http.Error(w, "Неверный идентификатор подписки", http.StatusBadRequest)
+			return
+		}
+
+		err = deleteWebhook(r.Context(), db, webhookID)
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Info("Подписка не найдена", slog.Int("webhook_id", webhookID))
+			http.Error(w, "Подписка не найдена", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Error("Ошибка при удалении подписки", slog.Int("webhook_id", webhookID), slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Ошибка при удалении подписки: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		log.Info("Webhook-подписка удалена", slog.Int("webhook_id", webhookID))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// deleteWebhook в одной транзакции удаляет подписку и записывает ее прежнее состояние в журнал аудита.
+// Если подписка не найдена, возвращается sql.ErrNoRows.
+func deleteWebhook(ctx context.Context, db *sql.DB, webhookID int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("ошибка при начале транзакции: %v", err)
+	}
+	defer tx.Rollback()
+
+	webhook := model.Webhook{ID: webhookID}
+	err = tx.QueryRow(`
+		DELETE FROM webhooks WHERE id = $1
+		RETURNING url, event_types, active, created_at
+	`, webhookID).Scan(&webhook.URL, pq.Array(&webhook.EventTypes), &webhook.Active, &webhook.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	err = audit.Record(ctx, tx, audit.Change{Action: audit.ActionDeleted, Entity: audit.EntityWebhook, EntityID: webhookID, Before: webhook})
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ошибка при фиксации транзакции: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package webhooks
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	model "main.go/tracker_model"
+)
+
+// deliveriesLimit максимальное количество доставок в ответе.
+const deliveriesLimit = 100
+
+// GetWebhookDeliveriesHandler обрабатывает запросы на получение доставок событий по подписке
+// с состоянием и результатом последней попытки, от последних событий к первым.
+// @Summary Журнал доставки webhook-подписки
+// @Tags Webhook
+// @Produce json
+// @Param id path int true "Идентификатор подписки"
+// @Success 200 {array} model.WebhookDelivery "Доставки событий"
+// @Failure 400 {string} string "Неверный идентификатор подписки"
+// @Failure 500 {string} string "Ошибка при выполнении запроса к базе данных"
+// @Router /api/v1/webhooks/{id}/deliveries [get]
+func GetWebhookDeliveriesHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := r.PathValue("id")
+		webhookID, err := strconv.Atoi(idStr)
+		if err != nil {
+			log.Error("Неверный идентификатор подписки", slog.String("idStr", idStr), slog.String("error", err.Error()))
+			http.Error(w, "Неверный идентификатор подписки", http.StatusBadRequest)
+			return
+		}
+
+		rows, err := db.Query(`
+			SELECT id, webhook_id, event_id, event_type, status, attempt, status_code, success, error, next_attempt_at, created_at, updated_at
+			FROM webhook_deliveries
+			WHERE webhook_id = $1
+			ORDER BY id DESC
+			LIMIT $2
+		`, webhookID, deliveriesLimit)
+		if err != nil {
+			log.Error("Ошибка выполнения запроса к базе данных", slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Database query failed: %v", err), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		deliveries := []model.WebhookDelivery{}
+		for rows.Next() {
+			var d model.WebhookDelivery
+			var statusCode sql.NullInt64
+			var nextAttemptAt sql.NullTime
+			err := rows.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.Status, &d.Attempt, &statusCode, &d.Success, &d.Error,
+				&nextAttemptAt, &d.CreatedAt, &d.UpdatedAt)
+			if err != nil {
+				log.Error("Ошибка сканирования строки результата", slog.String("error", err.Error()))
+				http.Error(w, fmt.Sprintf("Failed to scan row: %v", err), http.StatusInternalServerError)
+				return
+			}
+			d.StatusCode = int(statusCode.Int64)
+			if nextAttemptAt.Valid && d.Status == model.DeliveryPending {
+				d.NextAttemptAt = &nextAttemptAt.Time
+			}
+			deliveries = append(deliveries, d)
+		}
+
+		if err = rows.Err(); err != nil {
+			log.Error("Ошибка итерации по строкам результата", slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Row iteration error: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(deliveries)
+	}
+}
@@ -0,0 +1,52 @@
+package webhooks
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/lib/pq"
+	model "main.go/tracker_model"
+)
+
+// GetWebhooksHandler обрабатывает запросы на получение списка webhook-подписок без секретов.
+// @Summary Список webhook-подписок
+// @Tags Webhook
+// @Produce json
+// @Success 200 {array} model.Webhook "Подписки"
+// @Failure 500 {string} string "Ошибка при выполнении запроса к базе данных"
+// @Router /api/v1/webhooks [get]
+func GetWebhooksHandler(db *sql.DB, log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rows, err := db.Query(`SELECT id, url, event_types, active, created_at FROM webhooks ORDER BY id`)
+		if err != nil {
+			log.Error("Ошибка выполнения запроса к базе данных", slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Database query failed: %v", err), http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+
+		webhooks := []model.Webhook{}
+		for rows.Next() {
+			var webhook model.Webhook
+			err := rows.Scan(&webhook.ID, &webhook.URL, pq.Array(&webhook.EventTypes), &webhook.Active, &webhook.CreatedAt)
+			if err != nil {
+				log.Error("Ошибка сканирования строки результата", slog.String("error", err.Error()))
+				http.Error(w, fmt.Sprintf("Failed to scan row: %v", err), http.StatusInternalServerError)
+				return
+			}
+			webhooks = append(webhooks, webhook)
+		}
+
+		if err = rows.Err(); err != nil {
+			log.Error("Ошибка итерации по строкам результата", slog.String("error", err.Error()))
+			http.Error(w, fmt.Sprintf("Row iteration error: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(webhooks)
+	}
+}
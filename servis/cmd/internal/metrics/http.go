@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	httpRequests = NewCounterVec("time_tracker_http_requests_total",
+		"Количество HTTP-запросов по маршруту, методу и коду ответа.", "method", "route", "status")
+	httpDuration = NewHistogramVec("time_tracker_http_request_duration_seconds",
+		"Длительность обработки HTTP-запросов в секундах.", DefaultBuckets, "method", "route", "status")
+)
+
+// knownMethods методы, сохраняемые в метке method; остальные учитываются как OTHER.
+var knownMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true,
+	http.MethodPatch: true, http.MethodDelete: true, http.MethodOptions: true,
+}
+
+// Middleware считает запросы к mux и время их обработки. Маршрутом считается шаблон,
+// с которым зарегистрирован обработчик (например, GET /users/{id}/current), поэтому
+// идентификаторы из пути не увеличивают число серий; запросы без обработчика учитываются как unmatched.
+func Middleware(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, route := mux.Handler(r)
+		if route == "" {
+			route = "unmatched"
+		}
+		method := r.Method
+		if !knownMethods[method] {
+			method = "OTHER"
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		mux.ServeHTTP(rec, r)
+
+		status := strconv.Itoa(rec.status)
+		httpRequests.Inc(method, route, status)
+		httpDuration.Observe(time.Since(start).Seconds(), method, route, status)
+	})
+}
+
+// statusRecorder запоминает код ответа обработчика.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+// Flush нужен потоковым обработчикам (/events), которые проверяют http.Flusher.
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		r.wroteHeader = true
+		f.Flush()
+	}
+}
+
+// Unwrap позволяет http.ResponseController добраться до исходного ResponseWriter.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
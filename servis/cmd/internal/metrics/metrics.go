@@ -0,0 +1,309 @@
+// Package metrics собирает метрики сервиса и отдает их в текстовом формате Prometheus.
+// Метрики объявляются переменными пакетов, которые их обновляют, и регистрируются при создании.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets границы гистограмм длительности в секундах.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// collector метрика, которую можно вывести в формате Prometheus.
+type collector interface {
+	metricName() string
+	write(w io.Writer) error
+}
+
+var (
+	registryMutex sync.Mutex
+	registry      []collector
+)
+
+// register добавляет метрику в реестр. Повторное имя — ошибка программы.
+func register(c collector) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	for _, existing := range registry {
+		if existing.metricName() == c.metricName() {
+			panic(fmt.Sprintf("metrics: метрика %s уже зарегистрирована", c.metricName()))
+		}
+	}
+	registry = append(registry, c)
+}
+
+// series значения метрики с одним набором меток.
+type series struct {
+	labels []string
+	value  float64  // значение счетчика
+	counts []uint64 // счетчики корзин гистограммы, не накопительные
+	sum    float64  // сумма наблюдений гистограммы
+	count  uint64   // количество наблюдений гистограммы
+}
+
+// vec общая часть метрик с метками.
+type vec struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+func (v *vec) metricName() string { return v.name }
+
+// get возвращает серию для значений меток, создавая ее при первом обращении. Вызывается под v.mu.
+func (v *vec) get(values []string, buckets int) *series {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s ожидает %d меток, получено %d", v.name, len(v.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{labels: append([]string(nil), values...), counts: make([]uint64, buckets)}
+		v.series[key] = s
+	}
+	return s
+}
+
+// sorted возвращает копии серий, упорядоченные по значениям меток. Вызывается под v.mu.
+func (v *vec) sorted() []series {
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	result := make([]series, 0, len(keys))
+	for _, key := range keys {
+		s := *v.series[key]
+		s.counts = append([]uint64(nil), s.counts...)
+		result = append(result, s)
+	}
+	return result
+}
+
+// CounterVec монотонно растущий счетчик с метками.
+type CounterVec struct {
+	vec
+}
+
+// NewCounterVec создает и регистрирует счетчик с метками labels.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{vec{name: name, help: help, labels: labels, series: map[string]*series{}}}
+	register(c)
+	return c
+}
+
+// Inc увеличивает счетчик с указанными значениями меток на единицу.
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add увеличивает счетчик с указанными значениями меток на delta (delta не меньше нуля).
+func (c *CounterVec) Add(delta float64, values ...string) {
+	if delta < 0 {
+		panic(fmt.Sprintf("metrics: счетчик %s не может уменьшаться", c.name))
+	}
+	c.mu.Lock()
+	c.get(values, 0).value += delta
+	c.mu.Unlock()
+}
+
+func (c *CounterVec) write(w io.Writer) error {
+	c.mu.Lock()
+	all := c.sorted()
+	c.mu.Unlock()
+
+	if err := writeHeader(w, c.name, c.help, "counter"); err != nil {
+		return err
+	}
+	for _, s := range all {
+		if err := writeSample(w, c.name, c.labels, s.labels, "", "", s.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HistogramVec гистограмма наблюдений с метками.
+type HistogramVec struct {
+	vec
+	buckets []float64
+}
+
+// NewHistogramVec создает и регистрирует гистограмму с верхними границами корзин buckets
+// (по возрастанию) и метками labels.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if !sort.Float64sAreSorted(buckets) {
+		panic(fmt.Sprintf("metrics: границы корзин %s должны быть упорядочены", name))
+	}
+	h := &HistogramVec{vec: vec{name: name, help: help, labels: labels, series: map[string]*series{}}, buckets: buckets}
+	register(h)
+	return h
+}
+
+// Observe добавляет наблюдение value в гистограмму с указанными значениями меток.
+func (h *HistogramVec) Observe(value float64, values ...string) {
+	i := sort.SearchFloat64s(h.buckets, value)
+	h.mu.Lock()
+	s := h.get(values, len(h.buckets))
+	if i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.sum += value
+	s.count++
+	h.mu.Unlock()
+}
+
+func (h *HistogramVec) write(w io.Writer) error {
+	h.mu.Lock()
+	all := h.sorted()
+	h.mu.Unlock()
+
+	if err := writeHeader(w, h.name, h.help, "histogram"); err != nil {
+		return err
+	}
+	for _, s := range all {
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			if err := writeSample(w, h.name+"_bucket", h.labels, s.labels, "le", formatFloat(bound), float64(cumulative)); err != nil {
+				return err
+			}
+		}
+		if err := writeSample(w, h.name+"_bucket", h.labels, s.labels, "le", "+Inf", float64(s.count)); err != nil {
+			return err
+		}
+		if err := writeSample(w, h.name+"_sum", h.labels, s.labels, "", "", s.sum); err != nil {
+			return err
+		}
+		if err := writeSample(w, h.name+"_count", h.labels, s.labels, "", "", float64(s.count)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GaugeFunc показатель, значение которого вычисляется при каждом запросе метрик.
+type GaugeFunc struct {
+	name  string
+	help  string
+	value func() (float64, error)
+}
+
+// NewGaugeFunc создает и регистрирует показатель, вычисляемый функцией value.
+// Если value возвращает ошибку, показатель пропускается в ответе.
+func NewGaugeFunc(name, help string, value func() (float64, error)) *GaugeFunc {
+	g := &GaugeFunc{name: name, help: help, value: value}
+	register(g)
+	return g
+}
+
+func (g *GaugeFunc) metricName() string { return g.name }
+
+func (g *GaugeFunc) write(w io.Writer) error {
+	value, err := g.value()
+	if err != nil {
+		return gaugeError{name: g.name, err: err}
+	}
+	if err := writeHeader(w, g.name, g.help, "gauge"); err != nil {
+		return err
+	}
+	return writeSample(w, g.name, nil, nil, "", "", value)
+}
+
+// gaugeError ошибка вычисления показателя; остальные метрики при этом выводятся.
+type gaugeError struct {
+	name string
+	err  error
+}
+
+func (e gaugeError) Error() string {
+	return fmt.Sprintf("ошибка вычисления метрики %s: %v", e.name, e.err)
+}
+
+// Handler отдает все зарегистрированные метрики в текстовом формате Prometheus.
+// Ошибки вычисления отдельных показателей записываются в журнал.
+func Handler(log *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		registryMutex.Lock()
+		collectors := append([]collector(nil), registry...)
+		registryMutex.Unlock()
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		for _, c := range collectors {
+			err := c.write(w)
+			if gErr, ok := err.(gaugeError); ok {
+				log.Warn("Метрика пропущена", slog.String("metric", gErr.name), slog.String("error", gErr.err.Error()))
+				continue
+			}
+			if err != nil {
+				log.Error("Ошибка записи метрик", slog.String("error", err.Error()))
+				return
+			}
+		}
+	})
+}
+
+func writeHeader(w io.Writer, name, help, typ string) error {
+	help = strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	return err
+}
+
+// writeSample выводит одно значение; extraName и extraValue — дополнительная метка (le у корзин).
+func writeSample(w io.Writer, name string, labels, values []string, extraName, extraValue string, value float64) error {
+	var b strings.Builder
+	b.WriteString(name)
+	if len(labels) > 0 || extraName != "" {
+		b.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeLabel(&b, label, values[i])
+		}
+		if extraName != "" {
+			if len(labels) > 0 {
+				b.WriteByte(',')
+			}
+			writeLabel(&b, extraName, extraValue)
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte(' ')
+	b.WriteString(formatFloat(value))
+	b.WriteByte('\n')
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func writeLabel(b *strings.Builder, name, value string) {
+	b.WriteString(name)
+	b.WriteString(`="`)
+	b.WriteString(labelEscaper.Replace(value))
+	b.WriteByte('"')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,223 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+	"main.go/cmd/internal/config"
+	"main.go/cmd/internal/events"
+	"main.go/cmd/internal/storage"
+	model "main.go/tracker_model"
+)
+
+// Sink получатель событий из outbox. Ошибка оставляет событие неопубликованным, и при следующей
+// попытке оно передается только получателям, которые его еще не приняли. Если экземпляр сервиса
+// остановится во время передачи, событие будет передано повторно, поэтому получатели должны быть
+// готовы к повторной доставке события с тем же идентификатором.
+type Sink func(ctx context.Context, event events.Event) error
+
+// Enqueue записывает событие в outbox. Вызывается в той же транзакции, что и изменение данных,
+// поэтому событие сохраняется тогда и только тогда, когда фиксируется само изменение.
+func Enqueue(q storage.Querier, event events.Event) error {
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации события: %v", err)
+	}
+	_, err = q.Exec(`
+		INSERT INTO outbox (event_type, user_id, payload, created_at)
+		VALUES ($1, $2, $3, $4)
+	`, event.Type, event.UserID, string(payload), event.OccurredAt)
+	if err != nil {
+		return fmt.Errorf("ошибка записи события в outbox: %v", err)
+	}
+	return nil
+}
+
+// EnqueueSession записывает в outbox событие типа eventType по сессии task.
+func EnqueueSession(q storage.Querier, eventType string, task model.UserTask) error {
+	return Enqueue(q, events.Event{Type: eventType, UserID: task.UserID, Session: &task})
+}
+
+// EnqueueUser записывает в outbox событие типа eventType по пользователю user.
+func EnqueueUser(q storage.Querier, eventType string, user model.Users) error {
+	return Enqueue(q, events.Event{Type: eventType, UserID: user.UserID, User: &user})
+}
+
+// EnqueueBudget записывает в outbox событие типа eventType о бюджете задачи, вызванное сессией пользователя userID.
+func EnqueueBudget(q storage.Querier, eventType string, userID int, budget model.TaskBudget) error {
+	return Enqueue(q, events.Event{Type: eventType, UserID: userID, Budget: &budget})
+}
+
+// Run периодически публикует неопубликованные события из outbox во все sinks, пока не будет отменен ctx.
+// Событие помечается опубликованным только после успешной передачи всем получателям
+// (семантика at-least-once); получатели, уже принявшие событие, при повторных попытках пропускаются.
+// После cfg.MaxAttempts неудачных попыток событие больше не выбирается
+// и остается в таблице с последней ошибкой для разбора.
+func Run(ctx context.Context, db *sql.DB, log *slog.Logger, cfg config.OutboxConfig, sinks map[string]Sink) {
+	if cfg.Interval <= 0 || cfg.BatchSize <= 0 {
+		log.Warn("Не заданы interval или batch_size, публикация событий из outbox отключена",
+			slog.Duration("interval", cfg.Interval), slog.Int("batch_size", cfg.BatchSize))
+		return
+	}
+	for _, name := range cfg.Sinks {
+		if _, ok := sinks[name]; !ok {
+			log.Warn("Неизвестный получатель событий outbox пропущен", slog.String("sink", name))
+		}
+	}
+	log.Info("Запущена публикация событий из outbox", slog.Duration("interval", cfg.Interval), slog.Any("sinks", cfg.Sinks))
+
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			published, err := relayBatch(ctx, db, log, cfg, sinks)
+			if err != nil {
+				log.Error("Ошибка публикации событий из outbox", slog.String("error", err.Error()))
+				break
+			}
+			// Неполная пачка означает, что очередь разобрана до конца или часть событий
+			// не опубликована; повторная попытка будет на следующем тике
+			if published < cfg.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pendingEvent событие outbox и получатели, которым оно уже передано.
+type pendingEvent struct {
+	event     events.Event
+	published []string
+}
+
+// relayBatch публикует одну пачку событий. Пачка закрепляется за экземпляром в отдельной
+// транзакции, а передача получателям выполняется после ее фиксации, поэтому блокировки строк
+// не удерживаются на время работы получателей. Возвращает количество успешно опубликованных событий.
+func relayBatch(ctx context.Context, db *sql.DB, log *slog.Logger, cfg config.OutboxConfig, sinks map[string]Sink) (int, error) {
+	batch, err := claimBatch(ctx, db, cfg)
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	for _, p := range batch {
+		done, publishErr := publish(ctx, p.event, p.published, cfg.Sinks, sinks)
+		if publishErr != nil {
+			log.Warn("Событие outbox не опубликовано", slog.Int64("event_id", p.event.ID), slog.String("type", p.event.Type),
+				slog.Any("published_sinks", done), slog.String("error", publishErr.Error()))
+			_, err = db.Exec(`
+				UPDATE outbox SET attempts = attempts + 1, last_error = $1, published_sinks = $2, locked_until = NULL WHERE id = $3
+			`, publishErr.Error(), pq.Array(done), p.event.ID)
+			if err != nil {
+				return published, fmt.Errorf("ошибка обновления события outbox %d: %v", p.event.ID, err)
+			}
+			continue
+		}
+
+		_, err = db.Exec(`
+			UPDATE outbox SET published_at = $1, attempts = attempts + 1, last_error = '', published_sinks = $2, locked_until = NULL WHERE id = $3
+		`, time.Now(), pq.Array(done), p.event.ID)
+		if err != nil {
+			return published, fmt.Errorf("ошибка обновления события outbox %d: %v", p.event.ID, err)
+		}
+		published++
+	}
+	return published, nil
+}
+
+// claimBatch выбирает пачку неопубликованных событий и закрепляет ее за экземпляром на cfg.LockTimeout.
+// Строки блокируются FOR UPDATE SKIP LOCKED, поэтому несколько экземпляров сервиса не закрепляют
+// одно событие одновременно; закрепление, не снятое из-за остановки экземпляра, истекает само.
+func claimBatch(ctx context.Context, db *sql.DB, cfg config.OutboxConfig) ([]pendingEvent, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при начале транзакции: %v", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT id, payload, published_sinks FROM outbox
+		WHERE published_at IS NULL AND attempts < $1 AND (locked_until IS NULL OR locked_until < NOW())
+		ORDER BY id
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	`, cfg.MaxAttempts, cfg.BatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении событий из outbox: %v", err)
+	}
+
+	var batch []pendingEvent
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		var payload string
+		var p pendingEvent
+		if err := rows.Scan(&id, &payload, pq.Array(&p.published)); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("ошибка сканирования события outbox: %v", err)
+		}
+		if err := json.Unmarshal([]byte(payload), &p.event); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("ошибка разбора события outbox %d: %v", id, err)
+		}
+		p.event.ID = id
+		batch = append(batch, p)
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по событиям outbox: %v", err)
+	}
+	if len(batch) == 0 {
+		return nil, nil
+	}
+
+	_, err = tx.Exec(`
+		UPDATE outbox SET locked_until = $1 WHERE id = ANY($2)
+	`, time.Now().Add(cfg.LockTimeout), pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка закрепления событий outbox: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ошибка при фиксации транзакции: %v", err)
+	}
+	return batch, nil
+}
+
+// publish передает событие включенным получателям names, которых нет среди уже принявших его published.
+// Ошибка одного получателя не мешает передаче остальным. Возвращает полный список принявших
+// событие получателей и объединенную ошибку неудачных передач.
+func publish(ctx context.Context, event events.Event, published, names []string, sinks map[string]Sink) ([]string, error) {
+	done := append([]string(nil), published...)
+	var errs []error
+	for _, name := range names {
+		sink, ok := sinks[name]
+		if !ok || slices.Contains(done, name) {
+			continue
+		}
+		if err := sink(ctx, event); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		done = append(done, name)
+	}
+	return done, errors.Join(errs...)
+}
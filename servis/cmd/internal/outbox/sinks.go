@@ -0,0 +1,36 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+
+	"main.go/cmd/internal/events"
+	"main.go/cmd/internal/webhook"
+)
+
+// Имена получателей, используемые в настройке outbox.sinks.
+const (
+	SinkBus      = "bus"      // внутренняя шина событий, например для потока /events
+	SinkLog      = "log"      // запись события в журнал приложения
+	SinkWebhooks = "webhooks" // доставка по webhook-подпискам
+)
+
+// Sinks возвращает все доступные получатели событий по именам.
+// Для подключения брокера сообщений достаточно добавить сюда получателя с новым именем.
+// Получатель webhooks только ставит событие в очередь доставки, попытки выполняет webhook.Run.
+func Sinks(db *sql.DB, log *slog.Logger) map[string]Sink {
+	return map[string]Sink{
+		SinkBus: func(ctx context.Context, event events.Event) error {
+			events.Publish(event)
+			return nil
+		},
+		SinkLog: func(ctx context.Context, event events.Event) error {
+			log.Info("Событие", slog.Int64("event_id", event.ID), slog.String("type", event.Type), slog.Int("user_id", event.UserID))
+			return nil
+		},
+		SinkWebhooks: func(ctx context.Context, event events.Event) error {
+			return webhook.Dispatch(ctx, db, event)
+		},
+	}
+}
@@ -0,0 +1,51 @@
+package passport
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// EncryptExisting шифрует паспорта, еще хранящиеся в открытом виде, заполняет слепые индексы
+// и очищает открытые столбцы. Каждая строка обновляется отдельно, поэтому прерванный запуск
+// можно безопасно повторить. Возвращает количество зашифрованных строк.
+func EncryptExisting(db *sql.DB, c *Cipher) (int, error) {
+	rows, err := db.Query(`
+		SELECT id, passport_serie, passport_number
+		FROM users
+		WHERE passport_encrypted IS NULL AND passport_serie IS NOT NULL AND passport_number IS NOT NULL`)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка при получении незашифрованных паспортов: %v", err)
+	}
+
+	type plainPassport struct{ id, serie, number int }
+	var pending []plainPassport
+	for rows.Next() {
+		var p plainPassport
+		if err := rows.Scan(&p.id, &p.serie, &p.number); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("ошибка сканирования паспорта: %v", err)
+		}
+		pending = append(pending, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("ошибка итерации по паспортам: %v", err)
+	}
+
+	for i, p := range pending {
+		encrypted, err := c.Encrypt(p.serie, p.number)
+		if err != nil {
+			return i, err
+		}
+		_, err = db.Exec(`
+			UPDATE users
+			SET passport_encrypted = $2, passport_hash = $3, passport_serie_hash = $4, passport_number_hash = $5,
+				passport_serie = NULL, passport_number = NULL
+			WHERE id = $1`,
+			p.id, encrypted, c.Index(p.serie, p.number), c.SerieIndex(p.serie), c.NumberIndex(p.number))
+		if err != nil {
+			return i, fmt.Errorf("ошибка шифрования паспорта пользователя %d: %v", p.id, err)
+		}
+	}
+	return len(pending), nil
+}
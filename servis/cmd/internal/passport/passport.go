@@ -0,0 +1,156 @@
+// Package passport шифрует паспортные данные пользователей на стороне приложения,
+// строит слепые индексы для поиска и проверки уникальности и маскирует паспорт для вывода.
+package passport
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"main.go/cmd/internal/config"
+)
+
+// Допустимые значения серии и номера паспорта.
+const (
+	MaxSerie  = 9999
+	MaxNumber = 999999
+)
+
+// Cipher шифрует паспорта ключом AES-256-GCM и вычисляет слепые индексы HMAC-SHA256.
+// Оба ключа выводятся из одного мастер-ключа, поэтому утечка индексов не раскрывает ключ шифрования.
+type Cipher struct {
+	aead     cipher.AEAD
+	indexKey []byte
+}
+
+// New загружает мастер-ключ из настроек (Key или файла KeyFile) и возвращает Cipher.
+func New(cfg config.PassportConfig) (*Cipher, error) {
+	encoded := cfg.Key
+	if encoded == "" && cfg.KeyFile != "" {
+		data, err := os.ReadFile(cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения файла ключа: %v", err)
+		}
+		encoded = string(data)
+	}
+	if encoded == "" {
+		return nil, errors.New("не задан ключ шифрования паспортных данных (passport.key или passport.key_file)")
+	}
+
+	master, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("ключ шифрования должен быть в base64: %v", err)
+	}
+	if len(master) != 32 {
+		return nil, fmt.Errorf("ключ шифрования должен быть длиной 32 байта, получено %d", len(master))
+	}
+
+	block, err := aes.NewCipher(deriveKey(master, "passport-encryption"))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead, indexKey: deriveKey(master, "passport-blind-index")}, nil
+}
+
+// deriveKey выводит из мастер-ключа ключ для указанного назначения.
+func deriveKey(master []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, master)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+// Validate проверяет диапазоны серии и номера паспорта.
+func Validate(serie, number int) error {
+	if serie < 0 || serie > MaxSerie {
+		return fmt.Errorf("серия паспорта должна быть от 0 до %d", MaxSerie)
+	}
+	if number < 0 || number > MaxNumber {
+		return fmt.Errorf("номер паспорта должен быть от 0 до %d", MaxNumber)
+	}
+	return nil
+}
+
+// Format возвращает паспорт в виде "1234 567890".
+func Format(serie, number int) string {
+	return fmt.Sprintf("%04d %06d", serie, number)
+}
+
+// Mask возвращает паспорт, в котором видны только первые и последние две цифры: "12** ****90".
+func Mask(serie, number int) string {
+	runes := []rune(Format(serie, number))
+	digits := 0
+	for _, r := range runes {
+		if r != ' ' {
+			digits++
+		}
+	}
+	seen := 0
+	for i, r := range runes {
+		if r == ' ' {
+			continue
+		}
+		if seen >= 2 && seen < digits-2 {
+			runes[i] = '*'
+		}
+		seen++
+	}
+	return string(runes)
+}
+
+// Encrypt шифрует паспорт. Результат содержит случайный nonce и шифртекст.
+func (c *Cipher) Encrypt(serie, number int) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("ошибка генерации nonce: %v", err)
+	}
+	return c.aead.Seal(nonce, nonce, []byte(Format(serie, number)), nil), nil
+}
+
+// Decrypt расшифровывает паспорт, зашифрованный Encrypt.
+func (c *Cipher) Decrypt(data []byte) (int, int, error) {
+	size := c.aead.NonceSize()
+	if len(data) < size {
+		return 0, 0, errors.New("зашифрованный паспорт поврежден")
+	}
+	plain, err := c.aead.Open(nil, data[:size], data[size:], nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("ошибка расшифровки паспорта: %v", err)
+	}
+	var serie, number int
+	if _, err := fmt.Sscanf(string(plain), "%d %d", &serie, &number); err != nil {
+		return 0, 0, fmt.Errorf("неверный формат расшифрованного паспорта: %v", err)
+	}
+	return serie, number, nil
+}
+
+// Index слепой индекс паспорта целиком для поиска и проверки уникальности.
+func (c *Cipher) Index(serie, number int) []byte {
+	return c.hash("passport:" + Format(serie, number))
+}
+
+// SerieIndex слепой индекс серии паспорта.
+func (c *Cipher) SerieIndex(serie int) []byte {
+	return c.hash(fmt.Sprintf("serie:%04d", serie))
+}
+
+// NumberIndex слепой индекс номера паспорта.
+func (c *Cipher) NumberIndex(number int) []byte {
+	return c.hash(fmt.Sprintf("number:%06d", number))
+}
+
+func (c *Cipher) hash(value string) []byte {
+	mac := hmac.New(sha256.New, c.indexKey)
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,19 @@
+package passport
+
+import (
+	"log/slog"
+	"strings"
+)
+
+// Redacted значение, которым в журнале заменяются паспортные данные.
+const Redacted = "[REDACTED]"
+
+// ReplaceAttr предназначена для slog.HandlerOptions.ReplaceAttr: заменяет значения атрибутов,
+// в имени которых есть "passport" (без учета регистра), на Redacted. Структуры с паспортными
+// данными скрывают их сами через slog.LogValuer.
+func ReplaceAttr(groups []string, a slog.Attr) slog.Attr {
+	if strings.Contains(strings.ToLower(a.Key), "passport") && a.Value.Kind() != slog.KindGroup {
+		return slog.String(a.Key, Redacted)
+	}
+	return a
+}
@@ -0,0 +1,72 @@
+package rounding
+
+import (
+	"fmt"
+	"time"
+
+	"main.go/cmd/internal/config"
+)
+
+// Режимы округления длительности сессий в отчетах.
+const (
+	ModeExact   = "exact"   // без округления, с точностью до секунды
+	ModeNearest = "nearest" // до ближайшего кратного шагу
+	ModeUp      = "up"      // всегда вверх до кратного шагу
+)
+
+// Policy политика округления длительности сессий. Округляется длительность каждой сессии
+// отдельно, а итоги считаются как сумма округленных длительностей, поэтому сводка,
+// отчеты, выгрузки и счета дают одинаковый результат.
+type Policy struct {
+	Mode string
+	Step time.Duration
+}
+
+// New проверяет настройки округления и возвращает политику.
+func New(cfg config.RoundingConfig) (Policy, error) {
+	p := Policy{Mode: cfg.Mode, Step: cfg.Step}
+	switch p.Mode {
+	case "", ModeExact:
+		p.Mode = ModeExact
+	case ModeNearest, ModeUp:
+		if p.Step < time.Second || p.Step%time.Second != 0 {
+			return p, fmt.Errorf("шаг округления должен быть положительным и кратным секунде: %s", p.Step)
+		}
+	default:
+		return p, fmt.Errorf("неизвестный режим округления %q", p.Mode)
+	}
+	return p, nil
+}
+
+// stepSeconds возвращает шаг округления в секундах.
+func (p Policy) stepSeconds() int64 {
+	return int64(p.Step / time.Second)
+}
+
+// Apply округляет длительность в секундах согласно политике.
+func (p Policy) Apply(seconds int64) int64 {
+	if p.Mode == ModeExact || seconds <= 0 {
+		return seconds
+	}
+	step := p.stepSeconds()
+	switch p.Mode {
+	case ModeNearest:
+		return (seconds + step/2) / step * step
+	case ModeUp:
+		return (seconds + step - 1) / step * step
+	}
+	return seconds
+}
+
+// SQL возвращает SQL-выражение, округляющее длительность в секундах expr так же, как Apply.
+// Выражение предназначено для агрегатов вида SUM(...) и содержит только константы из настроек.
+func (p Policy) SQL(expr string) string {
+	step := p.stepSeconds()
+	switch p.Mode {
+	case ModeNearest:
+		return fmt.Sprintf("((%s + %d) / %d * %d)", expr, step/2, step, step)
+	case ModeUp:
+		return fmt.Sprintf("((%s + %d) / %d * %d)", expr, step-1, step, step)
+	}
+	return expr
+}
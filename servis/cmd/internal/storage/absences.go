@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	model "main.go/tracker_model"
+)
+
+// AbsenceColumns перечень колонок absences в порядке, ожидаемом ScanAbsence.
+const AbsenceColumns = "id, user_id, type, start_date, end_date, half_day, status, comment, manager_id, created_at, decided_at"
+
+// ScanAbsence сканирует строку с колонками AbsenceColumns в модель отсутствия и вычисляет количество дней.
+func ScanAbsence(row RowScanner) (model.Absence, error) {
+	var absence model.Absence
+	var managerID sql.NullInt64
+	var decidedAt sql.NullTime
+	err := row.Scan(&absence.ID, &absence.UserID, &absence.Type, &absence.StartDate, &absence.EndDate, &absence.HalfDay,
+		&absence.Status, &absence.Comment, &managerID, &absence.CreatedAt, &decidedAt)
+	if err != nil {
+		return absence, err
+	}
+	absence.ManagerID = int(managerID.Int64)
+	absence.DecidedAt = decidedAt.Time
+	absence.Days = AbsenceDays(absence, absence.StartDate, absence.EndDate)
+	return absence, nil
+}
+
+// AbsenceDays возвращает количество дней отсутствия absence внутри диапазона дат [from, to] включительно.
+// Отсутствие на половину дня дает 0.5.
+func AbsenceDays(absence model.Absence, from, to time.Time) float64 {
+	start, end := absence.StartDate, absence.EndDate
+	if from.After(start) {
+		start = from
+	}
+	if to.Before(end) {
+		end = to
+	}
+	if end.Before(start) {
+		return 0
+	}
+	if absence.HalfDay {
+		return 0.5
+	}
+	return float64(int(end.Sub(start).Hours()/24) + 1)
+}
+
+// ApprovedAbsences возвращает согласованные отсутствия пользователя, пересекающиеся с диапазоном
+// дат [from, to] включительно. Даты передаются в формате YYYY-MM-DD, чтобы не зависеть от часовых поясов.
+func ApprovedAbsences(q Querier, userID int, from, to string) ([]model.Absence, error) {
+	rows, err := q.Query(`
+		SELECT `+AbsenceColumns+`
+		FROM absences
+		WHERE user_id = $1 AND status = $2 AND start_date <= $4::date AND end_date >= $3::date
+		ORDER BY start_date, id
+	`, userID, model.StatusApproved, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении отсутствий: %v", err)
+	}
+	defer rows.Close()
+
+	absences := []model.Absence{}
+	for rows.Next() {
+		absence, err := ScanAbsence(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка сканирования отсутствия: %v", err)
+		}
+		absences = append(absences, absence)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по отсутствиям: %v", err)
+	}
+	return absences, nil
+}
+
+// FullDayAbsence возвращает согласованное отсутствие пользователя на целый день date (YYYY-MM-DD)
+// и true, если такое отсутствие есть.
+func FullDayAbsence(q Querier, userID int, date string) (model.Absence, bool, error) {
+	absence, err := ScanAbsence(q.QueryRow(`
+		SELECT `+AbsenceColumns+`
+		FROM absences
+		WHERE user_id = $1 AND status = $2 AND NOT half_day AND $3::date BETWEEN start_date AND end_date
+		ORDER BY id
+		LIMIT 1
+	`, userID, model.StatusApproved, date))
+	if errors.Is(err, sql.ErrNoRows) {
+		return absence, false, nil
+	}
+	if err != nil {
+		return absence, false, fmt.Errorf("ошибка при проверке отсутствия: %v", err)
+	}
+	return absence, true, nil
+}
@@ -5,7 +5,10 @@ import (
 	"log"
 	"sort"
 	"sync"
+	"sync/atomic"
 
+	"main.go/cmd/internal/metrics"
+	"main.go/cmd/internal/storage"
 	model "main.go/tracker_model"
 )
 
@@ -17,6 +20,27 @@ var (
 	TasksCacheMutex sync.RWMutex
 )
 
+// warmed отмечает, что кэш пользователей загружен из базы данных.
+var warmed atomic.Bool
+
+// Warmed сообщает, завершена ли загрузка кэша CacheAllUsersFromDB.
+func Warmed() bool {
+	return warmed.Load()
+}
+
+// lookups счетчик чтений пользователей из кэша: result — hit или miss.
+var lookups = metrics.NewCounterVec("time_tracker_user_cache_lookups_total",
+	"Количество чтений пользователей из кэша по результату (hit, miss).", "result")
+
+// recordLookup учитывает чтение пользователя из кэша.
+func recordLookup(exists bool) {
+	if exists {
+		lookups.Inc("hit")
+	} else {
+		lookups.Inc("miss")
+	}
+}
+
 func InitCache() {
 	UserCache = make(map[int]model.Users)
 	TasksCache = make(map[int]model.Task)
@@ -39,7 +63,8 @@ func CacheTask(task model.Task) {
 // CacheAllUsersFromDB загружает всех пользователей и их задачи из базы данных и кэширует их.
 func CacheAllUsersFromDB(db *sql.DB) {
 	// Выполнение SQL-запроса для получения всех пользователей.
-	userRows, err := db.Query("SELECT id, passport_serie, passport_number, surname, name, patronymic, address FROM users")
+	// Паспортные данные в кэше не хранятся.
+	userRows, err := db.Query("SELECT id, surname, name, COALESCE(patronymic, ''), address, role, timezone FROM users")
 	if err != nil {
 		log.Fatalf("Ошибка выполнения запроса для получения пользователей: %v", err)
 	}
@@ -51,21 +76,20 @@ func CacheAllUsersFromDB(db *sql.DB) {
 	// Обработка результатов запроса.
 	for userRows.Next() {
 		var user model.Users
-		err := userRows.Scan(&user.UserID, &user.PassportSerie, &user.PassportNumber, &user.Surname, &user.Name, &user.Patronymic, &user.Address)
+		err := userRows.Scan(&user.UserID, &user.Surname, &user.Name, &user.Patronymic, &user.Address, &user.Role, &user.Timezone)
 		if err != nil {
 			log.Fatalf("Ошибка сканирования строки результата: %v", err)
 		}
 
 		// Получение задач для текущего пользователя.
-		taskRows, err := db.Query("SELECT user_id, id_task, task_name, start_time, end_time, total_minutes FROM users_tasks WHERE user_id = $1", user.UserID)
+		taskRows, err := db.Query("SELECT "+storage.UserTaskColumns+" FROM users_tasks WHERE user_id = $1", user.UserID)
 		if err != nil {
 			log.Fatalf("Ошибка выполнения запроса для получения задач пользователя: %v", err)
 		}
 		defer taskRows.Close()
 
 		for taskRows.Next() {
-			var task model.UserTask
-			err := taskRows.Scan(&task.UserID, &task.IDTask, &task.TaskName, &task.StartTime, &task.EndTime, &task.TotalMinutes)
+			task, err := storage.ScanUserTask(taskRows)
 			if err != nil {
 				log.Fatalf("Ошибка сканирования строки задачи: %v", err)
 			}
@@ -84,6 +108,7 @@ func CacheAllUsersFromDB(db *sql.DB) {
 	if err := userRows.Err(); err != nil {
 		log.Fatalf("Ошибка итерации по строкам результата пользователей: %v", err)
 	}
+	warmed.Store(true)
 }
 
 // GetUserTaskFromCache получает задачу из кэша по его идентификатору.
@@ -91,6 +116,7 @@ func GetUserTaskFromCache(userID int) ([]model.UserTask, bool) {
 	UserCacheMutex.RLock()
 	defer UserCacheMutex.RUnlock()
 	user, exists := UserCache[userID]
+	recordLookup(exists)
 	if !exists {
 		return nil, false
 	}
@@ -98,10 +124,61 @@ func GetUserTaskFromCache(userID int) ([]model.UserTask, bool) {
 	// Копируем задачи пользователя для сортировки
 	userTasks := append([]model.UserTask{}, user.UserTask...)
 
-	// Сортируем задачи по трудозатратам (TotalMinutes) от большей к меньшей
+	// Сортируем задачи по трудозатратам (TotalSeconds) от большей к меньшей
 	sort.Slice(userTasks, func(i, j int) bool {
-		return userTasks[i].TotalMinutes > userTasks[j].TotalMinutes
+		return userTasks[i].TotalSeconds > userTasks[j].TotalSeconds
 	})
 
 	return userTasks, true
 }
+
+// GetRunningSession возвращает пользователя из кэша и его текущую незавершенную сессию.
+// Если незавершенных сессий несколько, возвращается начатая последней; nil — пользователь не работает.
+func GetRunningSession(userID int) (model.Users, *model.UserTask, bool) {
+	UserCacheMutex.RLock()
+	defer UserCacheMutex.RUnlock()
+	user, exists := UserCache[userID]
+	recordLookup(exists)
+	if !exists {
+		return user, nil, false
+	}
+	return user, latestRunning(user.UserTask), true
+}
+
+// GetAllRunningSessions возвращает из кэша всех пользователей с незавершенной сессией
+// и их текущие сессии, отсортированные по идентификатору пользователя.
+func GetAllRunningSessions() ([]model.Users, []model.UserTask) {
+	UserCacheMutex.RLock()
+	defer UserCacheMutex.RUnlock()
+
+	var users []model.Users
+	for _, user := range UserCache {
+		if latestRunning(user.UserTask) != nil {
+			users = append(users, user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].UserID < users[j].UserID
+	})
+
+	sessions := make([]model.UserTask, 0, len(users))
+	for _, user := range users {
+		sessions = append(sessions, *latestRunning(user.UserTask))
+	}
+	return users, sessions
+}
+
+// latestRunning возвращает копию последней начатой незавершенной сессии или nil.
+func latestRunning(tasks []model.UserTask) *model.UserTask {
+	var running *model.UserTask
+	for i := range tasks {
+		if !tasks[i].EndTime.IsZero() {
+			continue
+		}
+		if running == nil || tasks[i].StartTime.After(running.StartTime) {
+			task := tasks[i]
+			running = &task
+		}
+	}
+	return running
+}
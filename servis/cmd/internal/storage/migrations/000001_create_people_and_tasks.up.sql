@@ -24,8 +24,11 @@ CREATE TABLE IF NOT EXISTS users_tasks (
 );
 
 INSERT INTO tasks (id_task, task_name)
-VALUES (1, 'работаю над таской 1');
+VALUES (1, 'работаю над таской 1')
+ON CONFLICT (id_task) DO NOTHING;
 INSERT INTO tasks (id_task, task_name)
-VALUES (2, 'работаю над таской 2');
+VALUES (2, 'работаю над таской 2')
+ON CONFLICT (id_task) DO NOTHING;
 INSERT INTO tasks (id_task, task_name)
-VALUES (3, 'работаю над таской 3');
\ No newline at end of file
+VALUES (3, 'работаю над таской 3')
+ON CONFLICT (id_task) DO NOTHING;
\ No newline at end of file
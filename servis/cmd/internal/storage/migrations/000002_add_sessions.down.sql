@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_users_tasks_user_start;
+ALTER TABLE users_tasks DROP COLUMN IF EXISTS id;
+ALTER TABLE users_tasks ADD CONSTRAINT unique_user_task UNIQUE (user_id, id_task);
\ No newline at end of file
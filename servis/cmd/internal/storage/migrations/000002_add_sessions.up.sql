@@ -0,0 +1,7 @@
+ALTER TABLE users_tasks ADD COLUMN IF NOT EXISTS id SERIAL PRIMARY KEY;
+
+ALTER TABLE users_tasks DROP CONSTRAINT IF EXISTS unique_user_task;
+
+UPDATE users_tasks SET end_time = NULL WHERE end_time = '0001-01-01 00:00:00';
+
+CREATE INDEX IF NOT EXISTS idx_users_tasks_user_start ON users_tasks (user_id, start_time);
\ No newline at end of file
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS timesheets;
+ALTER TABLE users_tasks DROP COLUMN IF EXISTS status;
+ALTER TABLE users DROP COLUMN IF EXISTS role;
\ No newline at end of file
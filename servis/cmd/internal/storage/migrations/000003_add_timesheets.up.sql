@@ -0,0 +1,17 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'employee';
+
+ALTER TABLE users_tasks ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'draft';
+
+CREATE TABLE IF NOT EXISTS timesheets (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    week_start DATE NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'submitted',
+    comment TEXT NOT NULL DEFAULT '',
+    manager_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    submitted_at TIMESTAMP NOT NULL,
+    decided_at TIMESTAMP,
+    CONSTRAINT unique_user_week UNIQUE (user_id, week_start)
+);
+
+CREATE INDEX IF NOT EXISTS idx_timesheets_status ON timesheets (status);
\ No newline at end of file
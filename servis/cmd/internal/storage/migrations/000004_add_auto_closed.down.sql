@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_users_tasks_open;
+ALTER TABLE users_tasks DROP COLUMN IF EXISTS auto_closed;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE users_tasks ADD COLUMN IF NOT EXISTS auto_closed BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_users_tasks_open ON users_tasks (start_time) WHERE end_time IS NULL;
\ No newline at end of file
@@ -0,0 +1,16 @@
+ALTER TABLE outbox
+    ALTER COLUMN created_at TYPE TIMESTAMP,
+    ALTER COLUMN published_at TYPE TIMESTAMP;
+
+ALTER TABLE webhook_deliveries ALTER COLUMN created_at TYPE TIMESTAMP;
+ALTER TABLE webhooks ALTER COLUMN created_at TYPE TIMESTAMP;
+
+ALTER TABLE timesheets
+    ALTER COLUMN submitted_at TYPE TIMESTAMP,
+    ALTER COLUMN decided_at TYPE TIMESTAMP;
+
+ALTER TABLE users_tasks
+    ALTER COLUMN start_time TYPE TIMESTAMP,
+    ALTER COLUMN end_time TYPE TIMESTAMP;
+
+ALTER TABLE users DROP COLUMN IF EXISTS timezone;
\ No newline at end of file
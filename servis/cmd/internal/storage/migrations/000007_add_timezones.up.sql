@@ -0,0 +1,19 @@
+-- Время сессий, табелей, подписок и событий хранится с часовым поясом.
+-- Существующие значения без пояса интерпретируются в часовом поясе сессии базы данных (TimeZone),
+-- поэтому перед миграцией он должен совпадать с часовым поясом, в котором работал сервер приложения.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+
+ALTER TABLE users_tasks
+    ALTER COLUMN start_time TYPE TIMESTAMPTZ,
+    ALTER COLUMN end_time TYPE TIMESTAMPTZ;
+
+ALTER TABLE timesheets
+    ALTER COLUMN submitted_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN decided_at TYPE TIMESTAMPTZ;
+
+ALTER TABLE webhooks ALTER COLUMN created_at TYPE TIMESTAMPTZ;
+ALTER TABLE webhook_deliveries ALTER COLUMN created_at TYPE TIMESTAMPTZ;
+
+ALTER TABLE outbox
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN published_at TYPE TIMESTAMPTZ;
\ No newline at end of file
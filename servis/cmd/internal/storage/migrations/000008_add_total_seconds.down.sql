@@ -0,0 +1,5 @@
+ALTER TABLE users_tasks ADD COLUMN IF NOT EXISTS total_minutes INTEGER;
+
+UPDATE users_tasks SET total_minutes = total_seconds / 60;
+
+ALTER TABLE users_tasks DROP COLUMN IF EXISTS total_seconds;
\ No newline at end of file
@@ -0,0 +1,7 @@
+ALTER TABLE users_tasks ADD COLUMN IF NOT EXISTS total_seconds BIGINT NOT NULL DEFAULT 0;
+
+UPDATE users_tasks
+SET total_seconds = FLOOR(EXTRACT(EPOCH FROM end_time - start_time))::bigint
+WHERE end_time IS NOT NULL AND total_seconds = 0;
+
+ALTER TABLE users_tasks DROP COLUMN IF EXISTS total_minutes;
\ No newline at end of file
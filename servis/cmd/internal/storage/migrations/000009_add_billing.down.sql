@@ -0,0 +1,7 @@
+ALTER TABLE users_tasks DROP COLUMN IF EXISTS invoice_id;
+ALTER TABLE users_tasks DROP COLUMN IF EXISTS billable;
+DROP TABLE IF EXISTS invoice_lines;
+DROP TABLE IF EXISTS invoices;
+DROP TABLE IF EXISTS rates;
+ALTER TABLE tasks DROP COLUMN IF EXISTS project_id;
+DROP TABLE IF EXISTS projects;
\ No newline at end of file
@@ -0,0 +1,47 @@
+CREATE TABLE IF NOT EXISTS projects (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL
+);
+
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS project_id INTEGER REFERENCES projects(id) ON DELETE SET NULL;
+
+CREATE TABLE IF NOT EXISTS rates (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    id_task INTEGER REFERENCES tasks(id_task) ON DELETE CASCADE,
+    project_id INTEGER REFERENCES projects(id) ON DELETE CASCADE,
+    hourly_rate NUMERIC(12, 2) NOT NULL CHECK (hourly_rate >= 0),
+    currency CHAR(3) NOT NULL,
+    effective_from DATE NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_rates_effective_from ON rates (effective_from);
+
+CREATE TABLE IF NOT EXISTS invoices (
+    id SERIAL PRIMARY KEY,
+    period_start DATE NOT NULL,
+    period_end DATE NOT NULL,
+    currency CHAR(3) NOT NULL,
+    total NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS invoice_lines (
+    id SERIAL PRIMARY KEY,
+    invoice_id INTEGER NOT NULL REFERENCES invoices(id) ON DELETE CASCADE,
+    session_id INTEGER REFERENCES users_tasks(id) ON DELETE SET NULL,
+    user_id INTEGER NOT NULL,
+    id_task INTEGER NOT NULL,
+    task_name VARCHAR(100) NOT NULL,
+    start_time TIMESTAMPTZ NOT NULL,
+    end_time TIMESTAMPTZ NOT NULL,
+    billed_seconds BIGINT NOT NULL,
+    hourly_rate NUMERIC(12, 2) NOT NULL,
+    amount NUMERIC(14, 2) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_invoice_lines_invoice ON invoice_lines (invoice_id);
+
+ALTER TABLE users_tasks ADD COLUMN IF NOT EXISTS billable BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE users_tasks ADD COLUMN IF NOT EXISTS invoice_id INTEGER REFERENCES invoices(id) ON DELETE SET NULL;
\ No newline at end of file
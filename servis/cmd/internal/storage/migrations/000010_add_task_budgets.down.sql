@@ -0,0 +1,2 @@
+ALTER TABLE tasks DROP COLUMN IF EXISTS budget_alert_percent;
+ALTER TABLE tasks DROP COLUMN IF EXISTS estimated_seconds;
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS estimated_seconds BIGINT CHECK (estimated_seconds > 0);
+
+-- Наибольший порог бюджета в процентах, о котором уже отправлено оповещение
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS budget_alert_percent INTEGER NOT NULL DEFAULT 0;
\ No newline at end of file
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS holidays;
+DROP TABLE IF EXISTS work_schedules;
\ No newline at end of file
@@ -0,0 +1,12 @@
+-- weekday нумеруется как time.Weekday: 0 — воскресенье, 6 — суббота
+CREATE TABLE IF NOT EXISTS work_schedules (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    weekday SMALLINT NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+    seconds BIGINT NOT NULL CHECK (seconds BETWEEN 0 AND 86400),
+    PRIMARY KEY (user_id, weekday)
+);
+
+CREATE TABLE IF NOT EXISTS holidays (
+    date DATE PRIMARY KEY,
+    name VARCHAR(100) NOT NULL DEFAULT ''
+);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS absences;
\ No newline at end of file
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS absences (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type VARCHAR(20) NOT NULL CHECK (type IN ('vacation', 'sick', 'business_trip', 'other')),
+    start_date DATE NOT NULL,
+    end_date DATE NOT NULL,
+    -- Половина дня допускается только для отсутствия в один день
+    half_day BOOLEAN NOT NULL DEFAULT FALSE,
+    status VARCHAR(20) NOT NULL DEFAULT 'submitted',
+    comment TEXT NOT NULL DEFAULT '',
+    manager_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    decided_at TIMESTAMPTZ,
+    CHECK (end_date >= start_date),
+    CHECK (NOT half_day OR start_date = end_date)
+);
+
+CREATE INDEX IF NOT EXISTS idx_absences_user_dates ON absences (user_id, start_date, end_date);
\ No newline at end of file
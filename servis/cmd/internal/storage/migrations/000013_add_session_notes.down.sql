@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_users_tasks_tags;
+ALTER TABLE users_tasks DROP COLUMN IF EXISTS tags;
+ALTER TABLE users_tasks DROP COLUMN IF EXISTS description;
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE users_tasks ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '';
+ALTER TABLE users_tasks ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS idx_users_tasks_tags ON users_tasks USING GIN (tags);
\ No newline at end of file
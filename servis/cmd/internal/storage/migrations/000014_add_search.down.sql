@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_users_tasks_search;
+DROP INDEX IF EXISTS idx_tasks_search;
+DROP INDEX IF EXISTS idx_users_search;
+ALTER TABLE users_tasks DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE tasks DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE users DROP COLUMN IF EXISTS search_vector;
\ No newline at end of file
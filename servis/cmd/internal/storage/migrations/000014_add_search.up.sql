@@ -0,0 +1,19 @@
+-- Векторы полнотекстового поиска в русской и английской конфигурациях
+ALTER TABLE users ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('russian', surname || ' ' || name || ' ' || COALESCE(patronymic, '')), 'A') ||
+    setweight(to_tsvector('english', surname || ' ' || name || ' ' || COALESCE(patronymic, '')), 'A') ||
+    setweight(to_tsvector('russian', address), 'B') ||
+    setweight(to_tsvector('english', address), 'B')
+) STORED;
+
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+    to_tsvector('russian', task_name) || to_tsvector('english', task_name)
+) STORED;
+
+ALTER TABLE users_tasks ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+    to_tsvector('russian', description) || to_tsvector('english', description)
+) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_users_search ON users USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_tasks_search ON tasks USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_users_tasks_search ON users_tasks USING GIN (search_vector);
\ No newline at end of file
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_users_patronymic_id;
+DROP INDEX IF EXISTS idx_users_name_id;
+DROP INDEX IF EXISTS idx_users_surname_id;
\ No newline at end of file
@@ -0,0 +1,4 @@
+-- Индексы для постраничной выдачи списка пользователей по ключу (сортировка, id).
+CREATE INDEX IF NOT EXISTS idx_users_surname_id ON users (surname, id);
+CREATE INDEX IF NOT EXISTS idx_users_name_id ON users (name, id);
+CREATE INDEX IF NOT EXISTS idx_users_patronymic_id ON users ((COALESCE(patronymic, '')), id);
\ No newline at end of file
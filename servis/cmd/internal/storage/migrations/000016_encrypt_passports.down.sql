@@ -0,0 +1,9 @@
+-- Зашифрованные данные нельзя расшифровать средствами SQL: перед откатом паспорта должны быть
+-- восстановлены в passport_serie и passport_number приложением, иначе они будут потеряны.
+DROP INDEX IF EXISTS idx_users_passport_number_hash;
+DROP INDEX IF EXISTS idx_users_passport_serie_hash;
+DROP INDEX IF EXISTS idx_users_passport_hash;
+ALTER TABLE users DROP COLUMN IF EXISTS passport_number_hash;
+ALTER TABLE users DROP COLUMN IF EXISTS passport_serie_hash;
+ALTER TABLE users DROP COLUMN IF EXISTS passport_hash;
+ALTER TABLE users DROP COLUMN IF EXISTS passport_encrypted;
\ No newline at end of file
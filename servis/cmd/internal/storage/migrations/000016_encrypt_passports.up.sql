@@ -0,0 +1,13 @@
+-- Паспортные данные хранятся зашифрованными на стороне приложения (passport_encrypted: nonce и шифртекст AES-GCM).
+-- Для поиска и проверки уникальности используются слепые индексы — HMAC от серии, номера и паспорта целиком.
+-- Открытые passport_serie и passport_number заполняются только у еще не зашифрованных строк: при старте
+-- приложение шифрует их и очищает.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS passport_encrypted BYTEA;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS passport_hash BYTEA;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS passport_serie_hash BYTEA;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS passport_number_hash BYTEA;
+ALTER TABLE users ALTER COLUMN passport_serie DROP NOT NULL;
+ALTER TABLE users ALTER COLUMN passport_number DROP NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_passport_hash ON users (passport_hash);
+CREATE INDEX IF NOT EXISTS idx_users_passport_serie_hash ON users (passport_serie_hash);
+CREATE INDEX IF NOT EXISTS idx_users_passport_number_hash ON users (passport_number_hash);
\ No newline at end of file
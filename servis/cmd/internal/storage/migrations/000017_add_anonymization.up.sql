@@ -0,0 +1,3 @@
+-- Отметка об обезличивании пользователя по его запросу: личные поля очищаются,
+-- а сессии остаются для агрегированных отчетов.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS anonymized_at TIMESTAMPTZ;
\ No newline at end of file
@@ -0,0 +1,33 @@
+-- Журнал аудита всех изменений: кто (actor_id, NULL — система), что (action, entity, entity_id),
+-- чьи данные (user_id), изменения по полям (diff: {"поле": {"before": ..., "after": ...}}) и запрос (request_id).
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    actor_id INTEGER,
+    action VARCHAR(50) NOT NULL,
+    entity VARCHAR(30) NOT NULL,
+    entity_id INTEGER NOT NULL,
+    user_id INTEGER,
+    diff JSONB NOT NULL DEFAULT '{}',
+    request_id VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_entity ON audit_log (entity, entity_id, id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log (actor_id, id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_user ON audit_log (user_id, id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_created ON audit_log (created_at);
+
+-- Журнал только дополняется. Единственное допустимое изменение — обезличивание личных полей
+-- в diff по запросу пользователя, которое выполняется с SET LOCAL audit.redact = 'on'.
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND current_setting('audit.redact', true) = 'on' THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
\ No newline at end of file
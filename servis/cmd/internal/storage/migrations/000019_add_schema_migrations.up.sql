@@ -0,0 +1,7 @@
+-- Примененные миграции: RunMigrations создает таблицу до первого файла, отмечает каждый файл
+-- в одной транзакции с его выполнением и пропускает отмеченные; проверка готовности сравнивает
+-- этот список с файлами, известными сервису.
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version VARCHAR(100) PRIMARY KEY,
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
\ No newline at end of file
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_webhook_deliveries_pending;
+DROP INDEX IF EXISTS idx_webhook_deliveries_event;
+ALTER TABLE webhook_deliveries ADD COLUMN IF NOT EXISTS payload TEXT NOT NULL DEFAULT '';
+ALTER TABLE webhook_deliveries ALTER COLUMN success DROP DEFAULT;
+ALTER TABLE webhook_deliveries ALTER COLUMN attempt DROP DEFAULT;
+ALTER TABLE webhook_deliveries DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE webhook_deliveries DROP COLUMN IF EXISTS next_attempt_at;
+ALTER TABLE webhook_deliveries DROP COLUMN IF EXISTS status;
\ No newline at end of file
@@ -0,0 +1,25 @@
+-- Журнал доставки становится очередью: одна строка на подписку и событие с состоянием доставки
+-- (pending, delivered, failed) и временем следующей попытки. Попытки выполняет отдельный фоновый процесс.
+ALTER TABLE webhook_deliveries ADD COLUMN IF NOT EXISTS status VARCHAR(20);
+ALTER TABLE webhook_deliveries ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMPTZ;
+ALTER TABLE webhook_deliveries ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+-- Из прежнего журнала попыток остается последняя попытка по каждой паре подписки и события
+DELETE FROM webhook_deliveries d
+USING webhook_deliveries newer
+WHERE newer.webhook_id = d.webhook_id AND newer.event_id = d.event_id AND newer.id > d.id;
+
+UPDATE webhook_deliveries
+SET status = CASE WHEN success THEN 'delivered' ELSE 'failed' END, updated_at = created_at
+WHERE status IS NULL;
+
+ALTER TABLE webhook_deliveries ALTER COLUMN status SET DEFAULT 'pending';
+ALTER TABLE webhook_deliveries ALTER COLUMN status SET NOT NULL;
+ALTER TABLE webhook_deliveries ALTER COLUMN attempt SET DEFAULT 0;
+ALTER TABLE webhook_deliveries ALTER COLUMN success SET DEFAULT FALSE;
+
+-- Тело события берется из outbox при каждой попытке, копия с личными данными в журнале не хранится
+ALTER TABLE webhook_deliveries DROP COLUMN IF EXISTS payload;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_webhook_deliveries_event ON webhook_deliveries (webhook_id, event_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_pending ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
\ No newline at end of file
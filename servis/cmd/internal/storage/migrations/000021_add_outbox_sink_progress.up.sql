@@ -0,0 +1,4 @@
+-- Получатели, которым событие уже передано: при повторной попытке событие передается только остальным.
+-- locked_until закрепляет событие за экземпляром сервиса на время передачи, которая выполняется вне транзакции.
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS published_sinks TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS locked_until TIMESTAMPTZ;
\ No newline at end of file
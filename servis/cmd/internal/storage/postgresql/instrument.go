@@ -0,0 +1,115 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"time"
+
+	"main.go/cmd/internal/metrics"
+)
+
+var (
+	queryDuration = metrics.NewHistogramVec("time_tracker_db_query_duration_seconds",
+		"Длительность выполнения запросов к базе данных в секундах (без чтения строк результата).",
+		metrics.DefaultBuckets, "operation")
+	queryErrors = metrics.NewCounterVec("time_tracker_db_query_errors_total",
+		"Количество запросов к базе данных, завершившихся ошибкой.", "operation")
+)
+
+// instrumentedConnector оборачивает соединения драйвера для учета длительности и ошибок запросов.
+type instrumentedConnector struct {
+	driver.Connector
+}
+
+func (c instrumentedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedConn{Conn: conn}, nil
+}
+
+// instrumentedConn соединение, измеряющее запросы, выполняемые через Exec и Query.
+// Остальные возможности передаются соединению драйвера.
+type instrumentedConn struct {
+	driver.Conn
+}
+
+func (c *instrumentedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	rows, err := queryer.QueryContext(ctx, query, args)
+	observe(query, start, err)
+	return rows, err
+}
+
+func (c *instrumentedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	result, err := execer.ExecContext(ctx, query, args)
+	observe(query, start, err)
+	return result, err
+}
+
+func (c *instrumentedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c *instrumentedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *instrumentedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *instrumentedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *instrumentedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+// observe учитывает выполненный запрос. driver.ErrSkip не ошибка: database/sql повторит запрос иначе.
+func observe(query string, start time.Time, err error) {
+	operation := queryOperation(query)
+	queryDuration.Observe(time.Since(start).Seconds(), operation)
+	if err != nil && !errors.Is(err, driver.ErrSkip) {
+		queryErrors.Inc(operation)
+	}
+}
+
+// queryOperation возвращает вид запроса по первому слову: select, insert, update, delete или other.
+func queryOperation(query string) string {
+	word, _, _ := strings.Cut(strings.TrimSpace(query), " ")
+	word = strings.ToLower(strings.TrimSpace(word))
+	switch word {
+	case "select", "insert", "update", "delete":
+		return word
+	}
+	return "other"
+}
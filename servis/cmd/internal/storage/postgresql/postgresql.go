@@ -1,25 +1,34 @@
 package postgresql
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"main.go/cmd/internal/config"
 	"main.go/cmd/internal/storage"
 )
 
 // Connect устанавливает соединение с базой данных и возвращает объект DB.
+// sql.Open не подключается к базе, поэтому соединение проверяется PingContext;
+// при неудаче попытки повторяются с удваивающейся задержкой (см. ping).
 func Connect(cfg config.DatabaseConfig) *sql.DB {
 	// Формируем строку подключения к базе данных
 	dbInfo := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=%s",
 		cfg.Host, cfg.User, cfg.Password, cfg.DBName, cfg.Port, cfg.SSLMode)
-	// Открываем соединение с базой данных
-	db, err := sql.Open("postgres", dbInfo)
+	// Открываем соединение с базой данных; запросы учитываются в метриках
+	connector, err := pq.NewConnector(dbInfo)
 	if err != nil {
 		log.Fatalf("Ошибка подключения к базе данных: %v", err)
 	}
+	db := sql.OpenDB(instrumentedConnector{connector})
+
+	if err := ping(context.Background(), db, cfg); err != nil {
+		log.Fatalf("Ошибка подключения к базе данных: %v", err)
+	}
 
 	// Создаем необходимые таблицы, если они еще не существуют
 	if err := storage.RunMigrations(db); err != nil {
@@ -29,3 +38,30 @@ func Connect(cfg config.DatabaseConfig) *sql.DB {
 	log.Println("Подключение к базе данных успешно установлено")
 	return db
 }
+
+// ping проверяет соединение с базой данных до cfg.ConnectAttempts раз. Каждая попытка ограничена
+// cfg.ConnectTimeout, задержка между попытками начинается с cfg.ConnectBackoff и удваивается до cfg.MaxBackoff.
+func ping(ctx context.Context, db *sql.DB, cfg config.DatabaseConfig) error {
+	attempts := max(cfg.ConnectAttempts, 1)
+	backoff := cfg.ConnectBackoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, cfg.ConnectTimeout)
+		err = db.PingContext(pingCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if attempt >= attempts {
+			return fmt.Errorf("база данных недоступна после %d попыток: %w", attempts, err)
+		}
+
+		log.Printf("База данных недоступна (попытка %d из %d), повтор через %s: %v", attempt, attempts, backoff, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, cfg.MaxBackoff)
+	}
+}
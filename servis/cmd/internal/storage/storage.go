@@ -1,41 +1,201 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
+	"strings"
 
+	"github.com/lib/pq"
 	model "main.go/tracker_model"
 )
 
+// migrationsDir каталог с файлами миграций
+const migrationsDir = "C:/dev/projects/time_tracker/servis/cmd/internal/storage/migrations/"
+
+// UserTaskColumns перечень колонок users_tasks в порядке, ожидаемом ScanUserTask.
+const UserTaskColumns = "id, user_id, id_task, task_name, start_time, end_time, total_seconds, status, auto_closed, billable, invoice_id, description, tags"
+
+// RowScanner общий интерфейс для *sql.Row и *sql.Rows.
+type RowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// Querier общий интерфейс для *sql.DB и *sql.Tx.
+type Querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// IsUniqueViolation сообщает, что ошибка вызвана нарушением ограничения уникальности.
+func IsUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// likeEscaper экранирует спецсимволы шаблона ILIKE, чтобы значение искалось как подстрока.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// EscapeLike экранирует в s спецсимволы шаблона LIKE и ILIKE (%, _ и \), чтобы значение,
+// подставленное в шаблон, совпадало только с самим собой.
+func EscapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// migrations файлы миграций в порядке применения.
+var migrations = []string{
+	"000001_create_people_and_tasks.up.sql",
+	"000002_add_sessions.up.sql",
+	"000003_add_timesheets.up.sql",
+	"000004_add_auto_closed.up.sql",
+	"000005_add_webhooks.up.sql",
+	"000006_add_outbox.up.sql",
+	"000007_add_timezones.up.sql",
+	"000008_add_total_seconds.up.sql",
+	"000009_add_billing.up.sql",
+	"000010_add_task_budgets.up.sql",
+	"000011_add_work_calendar.up.sql",
+	"000012_add_absences.up.sql",
+	"000013_add_session_notes.up.sql",
+	"000014_add_search.up.sql",
+	"000015_add_users_keyset_indexes.up.sql",
+	"000016_encrypt_passports.up.sql",
+	"000017_add_anonymization.up.sql",
+	"000018_add_audit_log.up.sql",
+	"000019_add_schema_migrations.up.sql",
+	"000020_add_webhook_delivery_queue.up.sql",
+	"000021_add_outbox_sink_progress.up.sql",
+}
+
+// schemaMigrationsTable создает таблицу примененных миграций. Выполняется до первого файла,
+// чтобы уже примененные миграции можно было пропустить; 000019 создает ту же таблицу.
+const schemaMigrationsTable = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version VARCHAR(100) PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`
+
+// RunMigrations применяет миграции, еще не отмеченные в schema_migrations. Каждый файл выполняется
+// в одной транзакции с отметкой о нем, поэтому прерванная миграция не считается примененной,
+// а повторный запуск сервиса не выполняет уже примененные файлы.
 func RunMigrations(db *sql.DB) error {
-	// Путь к файлам миграции
-	files := []string{"C:/dev/projects/time_tracker/servis/cmd/internal/storage/migrations/000001_create_people_and_tasks.up.sql"}
+	if _, err := db.Exec(schemaMigrationsTable); err != nil {
+		return fmt.Errorf("ошибка создания таблицы schema_migrations: %v", err)
+	}
 
-	for _, file := range files {
-		// Проверяем существование файла
-		if _, err := os.Stat(file); os.IsNotExist(err) {
-			return fmt.Errorf("файл миграции %s не найден", file)
+	applied := map[string]bool{}
+	rows, err := db.Query(`SELECT version FROM schema_migrations`)
+	if err != nil {
+		return fmt.Errorf("ошибка получения списка примененных миграций: %v", err)
+	}
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			return fmt.Errorf("ошибка сканирования миграции: %v", err)
 		}
+		applied[version] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("ошибка итерации по примененным миграциям: %v", err)
+	}
 
-		// Читаем содержимое SQL-файла миграции
-		content, err := ioutil.ReadFile(file)
-		if err != nil {
-			return fmt.Errorf("ошибка чтения файла миграции %s: %v", file, err)
+	for _, name := range migrations {
+		if applied[name] {
+			continue
 		}
-
-		// Выполняем SQL-запрос из файла
-		if _, err := db.Exec(string(content)); err != nil {
-			return fmt.Errorf("ошибка выполнения миграции из файла %s: %v", file, err)
+		if err := applyMigration(db, name); err != nil {
+			return err
 		}
+		log.Printf("Выполнена миграция из файла: %s", migrationsDir+name)
+	}
+	return nil
+}
 
-		log.Printf("Выполнена миграция из файла: %s", file)
+// applyMigration выполняет файл миграции name и отмечает его примененным в одной транзакции.
+func applyMigration(db *sql.DB, name string) error {
+	file := migrationsDir + name
+	// Проверяем существование файла
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		return fmt.Errorf("файл миграции %s не найден", file)
+	}
+
+	// Читаем содержимое SQL-файла миграции
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("ошибка чтения файла миграции %s: %v", file, err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("ошибка при начале транзакции: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Выполняем SQL-запрос из файла
+	if _, err := tx.Exec(string(content)); err != nil {
+		return fmt.Errorf("ошибка выполнения миграции из файла %s: %v", file, err)
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES ($1)`, name); err != nil {
+		return fmt.Errorf("ошибка отметки миграции %s: %v", name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ошибка при фиксации транзакции: %v", err)
 	}
 	return nil
 }
 
+// PendingMigrations возвращает миграции, известные сервису, но не отмеченные в базе как примененные.
+func PendingMigrations(ctx context.Context, db *sql.DB) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT version FROM unnest($1::text[]) AS version
+		WHERE version NOT IN (SELECT version FROM schema_migrations)`, pq.Array(migrations))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения списка примененных миграций: %v", err)
+	}
+	defer rows.Close()
+
+	var pending []string
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			return nil, fmt.Errorf("ошибка сканирования миграции: %v", err)
+		}
+		pending = append(pending, version)
+	}
+	return pending, rows.Err()
+}
+
+// ScanUserTask сканирует строку с колонками UserTaskColumns в модель сессии.
+// NULL в end_time означает незавершенную сессию и оставляет EndTime нулевым.
+// TotalMinutes заполняется целыми минутами из TotalSeconds, Tags — пустым списком, если меток нет.
+func ScanUserTask(row RowScanner) (model.UserTask, error) {
+	var task model.UserTask
+	var endTime sql.NullTime
+	var invoiceID sql.NullInt64
+	err := row.Scan(&task.IDSession, &task.UserID, &task.IDTask, &task.TaskName, &task.StartTime, &endTime, &task.TotalSeconds, &task.Status, &task.AutoClosed,
+		&task.Billable, &invoiceID, &task.Description, pq.Array(&task.Tags))
+	if err != nil {
+		return task, err
+	}
+	if endTime.Valid {
+		task.EndTime = endTime.Time
+	}
+	task.TotalMinutes = int(task.TotalSeconds / 60)
+	task.InvoiceID = int(invoiceID.Int64)
+	if task.Tags == nil {
+		task.Tags = []string{}
+	}
+	return task, nil
+}
+
 // insertUser вставляет информацию о пользователе в базу данных.
 func InsertUser(user model.Users, db *sql.DB) error {
 	query := `
@@ -48,9 +208,9 @@ func InsertUser(user model.Users, db *sql.DB) error {
 // insertUserTask вставляет информацию о задаче пользователя в базу данных.
 func InsertUserTask(userTask model.UserTask, db *sql.DB) error {
 	query := `
-		INSERT INTO users_task (id, id_task, task_name, start_time, end_time, total_minutes)
+		INSERT INTO users_task (id, id_task, task_name, start_time, end_time, total_seconds)
 		VALUES ($1, $2, $3, $4, $5, $6)`
-	_, err := db.Exec(query, userTask.UserID, userTask.IDTask, userTask.TaskName, userTask.StartTime, userTask.EndTime, userTask.TotalMinutes)
+	_, err := db.Exec(query, userTask.UserID, userTask.IDTask, userTask.TaskName, userTask.StartTime, userTask.EndTime, userTask.TotalSeconds)
 	return err
 }
 
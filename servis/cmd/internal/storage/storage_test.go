@@ -0,0 +1,9 @@
+package storage
+
+import "testing"
+
+func TestEscapeLike(t *testing.T) {
+	if got, want := EscapeLike(`100%_done\`), `100\%\_done\\`; got != want {
+		t.Errorf("EscapeLike = %q, want %q", got, want)
+	}
+}
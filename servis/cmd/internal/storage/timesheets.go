@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	model "main.go/tracker_model"
+)
+
+// TimesheetColumns перечень колонок timesheets в порядке, ожидаемом ScanTimesheet.
+const TimesheetColumns = "id, user_id, week_start, status, comment, manager_id, submitted_at, decided_at"
+
+// ScanTimesheet сканирует строку с колонками TimesheetColumns в модель табеля.
+func ScanTimesheet(row RowScanner) (model.Timesheet, error) {
+	var timesheet model.Timesheet
+	var managerID sql.NullInt64
+	var decidedAt sql.NullTime
+	err := row.Scan(&timesheet.ID, &timesheet.UserID, &timesheet.WeekStart, &timesheet.Status,
+		&timesheet.Comment, &managerID, &timesheet.SubmittedAt, &decidedAt)
+	if err != nil {
+		return timesheet, err
+	}
+	timesheet.ManagerID = int(managerID.Int64)
+	timesheet.DecidedAt = decidedAt.Time
+	return timesheet, nil
+}
+
+// ErrPeriodLocked возвращается при попытке изменить сессии в согласованном периоде.
+var ErrPeriodLocked = errors.New("период согласован и не может быть изменен")
+
+// WeekStart возвращает понедельник недели, к которой относится t.
+// Используются дата и время в часовом поясе самого значения t.
+func WeekStart(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, time.UTC)
+}
+
+// CheckPeriodUnlocked проверяет, что ни одна неделя интервала [start, end] не согласована для пользователя,
+// включая недели внутри интервала. Недели определяются в часовом поясе пользователя.
+// Нулевой end (незавершенная сессия) ограничивает проверку неделей start.
+func CheckPeriodUnlocked(q Querier, userID int, start, end time.Time) error {
+	loc, err := UserLocation(q, userID)
+	if err != nil {
+		return err
+	}
+	if end.IsZero() || end.Before(start) {
+		end = start
+	}
+	var locked bool
+	err = q.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM timesheets
+			WHERE user_id = $1 AND week_start BETWEEN $2 AND $3 AND status = $4
+		)
+	`, userID, WeekStart(start.In(loc)), WeekStart(end.In(loc)), model.StatusApproved).Scan(&locked)
+	if err != nil {
+		return fmt.Errorf("ошибка при проверке согласования периода: %v", err)
+	}
+	if locked {
+		return ErrPeriodLocked
+	}
+	return nil
+}
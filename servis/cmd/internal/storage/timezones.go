@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultTimezone часовой пояс пользователя, если он не задан.
+const DefaultTimezone = "UTC"
+
+// LoadTimezone проверяет имя часового пояса IANA, например Europe/Moscow, и возвращает его.
+// Пустое имя означает DefaultTimezone. "Local" не допускается: он зависит от настроек сервера
+// и неизвестен базе данных.
+func LoadTimezone(name string) (*time.Location, error) {
+	if name == "" {
+		name = DefaultTimezone
+	}
+	if name == "Local" {
+		return nil, errors.New("часовой пояс Local не поддерживается")
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("неизвестный часовой пояс %q", name)
+	}
+	return loc, nil
+}
+
+// UserLocation возвращает часовой пояс пользователя. Если пользователь не найден,
+// возвращается ошибка, оборачивающая sql.ErrNoRows.
+func UserLocation(q Querier, userID int) (*time.Location, error) {
+	var name string
+	if err := q.QueryRow(`SELECT timezone FROM users WHERE id = $1`, userID).Scan(&name); err != nil {
+		return nil, fmt.Errorf("ошибка при получении часового пояса пользователя: %w", err)
+	}
+	loc, err := LoadTimezone(name)
+	if err != nil {
+		// Неизвестный базе Go пояс не должен блокировать работу пользователя
+		return time.UTC, nil
+	}
+	return loc, nil
+}
+
+// LocalDate возвращает начало календарного дня date в часовом поясе loc.
+func LocalDate(date time.Time, loc *time.Location) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc)
+}
@@ -0,0 +1,97 @@
+// Package taskbudget вычисляет использование бюджета задач и оповещает о достижении его порогов.
+package taskbudget
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"math"
+
+	"main.go/cmd/internal/events"
+	"main.go/cmd/internal/outbox"
+	"main.go/cmd/internal/storage"
+	model "main.go/tracker_model"
+)
+
+// budgetThresholds пороги бюджета задачи в процентах по возрастанию и типы событий, отправляемых при их достижении.
+var budgetThresholds = []struct {
+	percent   int
+	eventType string
+}{
+	{80, events.TaskBudgetWarning},
+	{100, events.TaskBudgetExceeded},
+}
+
+// Query выбирает колонки в порядке, ожидаемом Scan; условие отбора задач добавляется к запросу.
+// Затраченное время считается по завершенным сессиям задачи всех пользователей.
+const Query = `
+	SELECT t.id_task, t.task_name, COALESCE(t.estimated_seconds, 0), t.budget_alert_percent,
+		COALESCE((SELECT SUM(ut.total_seconds) FROM users_tasks ut WHERE ut.id_task = t.id_task AND ut.end_time IS NOT NULL), 0)
+	FROM tasks t`
+
+// Scan сканирует строку Query и вычисляет остаток и процент использования бюджета.
+// Отрицательный остаток означает перерасход.
+func Scan(row storage.RowScanner) (model.TaskBudget, error) {
+	var budget model.TaskBudget
+	err := row.Scan(&budget.IDTask, &budget.TaskName, &budget.EstimatedSeconds, &budget.AlertedPercent, &budget.SpentSeconds)
+	if err != nil {
+		return budget, err
+	}
+	if budget.EstimatedSeconds > 0 {
+		budget.RemainingSeconds = budget.EstimatedSeconds - budget.SpentSeconds
+		budget.PercentUsed = math.Round(float64(budget.SpentSeconds)*10000/float64(budget.EstimatedSeconds)) / 100
+	}
+	return budget, nil
+}
+
+// Check в транзакции tx проверяет, не достигло ли затраченное время по задаче очередного
+// порога бюджета, о котором еще не было оповещения. Для достигнутого порога запоминает его в задаче,
+// чтобы оповещение отправлялось один раз, и записывает событие в outbox от имени пользователя userID.
+// Возвращает бюджет с достигнутым порогом либо nil, если оповещать не о чем.
+func Check(tx *sql.Tx, userID, taskID int) (*model.TaskBudget, error) {
+	// Блокировка задачи, чтобы одновременные завершения сессий не отправили оповещение дважды
+	var id int
+	err := tx.QueryRow(`SELECT id_task FROM tasks WHERE id_task = $1 FOR UPDATE`, taskID).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при блокировке задачи: %v", err)
+	}
+
+	budget, err := Scan(tx.QueryRow(Query+` WHERE t.id_task = $1`, taskID))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении бюджета задачи: %v", err)
+	}
+	if budget.EstimatedSeconds == 0 {
+		return nil, nil
+	}
+
+	percent, eventType := 0, ""
+	for _, t := range budgetThresholds {
+		if budget.SpentSeconds*100 >= budget.EstimatedSeconds*int64(t.percent) {
+			percent, eventType = t.percent, t.eventType
+		}
+	}
+	if percent <= budget.AlertedPercent {
+		return nil, nil
+	}
+
+	_, err = tx.Exec(`UPDATE tasks SET budget_alert_percent = $1 WHERE id_task = $2`, percent, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при сохранении порога бюджета задачи: %v", err)
+	}
+	budget.AlertedPercent = percent
+
+	if err := outbox.EnqueueBudget(tx, eventType, userID, budget); err != nil {
+		return nil, err
+	}
+	return &budget, nil
+}
+
+// LogAlert записывает в журнал предупреждение о достигнутом пороге бюджета, который вернул Check.
+// Для nil ничего не делает.
+func LogAlert(log *slog.Logger, budget *model.TaskBudget) {
+	if budget == nil {
+		return
+	}
+	log.Warn("Затраченное время по задаче достигло порога бюджета", slog.Int("task_id", budget.IDTask), slog.Int("threshold_percent", budget.AlertedPercent),
+		slog.Int64("spent_seconds", budget.SpentSeconds), slog.Int64("estimated_seconds", budget.EstimatedSeconds))
+}
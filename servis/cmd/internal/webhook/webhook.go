@@ -0,0 +1,266 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"main.go/cmd/internal/config"
+	"main.go/cmd/internal/events"
+	model "main.go/tracker_model"
+)
+
+// Заголовки запроса доставки события.
+const (
+	SignatureHeader = "X-Webhook-Signature" // sha256=<hex HMAC-SHA256 тела запроса по секрету подписки>
+	EventHeader     = "X-Webhook-Event"     // тип события
+	EventIDHeader   = "X-Webhook-Event-ID"  // идентификатор события для дедупликации на стороне получателя
+)
+
+// claimMargin запас сверх таймаута попытки, на который доставка закрепляется за экземпляром сервиса.
+// Если экземпляр остановится во время попытки, доставку по истечении срока выполнит другой.
+const claimMargin = time.Minute
+
+// Sign возвращает подпись тела запроса в формате, передаваемом в заголовке SignatureHeader.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff возвращает задержку перед следующей попыткой после неудачной попытки с номером attempt:
+// cfg.BaseBackoff, 2*cfg.BaseBackoff, 4*cfg.BaseBackoff, ...
+func Backoff(cfg config.WebhooksConfig, attempt int) time.Duration {
+	backoff := cfg.BaseBackoff
+	for i := 1; i < attempt && backoff < 24*time.Hour; i++ {
+		backoff *= 2
+	}
+	return backoff
+}
+
+// Deliver выполняет одну попытку доставки события на url. Возвращает HTTP статус ответа
+// (0 — ответ не получен) и ошибку, если событие не доставлено. Успешной считается попытка с ответом 2xx.
+func Deliver(ctx context.Context, client *http.Client, url, secret string, event events.Event) (int, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка сериализации события: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("ошибка формирования запроса: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(secret, body))
+	req.Header.Set(EventHeader, event.Type)
+	req.Header.Set(EventIDHeader, strconv.FormatInt(event.ID, 10))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("получатель вернул статус %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Dispatch ставит событие в очередь доставки всем активным подпискам на его тип: для каждой
+// подписки создается ожидающая доставка в webhook_deliveries. Повторный вызов для того же события
+// не создает дублей, поэтому outbox может безопасно передать событие еще раз.
+// Сами попытки доставки выполняет Run.
+func Dispatch(ctx context.Context, db *sql.DB, event events.Event) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, status, next_attempt_at)
+		SELECT id, $1, $2, $3, NOW() FROM webhooks
+		WHERE active AND $2 = ANY(event_types)
+		ON CONFLICT (webhook_id, event_id) DO NOTHING
+	`, event.ID, event.Type, model.DeliveryPending)
+	if err != nil {
+		return fmt.Errorf("ошибка постановки события в очередь доставки: %v", err)
+	}
+	return nil
+}
+
+// Run периодически выполняет ожидающие доставки, время попытки которых наступило, пока не будет отменен ctx.
+// Неудачная попытка откладывает доставку на Backoff; после cfg.MaxAttempts попыток доставка
+// помечается как failed и остается в журнале с последней ошибкой.
+func Run(ctx context.Context, db *sql.DB, log *slog.Logger, cfg config.WebhooksConfig) {
+	if cfg.Interval <= 0 || cfg.BatchSize <= 0 {
+		log.Warn("Не заданы interval или batch_size, доставка событий по webhook-подпискам отключена",
+			slog.Duration("interval", cfg.Interval), slog.Int("batch_size", cfg.BatchSize))
+		return
+	}
+
+	client := &http.Client{Timeout: cfg.Timeout}
+	log.Info("Запущена доставка событий по webhook-подпискам", slog.Duration("interval", cfg.Interval))
+
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			claimed, err := deliverBatch(ctx, db, log, client, cfg)
+			if err != nil {
+				log.Error("Ошибка доставки событий по webhook-подпискам", slog.String("error", err.Error()))
+				break
+			}
+			if claimed < cfg.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// delivery ожидающая доставка вместе с подпиской и телом события.
+type delivery struct {
+	id      int64
+	attempt int
+	url     string
+	secret  string
+	event   events.Event
+}
+
+// deliverBatch закрепляет за экземпляром пачку ожидающих доставок, фиксирует это и только затем
+// выполняет попытки параллельно, поэтому блокировки строк не удерживаются на время HTTP-запросов.
+// Возвращает количество закрепленных доставок.
+func deliverBatch(ctx context.Context, db *sql.DB, log *slog.Logger, client *http.Client, cfg config.WebhooksConfig) (int, error) {
+	batch, err := claimBatch(ctx, db, cfg)
+	if err != nil {
+		return 0, err
+	}
+
+	var wg sync.WaitGroup
+	for _, d := range batch {
+		wg.Add(1)
+		go func(d delivery) {
+			defer wg.Done()
+			statusCode, err := Deliver(ctx, client, d.url, d.secret, d.event)
+			if err := recordAttempt(db, cfg, d, statusCode, err); err != nil {
+				log.Error("Ошибка записи результата доставки", slog.Int64("delivery_id", d.id), slog.String("error", err.Error()))
+			}
+			if err != nil {
+				log.Warn("Событие не доставлено подписчику", slog.Int64("delivery_id", d.id), slog.Int64("event_id", d.event.ID),
+					slog.Int("attempt", d.attempt+1), slog.String("error", err.Error()))
+				return
+			}
+			log.Debug("Событие доставлено подписчику", slog.Int64("delivery_id", d.id), slog.Int64("event_id", d.event.ID))
+		}(d)
+	}
+	wg.Wait()
+	return len(batch), nil
+}
+
+// claimBatch выбирает ожидающие доставки, время попытки которых наступило, и переносит их следующую
+// попытку на время выполнения текущей. Строки блокируются FOR UPDATE SKIP LOCKED, поэтому
+// несколько экземпляров сервиса не закрепляют одну доставку одновременно.
+// Тело события берется из outbox, где оно хранится в единственном экземпляре.
+func claimBatch(ctx context.Context, db *sql.DB, cfg config.WebhooksConfig) ([]delivery, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при начале транзакции: %v", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT d.id, d.attempt, w.url, w.secret, d.event_id, o.payload
+		FROM webhook_deliveries d
+		JOIN webhooks w ON w.id = d.webhook_id
+		JOIN outbox o ON o.id = d.event_id
+		WHERE d.status = $1 AND d.next_attempt_at <= NOW() AND w.active
+		ORDER BY d.next_attempt_at
+		LIMIT $2
+		FOR UPDATE OF d SKIP LOCKED
+	`, model.DeliveryPending, cfg.BatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении ожидающих доставок: %v", err)
+	}
+
+	var batch []delivery
+	var ids []int64
+	for rows.Next() {
+		var d delivery
+		var payload string
+		if err := rows.Scan(&d.id, &d.attempt, &d.url, &d.secret, &d.event.ID, &payload); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("ошибка сканирования доставки: %v", err)
+		}
+		eventID := d.event.ID
+		if err := json.Unmarshal([]byte(payload), &d.event); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("ошибка разбора события outbox %d: %v", eventID, err)
+		}
+		d.event.ID = eventID
+		batch = append(batch, d)
+		ids = append(ids, d.id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка итерации по доставкам: %v", err)
+	}
+	if len(batch) == 0 {
+		return nil, nil
+	}
+
+	_, err = tx.Exec(`
+		UPDATE webhook_deliveries SET next_attempt_at = $1 WHERE id = ANY($2)
+	`, time.Now().Add(cfg.Timeout+claimMargin), pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка закрепления доставок: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ошибка при фиксации транзакции: %v", err)
+	}
+	return batch, nil
+}
+
+// recordAttempt сохраняет результат попытки доставки d и планирует следующую попытку,
+// если событие не доставлено и попытки не исчерпаны.
+func recordAttempt(db *sql.DB, cfg config.WebhooksConfig, d delivery, statusCode int, deliverErr error) error {
+	attempt := d.attempt + 1
+	status := model.DeliveryDelivered
+	var nextAttemptAt sql.NullTime
+	errText := ""
+	if deliverErr != nil {
+		errText = deliverErr.Error()
+		if attempt < cfg.MaxAttempts {
+			status = model.DeliveryPending
+			nextAttemptAt = sql.NullTime{Time: time.Now().Add(Backoff(cfg, attempt)), Valid: true}
+		} else {
+			status = model.DeliveryFailed
+		}
+	}
+	var code sql.NullInt64
+	if statusCode != 0 {
+		code = sql.NullInt64{Int64: int64(statusCode), Valid: true}
+	}
+
+	_, err := db.Exec(`
+		UPDATE webhook_deliveries
+		SET attempt = $1, status = $2, status_code = $3, success = $4, error = $5, next_attempt_at = $6, updated_at = NOW()
+		WHERE id = $7
+	`, attempt, status, code, deliverErr == nil, errText, nextAttemptAt, d.id)
+	return err
+}
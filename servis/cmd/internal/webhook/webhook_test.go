@@ -0,0 +1,90 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"main.go/cmd/internal/config"
+	"main.go/cmd/internal/events"
+	model "main.go/tracker_model"
+)
+
+func TestDeliverSignsRequest(t *testing.T) {
+	const secret = "s3cr3t"
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("read body: %v", err)
+		}
+		if got, want := r.Header.Get(SignatureHeader), Sign(secret, body); got != want {
+			t.Errorf("signature = %q, want %q", got, want)
+		}
+		if got := r.Header.Get(EventHeader); got != events.SessionEnded {
+			t.Errorf("event header = %q, want %q", got, events.SessionEnded)
+		}
+		if got := r.Header.Get(EventIDHeader); got != "42" {
+			t.Errorf("event id header = %q, want 42", got)
+		}
+
+		var event events.Event
+		if err := json.Unmarshal(body, &event); err != nil {
+			t.Errorf("unmarshal body: %v", err)
+		}
+		if event.Session == nil || event.Session.IDSession != 7 {
+			t.Errorf("unexpected session in payload: %+v", event.Session)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	event := events.Event{ID: 42, Type: events.SessionEnded, UserID: 1, Session: &model.UserTask{IDSession: 7, UserID: 1}}
+
+	statusCode, err := Deliver(context.Background(), receiver.Client(), receiver.URL, secret, event)
+	if err != nil {
+		t.Fatalf("Deliver: %v", err)
+	}
+	if statusCode != http.StatusNoContent {
+		t.Errorf("status code = %d, want %d", statusCode, http.StatusNoContent)
+	}
+}
+
+func TestDeliverFailsOnErrorStatus(t *testing.T) {
+	var calls int
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer receiver.Close()
+
+	event := events.Event{ID: 1, Type: events.UserDeleted, UserID: 3}
+
+	statusCode, err := Deliver(context.Background(), receiver.Client(), receiver.URL, "secret", event)
+	if err == nil {
+		t.Fatal("Deliver succeeded, want error")
+	}
+	if statusCode != http.StatusBadGateway {
+		t.Errorf("status code = %d, want %d", statusCode, http.StatusBadGateway)
+	}
+	// Повторы выполняет очередь доставки, а не Deliver
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	cfg := config.WebhooksConfig{BaseBackoff: time.Second}
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 5: 16 * time.Second} {
+		if got := Backoff(cfg, attempt); got != want {
+			t.Errorf("Backoff(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+	if got := Backoff(cfg, 100); got > 48*time.Hour {
+		t.Errorf("Backoff(100) = %v, want capped", got)
+	}
+}
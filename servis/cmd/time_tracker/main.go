@@ -1,16 +1,34 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 
+	"main.go/cmd/internal/audit"
+	"main.go/cmd/internal/autostop"
+	"main.go/cmd/internal/calendar"
 	"main.go/cmd/internal/config"
+	"main.go/cmd/internal/handlers/absence"
+	"main.go/cmd/internal/handlers/auditlog"
+	"main.go/cmd/internal/handlers/billing"
+	"main.go/cmd/internal/handlers/health"
+	"main.go/cmd/internal/handlers/report"
+	"main.go/cmd/internal/handlers/search"
+	"main.go/cmd/internal/handlers/stream"
 	"main.go/cmd/internal/handlers/task"
+	"main.go/cmd/internal/handlers/timesheet"
 	"main.go/cmd/internal/handlers/user"
+	"main.go/cmd/internal/handlers/webhooks"
+	"main.go/cmd/internal/metrics"
+	"main.go/cmd/internal/outbox"
+	"main.go/cmd/internal/passport"
+	"main.go/cmd/internal/rounding"
 	"main.go/cmd/internal/storage/cache"
 	"main.go/cmd/internal/storage/postgresql"
+	"main.go/cmd/internal/webhook"
 )
 
 const (
@@ -30,23 +48,106 @@ func main() {
 	db := postgresql.Connect(cfg.Database)
 	defer db.Close()
 
+	// Ключ шифрования паспортных данных; паспорта, еще хранящиеся открыто, шифруются при старте
+	passportCipher, err := passport.New(cfg.Passport)
+	if err != nil {
+		log.Error("Неверные настройки шифрования паспортных данных", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	encrypted, err := passport.EncryptExisting(db, passportCipher)
+	if err != nil {
+		log.Error("Ошибка шифрования паспортных данных", slog.Int("encrypted", encrypted), slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	if encrypted > 0 {
+		log.Info("Паспортные данные зашифрованы", slog.Int("count", encrypted))
+	}
+
 	// Инициализация кэша
 	cache.InitCache()
 
 	cache.CacheAllUsersFromDB(db)
 
+	// Показатели пула соединений, кэша и бизнес-показатели для /metrics
+	registerGauges(db)
+
+	// Политика округления длительности сессий в отчетах
+	roundingPolicy, err := rounding.New(cfg.Rounding)
+	if err != nil {
+		log.Error("Неверные настройки округления", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	// Загрузка праздничных дней производственного календаря
+	if cfg.Calendar.HolidaysFile != "" {
+		count, err := calendar.LoadHolidaysFile(db, cfg.Calendar.HolidaysFile)
+		if err != nil {
+			log.Error("Ошибка загрузки праздничных дней", slog.String("file", cfg.Calendar.HolidaysFile), slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		log.Info("Праздничные дни загружены", slog.String("file", cfg.Calendar.HolidaysFile), slog.Int("count", count))
+	}
+
+	// Фоновое завершение забытых сессий
+	go autostop.Run(context.Background(), db, log, cfg.AutoStop)
+
+	// Публикация событий из outbox во внутреннюю шину, журнал и очередь доставки webhook-подписок
+	go outbox.Run(context.Background(), db, log, cfg.Outbox, outbox.Sinks(db, log))
+
+	// Доставка событий по webhook-подпискам с повторами
+	go webhook.Run(context.Background(), db, log, cfg.Webhooks)
+
 	//http.HandleFunc()
 	// Настройка маршрутов и обработчиков
-	http.HandleFunc("/adduser", user.AddUserHandler(db, log))
-	http.HandleFunc("/start_task", task.StartTaskHandler(db, log))
+	http.HandleFunc("/adduser", user.AddUserHandler(db, log, passportCipher))
+	http.HandleFunc("/start_task", task.StartTaskHandler(db, log, cfg.Tasks))
 	http.HandleFunc("/end_task", task.EndTaskHandler(db, log))
-	http.HandleFunc("/user_task", task.GetUserTaskSummaryHandler(db, log))
+	http.HandleFunc("/user_task", task.GetUserTaskSummaryHandler(db, log, roundingPolicy))
+	http.HandleFunc("GET /tasks/budgets", task.GetTaskBudgetsHandler(db, log))
+	http.HandleFunc("PUT /tasks/{id}/estimate", task.SetTaskEstimateHandler(db, log))
+	http.HandleFunc("GET /reports", report.GetReportHandler(db, log, roundingPolicy))
+	http.HandleFunc("GET /reports/overtime", report.GetOvertimeReportHandler(db, log, roundingPolicy, cfg.Calendar))
+	http.HandleFunc("POST /projects", billing.AddProjectHandler(db, log))
+	http.HandleFunc("POST /rates", billing.AddRateHandler(db, log))
+	http.HandleFunc("GET /rates", billing.GetRatesHandler(db, log))
+	http.HandleFunc("POST /invoices", billing.CreateInvoiceHandler(db, log, roundingPolicy))
+	http.HandleFunc("GET /invoices/{id}", billing.GetInvoiceHandler(db, log))
+	http.HandleFunc("/add_session", task.AddSessionHandler(db, log))
+	http.HandleFunc("/update_session/", task.UpdateSessionHandler(db, log))
+	http.HandleFunc("/review_sessions", task.GetReviewSessionsHandler(db, log))
+	http.HandleFunc("GET /search", search.SearchHandler(db, log))
+	http.HandleFunc("/events", stream.EventsHandler(log))
+	http.HandleFunc("POST /webhooks", webhooks.AddWebhookHandler(db, log))
+	http.HandleFunc("GET /webhooks", webhooks.GetWebhooksHandler(db, log))
+	http.HandleFunc("DELETE /webhooks/{id}", webhooks.DeleteWebhookHandler(db, log))
+	http.HandleFunc("GET /webhooks/{id}/deliveries", webhooks.GetWebhookDeliveriesHandler(db, log))
+	http.HandleFunc("POST /absences", absence.AddAbsenceHandler(db, log))
+	http.HandleFunc("GET /absences", absence.GetAbsencesHandler(db, log))
+	http.HandleFunc("POST /absences/{id}/approve", absence.ApproveAbsenceHandler(db, log))
+	http.HandleFunc("POST /absences/{id}/reject", absence.RejectAbsenceHandler(db, log))
+	http.HandleFunc("/submit_timesheet", timesheet.SubmitTimesheetHandler(db, log))
+	http.HandleFunc("/approve_timesheet/", timesheet.ApproveTimesheetHandler(db, log))
+	http.HandleFunc("/reject_timesheet/", timesheet.RejectTimesheetHandler(db, log))
+	http.HandleFunc("/timesheets", timesheet.GetTimesheetsHandler(db, log))
 	http.HandleFunc("/delete_user", user.DeleteUserHandler(db, log))
-	http.HandleFunc("/update_user/", user.UpdateUserHandler(db, log))
-	http.HandleFunc("/users", user.GetUsersHandler(db, log))
-
+	http.HandleFunc("/update_user/", user.UpdateUserHandler(db, log, passportCipher))
+	http.HandleFunc("/users", user.GetUsersHandler(db, log, roundingPolicy, passportCipher))
+	http.HandleFunc("GET /users/current", user.GetWorkingNowHandler(log))
+	http.HandleFunc("GET /users/{id}/current", user.GetCurrentStatusHandler(db, log))
+	http.HandleFunc("GET /users/{id}/schedule", user.GetWorkScheduleHandler(db, log, cfg.Calendar))
+	http.HandleFunc("PUT /users/{id}/schedule", user.SetWorkScheduleHandler(db, log))
+	http.HandleFunc("GET /users/{id}/export", user.ExportUserDataHandler(db, log, passportCipher, cfg.Calendar))
+	http.HandleFunc("POST /users/{id}/anonymize", user.AnonymizeUserHandler(db, log))
+	http.HandleFunc("GET /audit", auditlog.GetAuditLogHandler(db, log))
+	http.Handle("GET /metrics", metrics.Handler(log))
+	http.HandleFunc("GET /healthz", health.HealthzHandler())
+	http.HandleFunc("GET /readyz", health.ReadyzHandler(db, log))
+
+	// Идентификатор запроса и инициатор изменений передаются обработчикам для журнала аудита,
+	// запросы учитываются в метриках по маршрутам
 	server := &http.Server{
 		Addr:         cfg.HTTPServer.Address,
+		Handler:      audit.Middleware(metrics.Middleware(http.DefaultServeMux)),
 		ReadTimeout:  cfg.HTTPServer.Timeout,
 		WriteTimeout: cfg.HTTPServer.Timeout,
 		IdleTimeout:  cfg.HTTPServer.IdleTimeout,
@@ -54,7 +155,7 @@ func main() {
 
 	log.Info("HTTP сервер запущен на", slog.String("адрес", cfg.HTTPServer.Address))
 
-	err := server.ListenAndServe()
+	err = server.ListenAndServe()
 	if err != nil {
 		log.Error("Ошибка запуска сервера", slog.String("ошибка", err.Error()))
 		os.Exit(1)
@@ -67,15 +168,15 @@ func setupLogger(env string) *slog.Logger {
 	switch env {
 	case envLocal:
 		log = slog.New(
-			slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}),
+			slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug, ReplaceAttr: passport.ReplaceAttr}),
 		)
 	case envDev:
 		log = slog.New(
-			slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}),
+			slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug, ReplaceAttr: passport.ReplaceAttr}),
 		)
 	case envProd:
 		log = slog.New(
-			slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}),
+			slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo, ReplaceAttr: passport.ReplaceAttr}),
 		)
 	}
 
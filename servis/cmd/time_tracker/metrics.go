@@ -0,0 +1,45 @@
+package main
+
+import (
+	"database/sql"
+
+	"main.go/cmd/internal/metrics"
+	"main.go/cmd/internal/storage/cache"
+	model "main.go/tracker_model"
+)
+
+// registerGauges регистрирует показатели, вычисляемые при каждом запросе /metrics:
+// состояние пула соединений, размер кэша и бизнес-показатели из базы данных.
+func registerGauges(db *sql.DB) {
+	metrics.NewGaugeFunc("time_tracker_db_open_connections", "Количество открытых соединений с базой данных.",
+		func() (float64, error) { return float64(db.Stats().OpenConnections), nil })
+	metrics.NewGaugeFunc("time_tracker_db_in_use_connections", "Количество соединений с базой данных, занятых запросами.",
+		func() (float64, error) { return float64(db.Stats().InUse), nil })
+
+	metrics.NewGaugeFunc("time_tracker_cached_users", "Количество пользователей в кэше.",
+		func() (float64, error) {
+			cache.UserCacheMutex.RLock()
+			defer cache.UserCacheMutex.RUnlock()
+			return float64(len(cache.UserCache)), nil
+		})
+
+	metrics.NewGaugeFunc("time_tracker_running_sessions", "Количество незавершенных сессий.",
+		countQuery(db, `SELECT COUNT(*) FROM users_tasks WHERE end_time IS NULL`))
+	metrics.NewGaugeFunc("time_tracker_outbox_pending_events", "Количество событий outbox, ожидающих публикации.",
+		countQuery(db, `SELECT COUNT(*) FROM outbox WHERE published_at IS NULL`))
+	metrics.NewGaugeFunc("time_tracker_webhook_pending_deliveries", "Количество доставок по webhook-подпискам, ожидающих попытки.",
+		countQuery(db, `SELECT COUNT(*) FROM webhook_deliveries WHERE status = $1`, model.DeliveryPending))
+	metrics.NewGaugeFunc("time_tracker_timesheets_awaiting_review", "Количество табелей, ожидающих согласования.",
+		countQuery(db, `SELECT COUNT(*) FROM timesheets WHERE status = $1`, model.StatusSubmitted))
+	metrics.NewGaugeFunc("time_tracker_absences_awaiting_review", "Количество заявок на отсутствие, ожидающих согласования.",
+		countQuery(db, `SELECT COUNT(*) FROM absences WHERE status = $1`, model.StatusSubmitted))
+}
+
+// countQuery возвращает функцию показателя, выполняющую запрос с COUNT(*).
+func countQuery(db *sql.DB, query string, args ...interface{}) func() (float64, error) {
+	return func() (float64, error) {
+		var count int64
+		err := db.QueryRow(query, args...).Scan(&count)
+		return float64(count), err
+	}
+}
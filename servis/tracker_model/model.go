@@ -1,30 +1,384 @@
 package tracker_model
 
 import (
+	"encoding/json"
+	"log/slog"
 	"time"
 )
 
+// Роли пользователей.
+const (
+	RoleEmployee = "employee"
+	RoleManager  = "manager"
+)
+
+// Статусы сессий и табелей в процессе согласования.
+const (
+	StatusDraft     = "draft"
+	StatusSubmitted = "submitted"
+	StatusApproved  = "approved"
+	StatusRejected  = "rejected"
+)
+
+// Типы отсутствий.
+const (
+	AbsenceVacation     = "vacation"
+	AbsenceSick         = "sick"
+	AbsenceBusinessTrip = "business_trip"
+	AbsenceOther        = "other"
+)
+
+// Users пользователь. Серия и номер паспорта в ответах API заполняются только для руководителей,
+// остальным возвращается маскированный Passport ("12** ****90").
 type Users struct {
 	UserID         int        `json:"id"`
-	PassportSerie  int        `json:"passport_serie"`
-	PassportNumber int        `json:"passport_number"`
+	PassportSerie  int        `json:"passport_serie,omitempty"`
+	PassportNumber int        `json:"passport_number,omitempty"`
+	Passport       string     `json:"passport,omitempty"`
 	Surname        string     `json:"surname"`
 	Name           string     `json:"name"`
 	Patronymic     string     `json:"patronymic"`
 	Address        string     `json:"address"`
+	Role           string     `json:"role"`
+	Timezone       string     `json:"timezone"`
 	UserTask       []UserTask `json:"userTask"`
 }
 
+// LogValue реализует slog.LogValuer: паспортные данные и сессии в журнал не попадают.
+func (u Users) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int("id", u.UserID),
+		slog.String("surname", u.Surname),
+		slog.String("name", u.Name),
+		slog.String("patronymic", u.Patronymic),
+		slog.String("role", u.Role),
+		slog.String("timezone", u.Timezone),
+	)
+}
+
 type UserTask struct {
+	IDSession    int       `json:"id_session"`
 	UserID       int       `json:"id_user"`
 	IDTask       int       `json:"id_task"`
 	TaskName     string    `json:"task_name"`
 	StartTime    time.Time `json:"start_time"`
 	EndTime      time.Time `json:"end_time"`
+	TotalSeconds int64     `json:"total_seconds"`
 	TotalMinutes int       `json:"total_minutes"`
+	Status       string    `json:"status"`
+	AutoClosed   bool      `json:"auto_closed"`
+	Billable     bool      `json:"billable"`
+	InvoiceID    int       `json:"invoice_id,omitempty"`
+	Description  string    `json:"description,omitempty"`
+	Tags         []string  `json:"tags"`
 }
 
 type Task struct {
 	IDTask   int    `json:"id_task"`
 	TaskName string `json:"task_name"`
 }
+
+// Timesheet недельный табель пользователя, отправляемый на согласование руководителю.
+type Timesheet struct {
+	ID          int       `json:"id"`
+	UserID      int       `json:"user_id"`
+	WeekStart   time.Time `json:"week_start"`
+	Status      string    `json:"status"`
+	Comment     string    `json:"comment"`
+	ManagerID   int       `json:"manager_id,omitempty"`
+	SubmittedAt time.Time `json:"submitted_at"`
+	DecidedAt   time.Time `json:"decided_at"`
+}
+
+// Webhook подписка внешней системы на события трекера.
+type Webhook struct {
+	ID         int       `json:"id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	Secret     string    `json:"secret,omitempty"`
+	Active     bool      `json:"active"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Состояния доставки события подписчику.
+const (
+	DeliveryPending   = "pending"   // ожидает очередной попытки
+	DeliveryDelivered = "delivered" // доставлено
+	DeliveryFailed    = "failed"    // не доставлено после всех попыток
+)
+
+// WebhookDelivery доставка события подписчику. StatusCode, Success и Error относятся к последней попытке,
+// NextAttemptAt заполнено только у ожидающих доставок.
+type WebhookDelivery struct {
+	ID            int        `json:"id"`
+	WebhookID     int        `json:"webhook_id"`
+	EventID       int64      `json:"event_id"`
+	EventType     string     `json:"event_type"`
+	Status        string     `json:"status"`
+	Attempt       int        `json:"attempt"` // количество выполненных попыток
+	StatusCode    int        `json:"status_code"`
+	Success       bool       `json:"success"`
+	Error         string     `json:"error"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// ReportRow строка отчета о трудозатратах по одной группе (дню, неделе, месяцу, задаче или пользователю).
+type ReportRow struct {
+	Key              string  `json:"key"`
+	Label            string  `json:"label,omitempty"`
+	TotalSeconds     int64   `json:"total_seconds"`
+	TotalMinutes     int     `json:"total_minutes"`
+	Hours            int     `json:"hours"`
+	Minutes          int     `json:"minutes"`
+	Sessions         int     `json:"sessions"`
+	WorkingDays      int     `json:"working_days"`
+	AveragePerDayMin float64 `json:"average_minutes_per_working_day"`
+}
+
+// Report отчет о трудозатратах за период со строками по группам и общим итогом.
+type Report struct {
+	GroupBy   string      `json:"group_by"`
+	StartDate string      `json:"start_date"`
+	EndDate   string      `json:"end_date"`
+	Timezone  string      `json:"timezone"`
+	Rows      []ReportRow `json:"rows"`
+	Total     ReportRow   `json:"total"`
+}
+
+// Project проект, объединяющий задачи для учета ставок.
+type Project struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	TaskIDs []int  `json:"task_ids"`
+}
+
+// Rate почасовая ставка. Пустые (нулевые) user_id, id_task и project_id означают любое значение;
+// для сессии выбирается наиболее конкретная ставка, действующая на дату ее начала.
+type Rate struct {
+	ID            int       `json:"id"`
+	UserID        int       `json:"user_id,omitempty"`
+	IDTask        int       `json:"id_task,omitempty"`
+	ProjectID     int       `json:"project_id,omitempty"`
+	HourlyRate    string    `json:"hourly_rate"`
+	Currency      string    `json:"currency"`
+	EffectiveFrom time.Time `json:"effective_from"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Invoice счет за период в одной валюте. Сессии, вошедшие в счет, больше не могут быть изменены.
+type Invoice struct {
+	ID          int           `json:"id"`
+	PeriodStart time.Time     `json:"period_start"`
+	PeriodEnd   time.Time     `json:"period_end"`
+	Currency    string        `json:"currency"`
+	Total       string        `json:"total"`
+	CreatedAt   time.Time     `json:"created_at"`
+	Lines       []InvoiceLine `json:"lines"`
+}
+
+// InvoiceLine строка счета по одной сессии.
+type InvoiceLine struct {
+	ID            int       `json:"id"`
+	SessionID     int       `json:"session_id"`
+	UserID        int       `json:"user_id"`
+	IDTask        int       `json:"id_task"`
+	TaskName      string    `json:"task_name"`
+	StartTime     time.Time `json:"start_time"`
+	EndTime       time.Time `json:"end_time"`
+	BilledSeconds int64     `json:"billed_seconds"`
+	HourlyRate    string    `json:"hourly_rate"`
+	Amount        string    `json:"amount"`
+}
+
+// TaskBudget оценка трудозатрат по задаче и фактически затраченное время по завершенным сессиям.
+type TaskBudget struct {
+	IDTask           int     `json:"id_task"`
+	TaskName         string  `json:"task_name"`
+	EstimatedSeconds int64   `json:"estimated_seconds"`
+	SpentSeconds     int64   `json:"spent_seconds"`
+	RemainingSeconds int64   `json:"remaining_seconds"`
+	PercentUsed      float64 `json:"percent_used"`
+	AlertedPercent   int     `json:"alerted_percent"`
+}
+
+// WorkSchedule график работы пользователя: норма часов по дням недели (monday … sunday).
+// Default означает, что график не задан и используется стандартная пятидневка.
+type WorkSchedule struct {
+	UserID  int                `json:"user_id"`
+	Hours   map[string]float64 `json:"hours"`
+	Default bool               `json:"default"`
+}
+
+// OvertimeDay отработанное время за день в сравнении с нормой по графику.
+// Работа в выходной или праздничный день целиком считается переработкой. В день согласованного
+// отсутствия норма снижается до нуля, а при отсутствии на половину дня — вдвое; Absence — тип отсутствия.
+type OvertimeDay struct {
+	Date             string `json:"date"`
+	WorkedSeconds    int64  `json:"worked_seconds"`
+	ScheduledSeconds int64  `json:"scheduled_seconds"`
+	RegularSeconds   int64  `json:"regular_seconds"`
+	OvertimeSeconds  int64  `json:"overtime_seconds"`
+	Weekend          bool   `json:"weekend"`
+	Holiday          bool   `json:"holiday"`
+	HolidayName      string `json:"holiday_name,omitempty"`
+	Absence          string `json:"absence,omitempty"`
+}
+
+// OvertimeWeek итог по неделе, начинающейся с понедельника WeekStart, или по всему периоду.
+type OvertimeWeek struct {
+	WeekStart                string  `json:"week_start,omitempty"`
+	WorkedSeconds            int64   `json:"worked_seconds"`
+	ScheduledSeconds         int64   `json:"scheduled_seconds"`
+	RegularSeconds           int64   `json:"regular_seconds"`
+	OvertimeSeconds          int64   `json:"overtime_seconds"`
+	WeekendAndHolidaySeconds int64   `json:"weekend_and_holiday_seconds"` // в выходные по графику и праздничные дни
+	AbsenceDays              float64 `json:"absence_days"`
+}
+
+// OvertimeReport отчет о переработках пользователя за период по дням и неделям.
+type OvertimeReport struct {
+	UserID                 int            `json:"user_id"`
+	StartDate              string         `json:"start_date"`
+	EndDate                string         `json:"end_date"`
+	Timezone               string         `json:"timezone"`
+	WeeklyThresholdSeconds int64          `json:"weekly_threshold_seconds"`
+	Days                   []OvertimeDay  `json:"days"`
+	Weeks                  []OvertimeWeek `json:"weeks"`
+	Total                  OvertimeWeek   `json:"total"`
+}
+
+// Absence отсутствие пользователя (отпуск, больничный, командировка) на период дат включительно.
+// Отсутствие на половину дня задается для одного дня. Days — количество календарных дней отсутствия.
+// Отсутствие учитывается после согласования руководителем.
+type Absence struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	Type      string    `json:"type"`
+	StartDate time.Time `json:"start_date"`
+	EndDate   time.Time `json:"end_date"`
+	HalfDay   bool      `json:"half_day"`
+	Days      float64   `json:"days"`
+	Status    string    `json:"status"`
+	Comment   string    `json:"comment"`
+	ManagerID int       `json:"manager_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	DecidedAt time.Time `json:"decided_at"`
+}
+
+// UserSummary трудозатраты пользователя за период вместе с согласованными отсутствиями.
+// AbsenceDays — дни отсутствия внутри периода.
+type UserSummary struct {
+	Sessions    []UserTask `json:"sessions"`
+	Absences    []Absence  `json:"absences"`
+	AbsenceDays float64    `json:"absence_days"`
+}
+
+// SearchResult результат полнотекстового поиска: пользователь, задача или сессия.
+// Headline содержит фрагменты текста с найденными словами, выделенными тегами <b></b>.
+type SearchResult struct {
+	Type     string  `json:"type"`
+	ID       int     `json:"id"`
+	UserID   int     `json:"user_id,omitempty"`
+	Title    string  `json:"title"`
+	Headline string  `json:"headline"`
+	Rank     float64 `json:"rank"`
+}
+
+// TaskTotal суммарные трудозатраты пользователя по одной задаче.
+type TaskTotal struct {
+	IDTask       int    `json:"id_task"`
+	TaskName     string `json:"task_name"`
+	TotalSeconds int64  `json:"total_seconds"`
+	TotalMinutes int    `json:"total_minutes"`
+	Sessions     int    `json:"sessions"`
+}
+
+// UserListItem пользователь в списке пользователей, при запросе — со сводкой трудозатрат по задачам.
+// Паспорт маскируется так же, как в Users.
+type UserListItem struct {
+	UserID         int         `json:"id"`
+	PassportSerie  int         `json:"passport_serie,omitempty"`
+	PassportNumber int         `json:"passport_number,omitempty"`
+	Passport       string      `json:"passport"`
+	Surname        string      `json:"surname"`
+	Name           string      `json:"name"`
+	Patronymic     string      `json:"patronymic"`
+	Address        string      `json:"address"`
+	Role           string      `json:"role"`
+	Timezone       string      `json:"timezone"`
+	Anonymized     bool        `json:"anonymized,omitempty"`
+	Summary        []TaskTotal `json:"summary,omitempty"`
+}
+
+// UserPage страница списка пользователей. NextCursor передается в параметре cursor
+// для получения следующей страницы и пуст на последней странице.
+type UserPage struct {
+	Items      []UserListItem `json:"items"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+	Total      *int           `json:"total,omitempty"`
+}
+
+// ExportEvent событие из истории изменений данных пользователя.
+type ExportEvent struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// UserExport выгрузка всех персональных данных пользователя по его запросу.
+// Паспорт в профиле не маскируется.
+type UserExport struct {
+	GeneratedAt time.Time     `json:"generated_at"`
+	Profile     UserListItem  `json:"profile"`
+	Schedule    WorkSchedule  `json:"schedule"`
+	Sessions    []UserTask    `json:"sessions"`
+	Timesheets  []Timesheet   `json:"timesheets"`
+	Absences    []Absence     `json:"absences"`
+	Events      []ExportEvent `json:"events"`
+	Audit       []AuditEntry  `json:"audit"`
+}
+
+// AuditEntry запись журнала аудита об одном изменении. ActorID 0 — изменение выполнено системой
+// (например, автоматическим завершением сессий). Diff содержит только измененные поля
+// в виде {"поле": {"before": ..., "after": ...}}.
+type AuditEntry struct {
+	ID        int64           `json:"id"`
+	ActorID   int             `json:"actor_id,omitempty"`
+	Action    string          `json:"action"`
+	Entity    string          `json:"entity"`
+	EntityID  int             `json:"entity_id"`
+	UserID    int             `json:"user_id,omitempty"`
+	Diff      json.RawMessage `json:"diff"`
+	RequestID string          `json:"request_id,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// AuditPage страница журнала аудита от новых записей к старым. NextBeforeID передается
+// в before_id для получения следующей страницы и отсутствует на последней.
+type AuditPage struct {
+	Items        []AuditEntry `json:"items"`
+	NextBeforeID int64        `json:"next_before_id,omitempty"`
+}
+
+// Состояния проверок готовности.
+const (
+	HealthOK       = "ok"
+	HealthDegraded = "degraded"
+	HealthFailed   = "failed"
+)
+
+// HealthCheck результат проверки одной зависимости сервиса.
+type HealthCheck struct {
+	Status     string `json:"status"` // ok, degraded или failed
+	DurationMS int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Readiness ответ проверки готовности: общий статус (ready, degraded, not_ready) и проверки по зависимостям.
+type Readiness struct {
+	Status string                 `json:"status"`
+	Checks map[string]HealthCheck `json:"checks"`
+}